@@ -10,6 +10,7 @@ import (
 
 type Config struct {
 	Linear LinearConfig `yaml:"linear"`
+	Deck   string       `yaml:"deck"` // Deck name or card list, see server.SetDeck
 }
 
 type LinearConfig struct {
@@ -40,9 +41,5 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
-	if config.Linear.APIKey == "" {
-		return nil, fmt.Errorf("linear.api_key is required in config file")
-	}
-
 	return &config, nil
 }
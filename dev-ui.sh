@@ -25,7 +25,7 @@ echo ""
 
 echo "🚀 Starting Go WebSocket server on port 9867..."
 # Pass through all arguments to the poker server command
-./poker server --port 9867 "$@" &
+./poker server --port 9867 --no-web "$@" &
 GO_PID=$!
 echo "   PID: $GO_PID"
 echo ""
@@ -12,4 +12,4 @@ echo ""
 echo "🚀 Starting Go server..."
 echo ""
 
-go run main.go server --port 9867
+go run main.go server --port 9867 --no-web
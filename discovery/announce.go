@@ -2,6 +2,7 @@ package discovery
 
 import (
 	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/grandcat/zeroconf"
@@ -25,10 +26,12 @@ func AnnounceSession(name string, port int) (func(), error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to announce session: %w", err)
 	}
+	slog.Debug("Registered mDNS service", "session", name, "port", port, "txt", txtRecords)
 
 	shutdown := func() {
 		if server != nil {
 			server.Shutdown()
+			slog.Debug("Withdrew mDNS announcement", "session", name)
 		}
 	}
 
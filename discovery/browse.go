@@ -3,6 +3,7 @@ package discovery
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"net"
 	"time"
 
@@ -55,6 +56,7 @@ func DiscoverSessions(ctx context.Context, timeout time.Duration) ([]*Session, e
 			session, exists := sessionMap[key]
 
 			if !exists {
+				slog.Debug("Discovered session", "session", entry.Instance, "host", entry.HostName, "port", entry.Port)
 				session = &Session{
 					Name:  entry.Instance,
 					Host:  entry.HostName,
@@ -2,6 +2,7 @@ package discovery
 
 import (
 	"fmt"
+	"log/slog"
 	"os/exec"
 	"path/filepath"
 )
@@ -36,6 +37,7 @@ func tryTailscaleCLI(cliPath string) (string, bool) {
 	cmd := exec.Command(cliPath, "ip", "-4")
 	output, err := cmd.Output()
 	if err != nil {
+		slog.Debug("Tailscale CLI unavailable", "cli", cliPath, "err", err)
 		return "", false
 	}
 
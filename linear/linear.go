@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"regexp"
@@ -18,8 +19,14 @@ type LinearClient struct {
 	httpClient *http.Client
 	apiKey     string
 	apiURL     string
+	log        *slog.Logger
+	observer   Observer
 }
 
+// Observer is told about every call to the Linear API: the client method
+// called, how long it took and the error it returned
+type Observer func(operation string, duration time.Duration, err error)
+
 type LinearIssue struct {
 	ID          string
 	Identifier  string
@@ -41,11 +48,38 @@ func NewClient(apiKey string) *LinearClient {
 		httpClient: &http.Client{Timeout: 30 * time.Second},
 		apiKey:     apiKey,
 		apiURL:     "https://api.linear.app/graphql",
+		log:        slog.Default(),
+	}
+}
+
+// SetLogger sets the logger requests are logged to (default: slog.Default())
+func (c *LinearClient) SetLogger(logger *slog.Logger) {
+	c.log = logger
+}
+
+// SetObserver sets the function told about every API call, e.g. to record
+// metrics
+func (c *LinearClient) SetObserver(observer Observer) {
+	c.observer = observer
+}
+
+// observe reports a finished API call to the observer, if any
+func (c *LinearClient) observe(operation string, start time.Time, err error) {
+	if c.observer != nil {
+		c.observer(operation, time.Since(start), err)
 	}
 }
 
-// executeQuery executes a GraphQL query against the Linear API
-func (c *LinearClient) executeQuery(ctx context.Context, query string, result interface{}) error {
+// executeQuery executes a GraphQL query against the Linear API. The
+// operation names the query in the logs.
+func (c *LinearClient) executeQuery(ctx context.Context, operation string, query string, result interface{}) (err error) {
+	start := time.Now()
+	defer func() {
+		// The query holds issue IDs and comment bodies, so it is only
+		// logged at debug level, like everything else here
+		c.log.Debug("Linear request", "operation", operation, "duration", time.Since(start), "query", query, "err", err)
+	}()
+
 	reqBody := map[string]string{"query": query}
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
@@ -122,7 +156,9 @@ func ParseCycleURL(cycleURL string) (*CycleInfo, error) {
 }
 
 // FetchCycleIssuesWithoutEstimates queries Linear for issues in a cycle without estimates
-func (c *LinearClient) FetchCycleIssuesWithoutEstimates(cycleInfo *CycleInfo) ([]LinearIssue, error) {
+func (c *LinearClient) FetchCycleIssuesWithoutEstimates(cycleInfo *CycleInfo) (issues []LinearIssue, err error) {
+	defer func(start time.Time) { c.observe("FetchCycleIssuesWithoutEstimates", start, err) }(time.Now())
+
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -147,7 +183,7 @@ func (c *LinearClient) FetchCycleIssuesWithoutEstimates(cycleInfo *CycleInfo) ([
 		} `json:"teams"`
 	}
 
-	if err := c.executeQuery(ctx, teamsQueryStr, &teamsResult); err != nil {
+	if err := c.executeQuery(ctx, "teams", teamsQueryStr, &teamsResult); err != nil {
 		return nil, fmt.Errorf("failed to fetch teams: %w", err)
 	}
 
@@ -203,7 +239,7 @@ func (c *LinearClient) FetchCycleIssuesWithoutEstimates(cycleInfo *CycleInfo) ([
 		} `json:"organization"`
 	}
 
-	if err := c.executeQuery(ctx, cyclesQueryStr, &cyclesResult); err != nil {
+	if err := c.executeQuery(ctx, "cycles", cyclesQueryStr, &cyclesResult); err != nil {
 		return nil, fmt.Errorf("failed to fetch cycles: %w", err)
 	}
 
@@ -267,6 +303,7 @@ func (c *LinearClient) FetchCycleIssuesWithoutEstimates(cycleInfo *CycleInfo) ([
 	if cycleID == "" {
 		return nil, fmt.Errorf("no matching cycle found for '%s' in team %s", cycleInfo.CycleType, cycleInfo.TeamKey)
 	}
+	c.log.Debug("Resolved Linear cycle", "team", cycleInfo.TeamKey, "cycle", cycleInfo.CycleType, "id", cycleID)
 
 	// Get issues without estimates for this cycle
 	issuesQueryStr := fmt.Sprintf(`{
@@ -297,11 +334,11 @@ func (c *LinearClient) FetchCycleIssuesWithoutEstimates(cycleInfo *CycleInfo) ([
 		} `json:"issues"`
 	}
 
-	if err := c.executeQuery(ctx, issuesQueryStr, &issuesResult); err != nil {
+	if err := c.executeQuery(ctx, "cycleIssues", issuesQueryStr, &issuesResult); err != nil {
 		return nil, fmt.Errorf("failed to fetch issues: %w", err)
 	}
 
-	issues := make([]LinearIssue, 0, len(issuesResult.Issues.Nodes))
+	issues = make([]LinearIssue, 0, len(issuesResult.Issues.Nodes))
 	for _, node := range issuesResult.Issues.Nodes {
 		issues = append(issues, LinearIssue{
 			ID:          node.ID,
@@ -335,8 +372,25 @@ func (c *LinearClient) FetchCycleIssuesWithoutEstimates(cycleInfo *CycleInfo) ([
 	return issues, nil
 }
 
+// Ping checks that the API is reachable and accepts the API key
+func (c *LinearClient) Ping(ctx context.Context) (err error) {
+	defer func(start time.Time) { c.observe("Ping", start, err) }(time.Now())
+
+	var result struct {
+		Viewer struct {
+			ID string `json:"id"`
+		} `json:"viewer"`
+	}
+	if err := c.executeQuery(ctx, "viewer", `{ viewer { id } }`, &result); err != nil {
+		return fmt.Errorf("failed to reach Linear: %w", err)
+	}
+	return nil
+}
+
 // PostComment adds a comment to a Linear issue with voting breakdown
-func (c *LinearClient) PostComment(issueID string, commentBody string) error {
+func (c *LinearClient) PostComment(issueID string, commentBody string) (err error) {
+	defer func(start time.Time) { c.observe("PostComment", start, err) }(time.Now())
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -367,7 +421,7 @@ func (c *LinearClient) PostComment(issueID string, commentBody string) error {
 		} `json:"commentCreate"`
 	}
 
-	if err := c.executeQuery(ctx, mutationStr, &result); err != nil {
+	if err := c.executeQuery(ctx, "commentCreate", mutationStr, &result); err != nil {
 		return fmt.Errorf("failed to post comment: %w", err)
 	}
 
@@ -379,7 +433,9 @@ func (c *LinearClient) PostComment(issueID string, commentBody string) error {
 }
 
 // UpdateEstimate updates the estimate field on a Linear issue
-func (c *LinearClient) UpdateEstimate(issueID string, estimate int64) error {
+func (c *LinearClient) UpdateEstimate(issueID string, estimate int64) (err error) {
+	defer func(start time.Time) { c.observe("UpdateEstimate", start, err) }(time.Now())
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -407,7 +463,7 @@ func (c *LinearClient) UpdateEstimate(issueID string, estimate int64) error {
 		} `json:"issueUpdate"`
 	}
 
-	if err := c.executeQuery(ctx, mutationStr, &result); err != nil {
+	if err := c.executeQuery(ctx, "issueUpdate", mutationStr, &result); err != nil {
 		return fmt.Errorf("failed to update estimate: %w", err)
 	}
 
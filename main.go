@@ -4,11 +4,14 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/jcpsimmons/poker/config"
@@ -22,14 +25,19 @@ import (
 	"github.com/urfave/cli/v2"
 )
 
+// version is set at release time by GoReleaser's default ldflags
+var version string
+
 func main() {
 	app := &cli.App{
+		Version: version,
 		Commands: []*cli.Command{
 			{
 				Name:    "server",
 				Aliases: []string{"s"},
 				Usage:   "start a new planning poker instance",
-				Flags: []cli.Flag{
+				Before:  setupLogging,
+				Flags: append([]cli.Flag{
 					&cli.BoolFlag{
 						Name:    "announce",
 						Aliases: []string{"a"},
@@ -59,70 +67,150 @@ func main() {
 						Name:  "auth-password",
 						Usage: "Password for HTTP Basic Authentication (username: 'admin')",
 					},
-				},
+					&cli.StringFlag{
+						Name:  "deck",
+						Usage: "estimation deck: fibonacci, modified-fibonacci, powers-of-two, tshirt or a card list like \"S=1,M=3,L=5\" (default: config file, then fibonacci)",
+						Value: "",
+					},
+					&cli.BoolFlag{
+						Name:  "auto-reveal",
+						Usage: "reveal the votes automatically once every participant has voted (hosts can toggle it per room)",
+					},
+					&cli.DurationFlag{
+						Name:  "auto-reveal-delay",
+						Usage: "how long to wait after the last vote before auto-revealing",
+						Value: 3 * time.Second,
+					},
+					&cli.DurationFlag{
+						Name:  "host-recovery-timeout",
+						Usage: "how long a room waits for its last host to come back before promoting the longest-connected player (at least the resume grace period)",
+						Value: 60 * time.Second,
+					},
+					&cli.DurationFlag{
+						Name:  "ping-interval",
+						Usage: "how often to ping each client to detect dead connections",
+						Value: 25 * time.Second,
+					},
+					&cli.DurationFlag{
+						Name:  "pong-timeout",
+						Usage: "disconnect a client that hasn't been heard from, pongs included, for this long",
+						Value: 60 * time.Second,
+					},
+					&cli.DurationFlag{
+						Name:  "write-timeout",
+						Usage: "how long a single write to a client may take",
+						Value: 10 * time.Second,
+					},
+					&cli.Int64Flag{
+						Name:  "max-message-size",
+						Usage: "largest message in bytes a client may send",
+						Value: 64 * 1024,
+					},
+					&cli.IntFlag{
+						Name:  "send-queue-size",
+						Usage: "messages buffered per client before a client that can't keep up is disconnected",
+						Value: 256,
+					},
+					&cli.StringFlag{
+						Name:  "web-dir",
+						Usage: "serve the web app from this directory instead of the one built into the binary (e.g. web/dist while developing)",
+						Value: "",
+					},
+					&cli.BoolFlag{
+						Name:  "no-web",
+						Usage: "start without a web app, e.g. while the Vite dev server serves it",
+					},
+					&cli.StringFlag{
+						Name:  "data-dir",
+						Usage: "directory to snapshot session state into and restore it from on restart",
+						Value: "",
+					},
+					&cli.BoolFlag{
+						Name:  "metrics",
+						Usage: "serve Prometheus metrics at /metrics",
+					},
+					&cli.StringFlag{
+						Name:    "api-token",
+						Usage:   "enable the REST API at /api/rooms for requests sending this bearer token",
+						EnvVars: []string{"POKER_API_TOKEN"},
+					},
+				}, logFlags...),
 				Action: func(cCtx *cli.Context) error {
+					// Ctrl-C and SIGTERM shut the server down gracefully; a
+					// second signal kills it
+					ctx, stop := signal.NotifyContext(cCtx.Context, os.Interrupt, syscall.SIGTERM)
+					defer stop()
+					go func() {
+						<-ctx.Done()
+						stop()
+					}()
+
 					port := cCtx.String("port")
 					sessionName := cCtx.String("name")
-					linearCycleURL := cCtx.String("linear-cycle")
-					authPassword := cCtx.String("auth-password")
 
-					// Configure authentication if password provided
-					if authPassword != "" {
-						server.SetBasicAuth("admin", authPassword)
+					opts := server.Options{
+						AuthPassword:        cCtx.String("auth-password"),
+						Deck:                cCtx.String("deck"),
+						AutoReveal:          cCtx.Bool("auto-reveal"),
+						AutoRevealDelay:     cCtx.Duration("auto-reveal-delay"),
+						HostRecoveryTimeout: cCtx.Duration("host-recovery-timeout"),
+						PingInterval:        cCtx.Duration("ping-interval"),
+						PongTimeout:         cCtx.Duration("pong-timeout"),
+						WriteTimeout:        cCtx.Duration("write-timeout"),
+						MaxMessageSize:      cCtx.Int64("max-message-size"),
+						SendQueueSize:       cCtx.Int("send-queue-size"),
+						DataDir:             cCtx.String("data-dir"),
+						Metrics:             cCtx.Bool("metrics"),
+						APIToken:            cCtx.String("api-token"),
+						NoWebApp:            cCtx.Bool("no-web"),
+						Version:             version,
 					}
 
-					// Default session name to hostname if not provided
-					if sessionName == "" {
-						hostname, _ := os.Hostname()
-						sessionName = hostname
+					if webDir := cCtx.String("web-dir"); webDir != "" {
+						opts.Assets = http.Dir(webDir)
 					}
 
-					// Handle Linear integration if requested
-					if linearCycleURL != "" {
-						// Load config
-						cfg, err := config.Load()
-						if err != nil {
-							return fmt.Errorf("failed to load config: %w", err)
+					// The deck flag takes precedence over the config file
+					if opts.Deck == "" {
+						if cfg, err := config.Load(); err == nil {
+							opts.Deck = cfg.Deck
 						}
+					}
 
-						// Parse cycle URL
-						cycleInfo, err := linear.ParseCycleURL(linearCycleURL)
+					// Handle Linear integration if requested
+					linearCycleURL := cCtx.String("linear-cycle")
+					var cycleInfo *linear.CycleInfo
+					if linearCycleURL != "" {
+						client, cycle, err := newLinearClient(linearCycleURL)
 						if err != nil {
-							return fmt.Errorf("failed to parse cycle URL: %w", err)
+							return err
 						}
+						opts.Linear = client
+						cycleInfo = cycle
+					}
 
-						// Create Linear client
-						linearClient := linear.NewClient(cfg.Linear.APIKey)
+					srv, err := server.New(opts)
+					if err != nil {
+						return err
+					}
 
-						// Fetch issues
-						log.Println("Fetching Linear issues...")
-						linearIssues, err := linearClient.FetchCycleIssuesWithoutEstimates(cycleInfo)
+					// Fetched once the server counts the Linear calls
+					if opts.Linear != nil {
+						issues, err := fetchLinearIssues(opts.Linear, cycleInfo)
 						if err != nil {
-							return fmt.Errorf("failed to fetch Linear issues: %w", err)
+							return err
 						}
-
-						if len(linearIssues) == 0 {
-							log.Println("No unestimated issues found in cycle")
-						} else {
-							log.Printf("Found %d unestimated issue(s) in cycle", len(linearIssues))
-
-							// Convert to server format
-							issues := make([]types.LinearIssue, 0, len(linearIssues))
-							for _, li := range linearIssues {
-								issues = append(issues, types.LinearIssue{
-									ID:          li.ID,
-									Identifier:  li.Identifier,
-									Title:       li.Title,
-									Description: li.Description,
-									URL:         li.URL,
-								})
-							}
-
-							// Pass to server
-							server.SetLinearIssues(issues, linearClient)
+						if len(issues) > 0 {
+							srv.SetLinearIssues(issues, opts.Linear)
 						}
 					}
 
+					// Default session name to hostname if not provided
+					if sessionName == "" {
+						hostname, _ := os.Hostname()
+						sessionName = hostname
+					}
+
 					// Announce session if requested
 					if cCtx.Bool("announce") {
 						portInt, err := strconv.Atoi(port)
@@ -132,29 +220,26 @@ func main() {
 
 						tsIP, hasTS := discovery.GetTailscaleIPv4()
 						if hasTS {
-							log.Printf("Tailscale IP detected: %s", tsIP)
+							slog.Info("Tailscale IP detected", "ip", tsIP)
 						} else {
-							log.Println("Tailscale IP not detected - announcing will still work via mDNS")
+							slog.Info("Tailscale IP not detected - announcing will still work via mDNS")
 						}
 
 						shutdown, err := discovery.AnnounceSession(sessionName, portInt)
 						if err != nil {
-							log.Printf("Warning: Failed to announce session: %v", err)
+							slog.Warn("Failed to announce session", "err", err)
 						} else {
 							defer shutdown()
-							log.Printf("Announcing session '%s' on port %s", sessionName, port)
+							slog.Info("Announcing session", "session", sessionName, "port", port)
 						}
 					}
 
 					if cCtx.Bool("ngrok") {
-						// Serve via ngrok with a random URL each run.
-						// Handlers are registered on the default mux so we can pass nil as handler below.
-						server.RegisterHandlers()
-
+						// Serve via ngrok with a random URL each run
 						if os.Getenv("NGROK_AUTHTOKEN") == "" {
 							return fmt.Errorf("NGROK_AUTHTOKEN not set")
 						}
-						ln, err := ngrok.Listen(context.Background())
+						ln, err := ngrok.Listen(ctx)
 						if err != nil {
 							return fmt.Errorf("failed to start ngrok listener: %w", err)
 						}
@@ -162,17 +247,18 @@ func main() {
 						fmt.Printf("Public URL: %s\n", ln.URL())
 						wsURL := strings.Replace(ln.URL().String(), "https://", "wss://", 1)
 						fmt.Printf("WebSocket endpoint: %s/ws\n", wsURL)
-						return http.Serve(ln, nil)
+						return srv.Serve(ctx, ln)
 					}
 
-					server.Start(port)
-					return nil
+					return srv.Start(ctx, port)
 				},
 			},
 			{
 				Name:    "discover",
 				Aliases: []string{"d"},
 				Usage:   "discover available poker sessions",
+				Flags:   logFlags,
+				Before:  setupLogging,
 				Action: func(cCtx *cli.Context) error {
 					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 					defer cancel()
@@ -213,3 +299,85 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// logFlags configure the logs of every command
+var logFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "log-level",
+		Usage: "log level: debug, info, warn or error. Message payloads are only logged at debug",
+		Value: "info",
+	},
+	&cli.StringFlag{
+		Name:  "log-format",
+		Usage: "log format: text or json",
+		Value: "text",
+	},
+}
+
+// setupLogging installs the default logger described by --log-level and
+// --log-format. The server, Linear client and discovery all log to it.
+func setupLogging(cCtx *cli.Context) error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cCtx.String("log-level"))); err != nil {
+		return fmt.Errorf("invalid log level: %w", err)
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch format := cCtx.String("log-format"); format {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("invalid log format %q, expected text or json", format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// newLinearClient creates a Linear client with the API key from the config
+// file, for the cycle at cycleURL
+func newLinearClient(cycleURL string) (*linear.LinearClient, *linear.CycleInfo, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg.Linear.APIKey == "" {
+		return nil, nil, fmt.Errorf("linear.api_key is required in config file")
+	}
+
+	// Parse cycle URL
+	cycleInfo, err := linear.ParseCycleURL(cycleURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse cycle URL: %w", err)
+	}
+	return linear.NewClient(cfg.Linear.APIKey), cycleInfo, nil
+}
+
+// fetchLinearIssues loads the unestimated issues of a Linear cycle
+func fetchLinearIssues(linearClient *linear.LinearClient, cycleInfo *linear.CycleInfo) ([]types.LinearIssue, error) {
+	slog.Info("Fetching Linear issues", "team", cycleInfo.TeamKey, "cycle", cycleInfo.CycleType)
+	linearIssues, err := linearClient.FetchCycleIssuesWithoutEstimates(cycleInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Linear issues: %w", err)
+	}
+	if len(linearIssues) == 0 {
+		slog.Info("No unestimated issues found in cycle")
+		return nil, nil
+	}
+	slog.Info("Found unestimated issues in cycle", "issues", len(linearIssues))
+
+	// Convert to server format
+	issues := make([]types.LinearIssue, 0, len(linearIssues))
+	for _, li := range linearIssues {
+		issues = append(issues, types.LinearIssue{
+			ID:          li.ID,
+			Identifier:  li.Identifier,
+			Title:       li.Title,
+			Description: li.Description,
+			URL:         li.URL,
+		})
+	}
+	return issues, nil
+}
@@ -0,0 +1,360 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/jcpsimmons/poker/types"
+)
+
+// apiUser is the name API commands are logged under
+const apiUser = "api"
+
+// errAPIShutdown is returned to API requests once the server shuts down
+var errAPIShutdown = &CommandError{Code: types.CodeUnavailable, Err: errors.New(shutdownReason)}
+
+// apiStatus maps error codes to the HTTP status API requests fail with.
+// Codes that aren't listed are a 400.
+var apiStatus = map[types.ErrorCode]int{
+	types.CodeUnauthorized:  http.StatusUnauthorized,
+	types.CodeForbidden:     http.StatusForbidden,
+	types.CodeNotFound:      http.StatusNotFound,
+	types.CodeInvalidRole:   http.StatusConflict,
+	types.CodeInvalidTarget: http.StatusConflict,
+	types.CodeStale:         http.StatusConflict,
+	types.CodeUnavailable:   http.StatusConflict,
+	types.CodeUpstream:      http.StatusBadGateway,
+}
+
+// sessionResponse is the JSON body of GET /api/rooms/{room}
+type sessionResponse struct {
+	Room         string                    `json:"room"`
+	Issue        types.CurrentIssuePayload `json:"issue"`
+	Phase        types.RoundPhase          `json:"phase"`
+	Round        int                       `json:"round"`
+	Reveal       *types.RevealPayload      `json:"reveal,omitempty"` // Results while the round is revealed
+	Participants []apiParticipant          `json:"participants"`
+	Queue        []types.QueueItem         `json:"queue"`
+}
+
+// apiParticipant is a joined participant as the API shows them. Votes
+// stay hidden until the round is revealed.
+type apiParticipant struct {
+	Username string `json:"username"`
+	Role     Role   `json:"role"`
+	HasVoted bool   `json:"hasVoted"`
+}
+
+// registerAPI serves the REST API, which runs the host's commands for
+// scripts and bots that don't speak the WebSocket protocol
+func (s *Server) registerAPI() {
+	s.mux.HandleFunc("GET /api/rooms/{room}", s.apiAuthMiddleware(s.handleAPISession))
+	s.mux.HandleFunc("POST /api/rooms/{room}/queue", s.apiAuthMiddleware(s.handleAPIQueueAdd))
+	s.mux.HandleFunc("PATCH /api/rooms/{room}/queue/{id}", s.apiAuthMiddleware(s.handleAPIQueueUpdate))
+	s.mux.HandleFunc("DELETE /api/rooms/{room}/queue/{id}", s.apiAuthMiddleware(s.handleAPIQueueDelete))
+	s.mux.HandleFunc("PUT /api/rooms/{room}/queue/order", s.apiAuthMiddleware(s.handleAPIQueueReorder))
+	s.mux.HandleFunc("POST /api/rooms/{room}/reveal", s.apiAuthMiddleware(s.handleAPIReveal))
+	s.mux.HandleFunc("POST /api/rooms/{room}/reset", s.apiAuthMiddleware(s.handleAPIReset))
+	s.mux.HandleFunc("POST /api/rooms/{room}/assign-estimate", s.apiAuthMiddleware(s.handleAPIAssignEstimate))
+}
+
+// apiAuthMiddleware checks the API token, sent as "Authorization: Bearer <token>"
+func (s *Server) apiAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.apiToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="Planning Poker"`)
+			writeAPIError(w, &CommandError{Code: types.CodeUnauthorized, Err: errors.New("missing or invalid API token")})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// runAPICommand runs fn on the room's event loop as a host, the way a
+// host's WebSocket command is run, and saves the room afterwards. Only
+// commands that may open the room run in rooms that aren't open, so queues
+// can be prepared before anybody joins. A room nobody is in is held open
+// for the resume grace period after each command.
+func (s *Server) runAPICommand(slug string, messageType types.MessageType, open bool, fn func(r *Room, host *Client) error) error {
+	// The room closes if its last participant leaves before fn runs, in
+	// which case it is opened again or not found
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.closed.Load() {
+			return errAPIShutdown
+		}
+		var room *Room
+		if open {
+			room = s.openRoom(slug)
+		} else if room = s.getRoom(slug); room == nil {
+			return errAPIRoomNotFound(slug)
+		}
+		host := &Client{server: s, Room: room, UserID: apiUser, IsHost: true, api: true}
+
+		var err error
+		ran := room.do(func() {
+			room.log.Debug("API command received", "user", apiUser, "type", messageType)
+			if permissionErr := room.authorize(host, messageType); permissionErr != nil {
+				err = permissionErr
+			} else {
+				err = fn(room, host)
+			}
+			room.mutex.Lock()
+			room.holdIdleUnlocked()
+			room.mutex.Unlock()
+			if err != nil {
+				room.log.Warn("Rejected command", "user", apiUser, "type", messageType, "err", err)
+				return
+			}
+			if persistedMessageTypes[messageType] {
+				room.persist()
+			}
+		})
+		if ran {
+			return err
+		}
+	}
+	return errAPIShutdown
+}
+
+// session returns the state of the room as the API shows it
+func (r *Room) session() sessionResponse {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	participants := make([]apiParticipant, 0, len(r.clients))
+	for client := range r.clients {
+		if client.UserID == "" {
+			continue
+		}
+		participants = append(participants, apiParticipant{
+			Username: client.UserID,
+			Role:     client.role(),
+			HasVoted: client.isVoter() && client.CurrentEstimate.Voted(),
+		})
+	}
+	sort.Slice(participants, func(i, j int) bool {
+		return participants[i].Username < participants[j].Username
+	})
+
+	return sessionResponse{
+		Room: r.Slug,
+		Issue: types.CurrentIssuePayload{
+			Text:        r.currentIssue,
+			LinearIssue: r.currentLinearIssue,
+		},
+		Phase:        r.phase,
+		Round:        r.round,
+		Reveal:       r.lastReveal,
+		Participants: participants,
+		Queue:        append([]types.QueueItem{}, r.queueItems...),
+	}
+}
+
+// queueItem returns the queue item with the given ID
+func (r *Room) queueItem(id string) (types.QueueItem, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, item := range r.queueItems {
+		if item.ID == id {
+			return item, true
+		}
+	}
+	return types.QueueItem{}, false
+}
+
+// errAPIRoomNotFound is returned for rooms that aren't open
+func errAPIRoomNotFound(slug string) error {
+	return &CommandError{Code: types.CodeNotFound, Err: fmt.Errorf("room %q not found", slug)}
+}
+
+// apiRoomSlug returns the room named in the request path, answering with
+// an error if it is invalid
+func apiRoomSlug(w http.ResponseWriter, req *http.Request) (string, bool) {
+	slug, err := normalizeRoomSlug(req.PathValue("room"))
+	if err != nil {
+		writeAPIError(w, &CommandError{Code: types.CodeBadRequest, Err: err})
+		return "", false
+	}
+	return slug, true
+}
+
+// decodeAPIBody decodes the JSON request body into v, answering with an
+// error if it can't
+func (s *Server) decodeAPIBody(w http.ResponseWriter, req *http.Request, v interface{}) bool {
+	body := http.MaxBytesReader(w, req.Body, s.maxMessageSize.Load())
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		writeAPIError(w, &CommandError{Code: types.CodeBadRequest, Err: fmt.Errorf("invalid request body: %w", err)})
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeAPIError answers with the error payload a WebSocket client would
+// get, and the HTTP status closest to its code
+func writeAPIError(w http.ResponseWriter, err error) {
+	payload := errorPayload(err, "")
+	status, ok := apiStatus[payload.Code]
+	if !ok {
+		status = http.StatusBadRequest
+	}
+	if errors.Is(err, errAPIShutdown) {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, payload)
+}
+
+// handleAPISession serves GET /api/rooms/{room}
+func (s *Server) handleAPISession(w http.ResponseWriter, req *http.Request) {
+	slug, ok := apiRoomSlug(w, req)
+	if !ok {
+		return
+	}
+	room := s.getRoom(slug)
+	if room == nil {
+		writeAPIError(w, errAPIRoomNotFound(slug))
+		return
+	}
+	writeJSON(w, http.StatusOK, room.session())
+}
+
+// handleAPIQueueAdd serves POST /api/rooms/{room}/queue, answering with the
+// new item. It opens the room if nobody has joined it yet.
+func (s *Server) handleAPIQueueAdd(w http.ResponseWriter, req *http.Request) {
+	slug, ok := apiRoomSlug(w, req)
+	if !ok {
+		return
+	}
+	var payload types.QueueAddPayload
+	if !s.decodeAPIBody(w, req, &payload) {
+		return
+	}
+	if payload.Identifier == "" && payload.Title == "" {
+		writeAPIError(w, &CommandError{Code: types.CodeBadRequest, Err: errors.New("an identifier or a title is required")})
+		return
+	}
+
+	var item types.QueueItem
+	err := s.runAPICommand(slug, types.MessageQueueAdd, true, func(r *Room, host *Client) error {
+		item = r.handleQueueAdd(payload, host)
+		return nil
+	})
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, item)
+}
+
+// handleAPIQueueUpdate serves PATCH /api/rooms/{room}/queue/{id}, answering
+// with the updated item. Fields left out are kept.
+func (s *Server) handleAPIQueueUpdate(w http.ResponseWriter, req *http.Request) {
+	slug, ok := apiRoomSlug(w, req)
+	if !ok {
+		return
+	}
+	var payload types.QueueUpdatePayload
+	if !s.decodeAPIBody(w, req, &payload) {
+		return
+	}
+	payload.ID = req.PathValue("id")
+
+	var item types.QueueItem
+	err := s.runAPICommand(slug, types.MessageQueueUpdate, false, func(r *Room, host *Client) error {
+		if err := r.handleQueueUpdate(payload, host); err != nil {
+			return err
+		}
+		item, _ = r.queueItem(payload.ID)
+		return nil
+	})
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+
+// handleAPIQueueDelete serves DELETE /api/rooms/{room}/queue/{id}
+func (s *Server) handleAPIQueueDelete(w http.ResponseWriter, req *http.Request) {
+	slug, ok := apiRoomSlug(w, req)
+	if !ok {
+		return
+	}
+	payload := types.QueueDeletePayload{ID: req.PathValue("id")}
+	err := s.runAPICommand(slug, types.MessageQueueDelete, false, func(r *Room, host *Client) error {
+		return r.handleQueueDelete(payload, host)
+	})
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAPIQueueReorder serves PUT /api/rooms/{room}/queue/order with the
+// item IDs in their new order, answering with the session
+func (s *Server) handleAPIQueueReorder(w http.ResponseWriter, req *http.Request) {
+	var payload types.QueueReorderPayload
+	if !s.decodeAPIBody(w, req, &payload) {
+		return
+	}
+	s.serveAPICommand(w, req, types.MessageQueueReorder, func(r *Room, host *Client) error {
+		r.handleQueueReorder(payload, host)
+		return nil
+	})
+}
+
+// handleAPIReveal serves POST /api/rooms/{room}/reveal
+func (s *Server) handleAPIReveal(w http.ResponseWriter, req *http.Request) {
+	s.serveAPICommand(w, req, types.Reveal, func(r *Room, host *Client) error {
+		return r.revealRound(host)
+	})
+}
+
+// handleAPIReset serves POST /api/rooms/{room}/reset
+func (s *Server) handleAPIReset(w http.ResponseWriter, req *http.Request) {
+	s.serveAPICommand(w, req, types.Reset, func(r *Room, host *Client) error {
+		r.resetRound(host)
+		return nil
+	})
+}
+
+// handleAPIAssignEstimate serves POST /api/rooms/{room}/assign-estimate
+func (s *Server) handleAPIAssignEstimate(w http.ResponseWriter, req *http.Request) {
+	s.serveAPICommand(w, req, types.MessageAssignEstimate, func(r *Room, host *Client) error {
+		return r.handleAssignEstimate(host)
+	})
+}
+
+// serveAPICommand runs a command in the room named in the request path and
+// answers with the session it leaves behind
+func (s *Server) serveAPICommand(w http.ResponseWriter, req *http.Request, messageType types.MessageType, fn func(r *Room, host *Client) error) {
+	slug, ok := apiRoomSlug(w, req)
+	if !ok {
+		return
+	}
+	var session sessionResponse
+	err := s.runAPICommand(slug, messageType, false, func(r *Room, host *Client) error {
+		if err := fn(r, host); err != nil {
+			return err
+		}
+		session = r.session()
+		return nil
+	})
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, session)
+}
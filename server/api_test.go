@@ -0,0 +1,313 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jcpsimmons/poker/types"
+	"github.com/stretchr/testify/require"
+)
+
+const testAPIToken = "s3cret-token"
+
+// apiRequest sends an API request with the test token and returns the
+// response status and body
+func apiRequest(t *testing.T, serverURL, method, path string, body interface{}) (int, []byte) {
+	return apiRequestWithToken(t, serverURL, method, path, testAPIToken, body)
+}
+
+func apiRequestWithToken(t *testing.T, serverURL, method, path, token string, body interface{}) (int, []byte) {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequest(method, serverURL+path, reader)
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, respBody
+}
+
+// getSession fetches the room's session through the API
+func getSession(t *testing.T, serverURL, room string) sessionResponse {
+	status, body := apiRequest(t, serverURL, http.MethodGet, "/api/rooms/"+room, nil)
+	require.Equal(t, http.StatusOK, status, string(body))
+	var session sessionResponse
+	require.NoError(t, json.Unmarshal(body, &session))
+	return session
+}
+
+func queueTitles(items []types.QueueItem) []string {
+	titles := make([]string, 0, len(items))
+	for _, item := range items {
+		titles = append(titles, item.Title)
+	}
+	return titles
+}
+
+func TestAPI_TokenAuth(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+	}{
+		{name: "no token", token: "", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", token: "guess", wantStatus: http.StatusUnauthorized},
+		{name: "valid token", token: testAPIToken, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ts := newInstanceTestServer(t, Options{APIToken: testAPIToken, AuthPassword: "secret"})
+
+			status, body := apiRequestWithToken(t, ts.URL, http.MethodGet, "/api/rooms/default", tt.token, nil)
+			require.Equal(t, tt.wantStatus, status, string(body))
+			if tt.wantStatus == http.StatusUnauthorized {
+				var payload types.ErrorPayload
+				require.NoError(t, json.Unmarshal(body, &payload))
+				require.Equal(t, types.CodeUnauthorized, payload.Code)
+			}
+		})
+	}
+}
+
+func TestAPI_DisabledWithoutToken(t *testing.T) {
+	srv, ts := newInstanceTestServer(t, Options{})
+
+	status, _ := apiRequest(t, ts.URL, http.MethodPost, "/api/rooms/prep/queue", types.QueueAddPayload{Title: "Login page"})
+	require.NotEqual(t, http.StatusCreated, status)
+	require.Nil(t, srv.getRoom("prep"), "a disabled API must not open rooms")
+}
+
+func TestAPI_PrepareQueueBeforeMeeting(t *testing.T) {
+	_, ts := newInstanceTestServer(t, Options{APIToken: testAPIToken})
+
+	// Nobody has joined yet, so the room doesn't exist
+	status, _ := apiRequest(t, ts.URL, http.MethodGet, "/api/rooms/prep", nil)
+	require.Equal(t, http.StatusNotFound, status)
+
+	var items []types.QueueItem
+	for _, title := range []string{"Login page", "Signup form", "Password reset"} {
+		status, body := apiRequest(t, ts.URL, http.MethodPost, "/api/rooms/prep/queue", types.QueueAddPayload{Identifier: "T", Title: title})
+		require.Equal(t, http.StatusCreated, status, string(body))
+		var item types.QueueItem
+		require.NoError(t, json.Unmarshal(body, &item))
+		require.NotEmpty(t, item.ID)
+		require.Equal(t, "custom", item.Source)
+		items = append(items, item)
+	}
+
+	status, body := apiRequest(t, ts.URL, http.MethodPatch, "/api/rooms/prep/queue/"+items[1].ID, types.QueueUpdatePayload{Title: "Signup form v2"})
+	require.Equal(t, http.StatusOK, status, string(body))
+	var updated types.QueueItem
+	require.NoError(t, json.Unmarshal(body, &updated))
+	require.Equal(t, "Signup form v2", updated.Title)
+	require.Equal(t, "T", updated.Identifier, "fields left out are kept")
+
+	status, body = apiRequest(t, ts.URL, http.MethodPut, "/api/rooms/prep/queue/order",
+		types.QueueReorderPayload{ItemIDs: []string{items[2].ID, items[0].ID, items[1].ID}})
+	require.Equal(t, http.StatusOK, status, string(body))
+
+	status, _ = apiRequest(t, ts.URL, http.MethodDelete, "/api/rooms/prep/queue/"+items[0].ID, nil)
+	require.Equal(t, http.StatusNoContent, status)
+
+	session := getSession(t, ts.URL, "prep")
+	require.Equal(t, "prep", session.Room)
+	require.Equal(t, types.RoundIdle, session.Phase)
+	require.Empty(t, session.Participants)
+	require.Equal(t, []string{"Password reset", "Signup form v2"}, queueTitles(session.Queue))
+
+	// The host finds the queue ready when they join
+	host := joinTestRoom(t, ts.URL, "prep", "Alice", true)
+	defer host.Close()
+	sync := lastMessage(host.welcome, types.MessageQueueSync)
+	require.NotNil(t, sync)
+	require.Len(t, sync["payload"].(map[string]interface{})["items"], 2)
+
+	// And sees later changes live
+	status, _ = apiRequest(t, ts.URL, http.MethodPost, "/api/rooms/prep/queue", types.QueueAddPayload{Title: "Profile page"})
+	require.Equal(t, http.StatusCreated, status)
+	sync = lastMessage(host.drain(), types.MessageQueueSync)
+	require.NotNil(t, sync)
+	require.Len(t, sync["payload"].(map[string]interface{})["items"], 3)
+}
+
+func TestAPI_UnknownRoom(t *testing.T) {
+	srv, ts := newInstanceTestServer(t, Options{APIToken: testAPIToken})
+
+	tests := []struct {
+		method string
+		path   string
+		body   interface{}
+	}{
+		{method: http.MethodGet, path: "/api/rooms/nowhere"},
+		{method: http.MethodPatch, path: "/api/rooms/nowhere/queue/1", body: types.QueueUpdatePayload{Title: "New title"}},
+		{method: http.MethodDelete, path: "/api/rooms/nowhere/queue/1"},
+		{method: http.MethodPut, path: "/api/rooms/nowhere/queue/order", body: types.QueueReorderPayload{}},
+		{method: http.MethodPost, path: "/api/rooms/nowhere/reveal"},
+		{method: http.MethodPost, path: "/api/rooms/nowhere/reset"},
+		{method: http.MethodPost, path: "/api/rooms/nowhere/assign-estimate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			status, body := apiRequest(t, ts.URL, tt.method, tt.path, tt.body)
+			require.Equal(t, http.StatusNotFound, status, string(body))
+			var payload types.ErrorPayload
+			require.NoError(t, json.Unmarshal(body, &payload))
+			require.Equal(t, types.CodeNotFound, payload.Code)
+			require.Nil(t, srv.getRoom("nowhere"), "only adding to the queue opens rooms")
+		})
+	}
+}
+
+func TestAPI_EmptyRoomCloses(t *testing.T) {
+	srv, ts := newInstanceTestServer(t, Options{APIToken: testAPIToken, ResumeGracePeriod: 100 * time.Millisecond})
+
+	status, _ := apiRequest(t, ts.URL, http.MethodPost, "/api/rooms/prep/queue", types.QueueAddPayload{Title: "Login page"})
+	require.Equal(t, http.StatusCreated, status)
+	require.NotNil(t, srv.getRoom("prep"))
+
+	// The queue is there for whoever joins, and the room closes after they leave
+	client := joinTestRoom(t, ts.URL, "prep", "Alice", true)
+	require.Len(t, lastMessage(client.welcome, types.MessageQueueSync)["payload"].(map[string]interface{})["items"], 1)
+	client.Close()
+
+	require.Eventually(t, func() bool {
+		return srv.getRoom("prep") == nil
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestAPI_RevealAndReset(t *testing.T) {
+	_, ts := newInstanceTestServer(t, Options{APIToken: testAPIToken})
+
+	host := joinTestRoom(t, ts.URL, "api", "Alice", true)
+	defer host.Close()
+	player := joinTestRoom(t, ts.URL, "api", "Bob", false)
+	defer player.Close()
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.NewIssue, Payload: "Login page"}))
+	require.NoError(t, player.WriteJSON(types.Message{Type: types.Estimate, Payload: "5"}))
+	host.drain()
+	player.drain()
+
+	session := getSession(t, ts.URL, "api")
+	require.Equal(t, types.RoundVoting, session.Phase)
+	require.Equal(t, "Login page", session.Issue.Text)
+	require.Equal(t, []apiParticipant{
+		{Username: "Alice", Role: RoleHost},
+		{Username: "Bob", Role: RolePlayer, HasVoted: true},
+	}, session.Participants)
+	require.Nil(t, session.Reveal, "votes stay hidden until revealed")
+
+	status, body := apiRequest(t, ts.URL, http.MethodPost, "/api/rooms/api/reveal", nil)
+	require.Equal(t, http.StatusOK, status, string(body))
+	require.NoError(t, json.Unmarshal(body, &session))
+	require.Equal(t, types.RoundRevealed, session.Phase)
+	require.NotNil(t, session.Reveal)
+	require.Equal(t, "5", session.Reveal.PointAvg)
+	require.NotNil(t, findMessage(player.drain(), types.RevealData))
+
+	// A revealed round can't be revealed again
+	status, body = apiRequest(t, ts.URL, http.MethodPost, "/api/rooms/api/reveal", nil)
+	require.Equal(t, http.StatusConflict, status, string(body))
+
+	status, body = apiRequest(t, ts.URL, http.MethodPost, "/api/rooms/api/reset", nil)
+	require.Equal(t, http.StatusOK, status, string(body))
+	require.NoError(t, json.Unmarshal(body, &session))
+	require.Equal(t, types.RoundClosed, session.Phase)
+	require.Empty(t, session.Issue.Text)
+	require.False(t, session.Participants[1].HasVoted)
+	require.NotNil(t, findMessage(player.drain(), types.ClearBoard))
+}
+
+func TestAPI_Errors(t *testing.T) {
+	srv, ts := newInstanceTestServer(t, Options{APIToken: testAPIToken})
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       interface{}
+		wantStatus int
+		wantCode   types.ErrorCode
+	}{
+		{
+			name:       "invalid room",
+			method:     http.MethodPost,
+			path:       "/api/rooms/Bad%20Room!/reveal",
+			wantStatus: http.StatusBadRequest,
+			wantCode:   types.CodeBadRequest,
+		},
+		{
+			name:       "invalid body",
+			method:     http.MethodPost,
+			path:       "/api/rooms/default/queue",
+			body:       "not an object",
+			wantStatus: http.StatusBadRequest,
+			wantCode:   types.CodeBadRequest,
+		},
+		{
+			name:       "empty queue item",
+			method:     http.MethodPost,
+			path:       "/api/rooms/default/queue",
+			body:       types.QueueAddPayload{},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   types.CodeBadRequest,
+		},
+		{
+			name:       "unknown queue item",
+			method:     http.MethodPatch,
+			path:       "/api/rooms/default/queue/missing",
+			body:       types.QueueUpdatePayload{Title: "New title"},
+			wantStatus: http.StatusNotFound,
+			wantCode:   types.CodeNotFound,
+		},
+		{
+			name:       "delete unknown queue item",
+			method:     http.MethodDelete,
+			path:       "/api/rooms/default/queue/missing",
+			wantStatus: http.StatusNotFound,
+			wantCode:   types.CodeNotFound,
+		},
+		{
+			name:       "reveal without a round",
+			method:     http.MethodPost,
+			path:       "/api/rooms/default/reveal",
+			wantStatus: http.StatusConflict,
+			wantCode:   types.CodeUnavailable,
+		},
+		{
+			name:       "assign estimate without Linear",
+			method:     http.MethodPost,
+			path:       "/api/rooms/default/assign-estimate",
+			wantStatus: http.StatusConflict,
+			wantCode:   types.CodeUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := apiRequest(t, ts.URL, tt.method, tt.path, tt.body)
+			require.Equal(t, tt.wantStatus, status, string(body))
+			var payload types.ErrorPayload
+			require.NoError(t, json.Unmarshal(body, &payload))
+			require.Equal(t, tt.wantCode, payload.Code)
+			require.NotEmpty(t, payload.Message)
+		})
+	}
+
+	t.Run("shutting down", func(t *testing.T) {
+		srv.Close()
+		status, body := apiRequest(t, ts.URL, http.MethodPost, "/api/rooms/default/reveal", nil)
+		require.Equal(t, http.StatusServiceUnavailable, status, string(body))
+	})
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"errors"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/jcpsimmons/poker/web"
+)
+
+// Vite puts a content hash in the name of every file under /assets, so a
+// changed file always gets a new URL and browsers may keep them forever.
+// Anything else, index.html included, is revalidated on each load.
+const (
+	hashedAssetsPrefix = "/assets/"
+	immutableCache     = "public, max-age=31536000, immutable"
+	revalidateCache    = "no-cache"
+)
+
+// precompressed lists the variants the build writes next to each text
+// file, in order of preference
+var precompressed = []struct {
+	encoding  string
+	extension string
+}{
+	{encoding: "br", extension: ".br"},
+	{encoding: "gzip", extension: ".gz"},
+}
+
+// errNoWebApp stops Start and Serve when there is no web app to serve, e.g. in a
+// binary built from a clean checkout without building the web app first
+var errNoWebApp = errors.New("the web app isn't built into this binary (web/dist has no index.html): build it with ./build.sh, serve a built copy with --web-dir, or pass --no-web to serve only the WebSocket")
+
+// embeddedAssets returns the web app built into the binary
+func embeddedAssets() http.FileSystem {
+	return http.FS(web.Dist())
+}
+
+// hasIndex reports whether assets contains a web app
+func hasIndex(assets http.FileSystem) bool {
+	f, err := assets.Open("/index.html")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return true
+}
+
+// checkWebApp refuses to serve without a web app unless Options.NoWebApp
+// is set, rather than answering / with a 404
+func (s *Server) checkWebApp() error {
+	if !s.noWebApp && !hasIndex(s.assets) {
+		return errNoWebApp
+	}
+	return nil
+}
+
+// assetHandler serves the web app in assets. Paths that aren't files get
+// index.html, so client-side routes survive a reload, unless they look
+// like a file (have an extension), in which case they are a 404 rather
+// than a page of HTML in place of a script.
+func assetHandler(assets http.FileSystem) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		name := path.Clean("/" + r.URL.Path)
+		if name == "/" {
+			name = "/index.html"
+		}
+		f, err := openFile(assets, name)
+		if errors.Is(err, fs.ErrNotExist) && path.Ext(name) == "" {
+			name = "/index.html"
+			f, err = openFile(assets, name)
+		}
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				http.NotFound(w, r)
+				return
+			}
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+
+		if strings.HasPrefix(name, hashedAssetsPrefix) {
+			w.Header().Set("Cache-Control", immutableCache)
+		} else {
+			w.Header().Set("Cache-Control", revalidateCache)
+		}
+		serveFile(w, r, assets, name, f)
+	})
+}
+
+// openFile opens the file at name, treating directories as missing
+func openFile(assets http.FileSystem, name string) (http.File, error) {
+	f, err := assets.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, fs.ErrNotExist
+	}
+	return f, nil
+}
+
+// serveFile writes f, or its precompressed variant if the client accepts
+// one. Either way the response is typed after the original file.
+func serveFile(w http.ResponseWriter, r *http.Request, assets http.FileSystem, name string, f http.File) {
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+		w.Header().Set("Content-Type", ctype)
+	}
+
+	accept := r.Header.Get("Accept-Encoding")
+	for _, variant := range precompressed {
+		compressed, err := openFile(assets, name+variant.extension)
+		if err != nil {
+			continue
+		}
+		defer compressed.Close()
+
+		// Caches must keep the variants apart, even for clients that
+		// get the plain file
+		w.Header().Set("Vary", "Accept-Encoding")
+		if !acceptsEncoding(accept, variant.encoding) {
+			continue
+		}
+		w.Header().Set("Content-Encoding", variant.encoding)
+		http.ServeContent(w, r, name, info.ModTime(), compressed)
+		return
+	}
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header allows the
+// given content coding
+func acceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.TrimSpace(coding)
+		if !strings.EqualFold(coding, encoding) && coding != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testAssets() http.FileSystem {
+	return http.FS(fstest.MapFS{
+		"index.html":              {Data: []byte("<h1>poker</h1>")},
+		"vite.svg":                {Data: []byte("<svg></svg>")},
+		"assets/index-a1b2.js":    {Data: []byte("console.log('plain')")},
+		"assets/index-a1b2.js.br": {Data: []byte("brotli")},
+		"assets/index-a1b2.js.gz": {Data: []byte("gzip")},
+	})
+}
+
+func TestAssetHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		wantStatus     int
+		wantBody       string
+		wantType       string
+		wantEncoding   string
+		wantCache      string
+	}{
+		{name: "index", path: "/", wantStatus: http.StatusOK, wantBody: "<h1>poker</h1>", wantType: "text/html; charset=utf-8", wantCache: revalidateCache},
+		{name: "client-side route", path: "/rooms/squad-a", wantStatus: http.StatusOK, wantBody: "<h1>poker</h1>", wantType: "text/html; charset=utf-8", wantCache: revalidateCache},
+		{name: "unhashed file", path: "/vite.svg", wantStatus: http.StatusOK, wantBody: "<svg></svg>", wantType: "image/svg+xml", wantCache: revalidateCache},
+		{name: "hashed asset", path: "/assets/index-a1b2.js", wantStatus: http.StatusOK, wantBody: "console.log('plain')", wantType: "text/javascript; charset=utf-8", wantCache: immutableCache},
+		{name: "brotli preferred", path: "/assets/index-a1b2.js", acceptEncoding: "gzip, deflate, br", wantStatus: http.StatusOK, wantBody: "brotli", wantType: "text/javascript; charset=utf-8", wantEncoding: "br", wantCache: immutableCache},
+		{name: "gzip", path: "/assets/index-a1b2.js", acceptEncoding: "gzip", wantStatus: http.StatusOK, wantBody: "gzip", wantType: "text/javascript; charset=utf-8", wantEncoding: "gzip", wantCache: immutableCache},
+		{name: "brotli refused", path: "/assets/index-a1b2.js", acceptEncoding: "br;q=0, gzip;q=0.5", wantStatus: http.StatusOK, wantBody: "gzip", wantType: "text/javascript; charset=utf-8", wantEncoding: "gzip", wantCache: immutableCache},
+		{name: "missing file", path: "/assets/gone-ffff.js", wantStatus: http.StatusNotFound},
+		{name: "directory", path: "/assets", wantStatus: http.StatusOK, wantBody: "<h1>poker</h1>", wantType: "text/html; charset=utf-8", wantCache: revalidateCache},
+	}
+	handler := assetHandler(testAssets())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			require.Equal(t, tt.wantBody, w.Body.String())
+			require.Equal(t, tt.wantType, w.Header().Get("Content-Type"))
+			require.Equal(t, tt.wantEncoding, w.Header().Get("Content-Encoding"))
+			require.Equal(t, tt.wantCache, w.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func TestAssetHandler_VariesOnlyWhenCompressed(t *testing.T) {
+	handler := assetHandler(testAssets())
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/assets/index-a1b2.js", nil))
+	require.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/vite.svg", nil))
+	require.Empty(t, w.Header().Get("Vary"))
+}
+
+func TestAssetHandler_NoApp(t *testing.T) {
+	assets := http.FS(fstest.MapFS{".gitkeep": {}})
+	require.False(t, hasIndex(assets))
+	require.True(t, hasIndex(testAssets()))
+
+	w := httptest.NewRecorder()
+	assetHandler(assets).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	s, err := New(Options{Assets: assets})
+	require.NoError(t, err)
+	require.ErrorIs(t, s.Start(context.Background(), "0"), errNoWebApp)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	require.ErrorIs(t, s.Serve(context.Background(), ln), errNoWebApp)
+	_, err = net.Dial("tcp", ln.Addr().String())
+	require.Error(t, err, "the listener is closed")
+
+	// Unless the app is served from elsewhere
+	s, err = New(Options{Assets: assets, NoWebApp: true})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.NoError(t, s.Start(ctx, "0"))
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"time"
+
+	"github.com/jcpsimmons/poker/messaging"
+	"github.com/jcpsimmons/poker/types"
+)
+
+// defaultAutoRevealDelay gives the last voter a moment to change their mind
+const defaultAutoRevealDelay = 3 * time.Second
+
+// SetAutoReveal configures whether rooms reveal the votes on their own once
+// every participant has voted, and how long they wait before doing so.
+// Hosts can still toggle it per room.
+func SetAutoReveal(enabled bool, delay time.Duration) {
+	defaultServer().setAutoReveal(enabled, delay)
+}
+
+func (s *Server) setAutoReveal(enabled bool, delay time.Duration) {
+	if delay < 0 {
+		delay = 0
+	}
+
+	s.roomsMutex.Lock()
+	defer s.roomsMutex.Unlock()
+
+	s.autoRevealEnabled = enabled
+	s.autoRevealDelay = delay
+	for _, room := range s.rooms {
+		room.mutex.Lock()
+		room.autoReveal = enabled
+		room.autoRevealDelay = delay
+		room.mutex.Unlock()
+	}
+	s.log.Info("Auto-reveal configured", "enabled", enabled, "delay", delay)
+}
+
+// allVotedUnlocked reports whether every joined participant has a vote.
+// Observers don't count towards the quorum (caller must hold mutex).
+func (r *Room) allVotedUnlocked() bool {
+	voters := 0
+	for client := range r.clients {
+		if !client.isVoter() {
+			continue
+		}
+		if !client.CurrentEstimate.Voted() {
+			return false
+		}
+		voters++
+	}
+	return voters > 0
+}
+
+// stopAutoRevealUnlocked cancels a pending auto-reveal (caller must hold mutex)
+func (r *Room) stopAutoRevealUnlocked() {
+	if r.autoRevealTimer == nil {
+		return
+	}
+	r.autoRevealTimer.Stop()
+	r.autoRevealTimer = nil
+	r.autoRevealGeneration++
+}
+
+// checkAutoReveal schedules the reveal once everybody has voted, and
+// cancels a pending one when somebody without a vote turns up
+func (r *Room) checkAutoReveal() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.autoReveal || !r.allVotedUnlocked() {
+		r.stopAutoRevealUnlocked()
+		return
+	}
+	if r.autoRevealTimer != nil {
+		return
+	}
+
+	generation := r.autoRevealGeneration
+	r.autoRevealTimer = time.AfterFunc(r.autoRevealDelay, func() {
+		r.do(func() { r.fireAutoReveal(generation) })
+	})
+	r.log.Info("Everybody has voted, auto-revealing", "delay", r.autoRevealDelay)
+}
+
+// fireAutoReveal reveals the round if everybody still has a vote once the
+// delay has passed
+func (r *Room) fireAutoReveal(generation int) {
+	r.mutex.Lock()
+	if r.autoRevealTimer == nil || r.autoRevealGeneration != generation {
+		r.mutex.Unlock()
+		return
+	}
+	r.autoRevealTimer = nil
+	r.autoRevealGeneration++
+	ready := r.autoReveal && r.allVotedUnlocked() && r.phase == types.RoundVoting
+	r.mutex.Unlock()
+
+	if !ready {
+		return
+	}
+	r.log.Info("Auto-revealing round")
+	if r.revealRound(nil) == nil {
+		r.persist()
+	}
+}
+
+// handleSetAutoReveal lets a host turn auto-reveal on or off for the room
+func (r *Room) handleSetAutoReveal(payload types.AutoRevealPayload, sender *Client) {
+	r.mutex.Lock()
+	r.autoReveal = payload.Enabled
+	message := r.roundStateMessageUnlocked()
+	r.mutex.Unlock()
+
+	r.log.Info("Host set auto-reveal", "user", sender.UserID, "enabled", payload.Enabled)
+	r.broadcast(messaging.MarshallMessage(message), sender)
+	r.checkAutoReveal()
+}
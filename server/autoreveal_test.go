@@ -0,0 +1,110 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jcpsimmons/poker/types"
+	"github.com/stretchr/testify/require"
+)
+
+// setRoomAutoReveal toggles auto-reveal through the host message
+func setRoomAutoReveal(t *testing.T, host *roomTestClient, enabled bool) {
+	require.NoError(t, host.WriteJSON(types.AutoRevealMessage{
+		Type:    types.SetAutoReveal,
+		Payload: types.AutoRevealPayload{Enabled: enabled},
+	}))
+}
+
+func TestAutoReveal_WhenEverybodyVoted(t *testing.T) {
+	ts := newRoomTestServer(t)
+	SetAutoReveal(false, 100*time.Millisecond)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	player := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+	defer player.Close()
+
+	setRoomAutoReveal(t, host, true)
+	states := roundStates(player.drain())
+	require.Len(t, states, 1)
+	require.Equal(t, true, states[0]["autoReveal"])
+	require.Equal(t, float64(100), states[0]["autoRevealDelayMs"])
+
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Estimate, Payload: "5"}))
+	require.Nil(t, findMessage(player.drain(), types.RevealData), "Bob has not voted yet")
+
+	require.NoError(t, player.WriteJSON(types.Message{Type: types.Estimate, Payload: "8"}))
+	require.Eventually(t, func() bool {
+		return getRoom("squad-a").getPhase() == types.RoundRevealed
+	}, 2*time.Second, 20*time.Millisecond)
+
+	reveal := findMessage(player.drain(), types.RevealData)
+	require.NotNil(t, reveal)
+	require.Len(t, reveal["payload"].(map[string]interface{})["estimates"], 2)
+}
+
+func TestAutoReveal_DisabledByDefault(t *testing.T) {
+	ts := newRoomTestServer(t)
+	SetAutoReveal(false, 10*time.Millisecond)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	require.Equal(t, false, roundStates(host.welcome)[0]["autoReveal"])
+
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Estimate, Payload: "5"}))
+	require.Nil(t, findMessage(host.drain(), types.RevealData))
+	require.Equal(t, types.RoundVoting, getRoom("squad-a").getPhase())
+}
+
+func TestAutoReveal_ConfiguredDefault(t *testing.T) {
+	ts := newRoomTestServer(t)
+	SetAutoReveal(true, 10*time.Millisecond)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	require.Equal(t, true, roundStates(host.welcome)[0]["autoReveal"])
+
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Estimate, Payload: "5"}))
+	require.NotNil(t, findMessage(host.drain(), types.RevealData))
+}
+
+func TestAutoReveal_NewcomerCancelsPendingReveal(t *testing.T) {
+	ts := newRoomTestServer(t)
+	SetAutoReveal(true, 300*time.Millisecond)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Estimate, Payload: "5"}))
+
+	// Bob joins within the delay and hasn't voted, so nothing is revealed
+	player := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+	defer player.Close()
+	time.Sleep(500 * time.Millisecond)
+	require.Equal(t, types.RoundVoting, getRoom("squad-a").getPhase())
+
+	// Once Bob leaves, everybody left has voted again
+	player.Close()
+	require.Eventually(t, func() bool {
+		return getRoom("squad-a").getPhase() == types.RoundRevealed
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestAutoReveal_OnlyHostsCanToggle(t *testing.T) {
+	ts := newRoomTestServer(t)
+	SetAutoReveal(false, 10*time.Millisecond)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	player := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+	defer player.Close()
+	host.drain()
+
+	setRoomAutoReveal(t, player, true)
+	require.Empty(t, roundStates(host.drain()))
+
+	room := getRoom("squad-a")
+	room.mutex.Lock()
+	defer room.mutex.Unlock()
+	require.False(t, room.autoReveal)
+}
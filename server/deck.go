@@ -0,0 +1,218 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/jcpsimmons/poker/messaging"
+	"github.com/jcpsimmons/poker/types"
+
+	"github.com/gorilla/websocket"
+)
+
+// Deck is the set of cards participants vote with. Cards are ordered by
+// ascending value; the value is used for averaging and for Linear estimates.
+type Deck struct {
+	Name  string
+	Cards []types.DeckCard
+}
+
+// defaultDeckName is the deck used when none is configured
+const defaultDeckName = "fibonacci"
+
+// builtinDecks are the decks selectable by name
+var builtinDecks = map[string]Deck{
+	"fibonacci": {
+		Name:  "fibonacci",
+		Cards: numericCards(1, 2, 3, 5, 8, 13),
+	},
+	"modified-fibonacci": {
+		Name: "modified-fibonacci",
+		Cards: append([]types.DeckCard{
+			{Label: "0", Value: 0},
+			{Label: "½", Value: 0.5},
+		}, numericCards(1, 2, 3, 5, 8, 13, 20, 40, 100)...),
+	},
+	"powers-of-two": {
+		Name:  "powers-of-two",
+		Cards: numericCards(1, 2, 4, 8, 16, 32, 64),
+	},
+	"tshirt": {
+		Name: "tshirt",
+		Cards: []types.DeckCard{
+			{Label: "XS", Value: 1},
+			{Label: "S", Value: 2},
+			{Label: "M", Value: 3},
+			{Label: "L", Value: 5},
+			{Label: "XL", Value: 8},
+			{Label: "XXL", Value: 13},
+		},
+	},
+}
+
+// noEstimate is sent in place of an estimate when there is none, for
+// participants who didn't vote and as the average when nobody voted with a
+// numeric card. It is empty so it can't be mistaken for a "0" card.
+const noEstimate = ""
+
+func numericCards(values ...float64) []types.DeckCard {
+	cards := make([]types.DeckCard, 0, len(values))
+	for _, value := range values {
+		cards = append(cards, types.DeckCard{
+			Label: strconv.FormatFloat(value, 'f', -1, 64),
+			Value: value,
+		})
+	}
+	return cards
+}
+
+// SetDeck configures the deck used by all rooms. spec is either the name of
+// a built-in deck or a comma-separated list of cards, each given as a number
+// ("1,2,3") or as "label=value" ("S=1,M=2,L=3").
+func SetDeck(spec string) error {
+	return defaultServer().setDeck(spec)
+}
+
+func (s *Server) setDeck(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	parsed, err := ParseDeck(spec)
+	if err != nil {
+		return err
+	}
+
+	s.roomsMutex.Lock()
+	defer s.roomsMutex.Unlock()
+
+	s.deck = parsed
+	for _, room := range s.rooms {
+		room.mutex.Lock()
+		room.deck = parsed
+		room.mutex.Unlock()
+	}
+	s.log.Info("Using deck", "deck", parsed.Name, "cards", strings.Join(parsed.labels(), ", "))
+	return nil
+}
+
+// ParseDeck resolves a deck spec as accepted by SetDeck
+func ParseDeck(spec string) (Deck, error) {
+	spec = strings.TrimSpace(spec)
+	if builtin, ok := builtinDecks[strings.ToLower(spec)]; ok {
+		return builtin, nil
+	}
+	if !strings.Contains(spec, ",") {
+		return Deck{}, fmt.Errorf("unknown deck %q", spec)
+	}
+
+	custom := Deck{Name: "custom"}
+	for _, entry := range strings.Split(spec, ",") {
+		label, rawValue, hasValue := strings.Cut(strings.TrimSpace(entry), "=")
+		label = strings.TrimSpace(label)
+		if !hasValue {
+			rawValue = label
+		}
+		if label == "" {
+			return Deck{}, fmt.Errorf("deck has an empty card")
+		}
+
+		value, err := parseCardValue(rawValue)
+		if err != nil {
+			return Deck{}, fmt.Errorf("card %q needs a numeric value (use label=value)", label)
+		}
+		if _, exists := custom.card(label); exists {
+			return Deck{}, fmt.Errorf("deck has duplicate card %q", label)
+		}
+		if n := len(custom.Cards); n > 0 && value <= custom.Cards[n-1].Value {
+			return Deck{}, fmt.Errorf("card %q must be worth more than %q", label, custom.Cards[n-1].Label)
+		}
+		custom.Cards = append(custom.Cards, types.DeckCard{Label: label, Value: value})
+	}
+
+	if len(custom.Cards) < 2 {
+		return Deck{}, fmt.Errorf("deck needs at least two cards")
+	}
+	return custom, nil
+}
+
+// parseCardValue parses a card value, accepting "½" for one half
+func parseCardValue(raw string) (float64, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "½" {
+		return 0.5, nil
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err == nil && (math.IsNaN(value) || math.IsInf(value, 0) || value < 0) {
+		err = fmt.Errorf("invalid card value %q", raw)
+	}
+	return value, err
+}
+
+// card finds the card matching an estimate, either by label
+// (case-insensitive) or by numeric value so "0.5" matches "½"
+func (d Deck) card(estimate string) (types.DeckCard, bool) {
+	estimate = strings.TrimSpace(estimate)
+	for _, card := range d.Cards {
+		if strings.EqualFold(card.Label, estimate) {
+			return card, true
+		}
+	}
+	if value, err := parseCardValue(estimate); err == nil {
+		for _, card := range d.Cards {
+			if card.Value == value {
+				return card, true
+			}
+		}
+	}
+	return types.DeckCard{}, false
+}
+
+// nearest returns the card closest in value, preferring the lower card on ties
+func (d Deck) nearest(value float64) types.DeckCard {
+	best := d.Cards[0]
+	for _, card := range d.Cards[1:] {
+		if math.Abs(card.Value-value) < math.Abs(best.Value-value) {
+			best = card
+		}
+	}
+	return best
+}
+
+// index returns the position of a card in the deck, or -1
+func (d Deck) index(label string) int {
+	for i, card := range d.Cards {
+		if card.Label == label {
+			return i
+		}
+	}
+	return -1
+}
+
+func (d Deck) labels() []string {
+	labels := make([]string, 0, len(d.Cards))
+	for _, card := range d.Cards {
+		labels = append(labels, card.Label)
+	}
+	return labels
+}
+
+// sendDeck tells a client which cards it can vote with
+func (r *Room) sendDeck(client *Client) {
+	message := types.DeckMessage{
+		Type: types.DeckInfo,
+		Payload: types.DeckPayload{
+			Name:     r.deck.Name,
+			Cards:    r.deck.Cards,
+			Specials: make([]types.SpecialCard, 0, len(specialCards)),
+		},
+	}
+	for _, special := range specialCards {
+		message.Payload.Specials = append(message.Payload.Specials, special.SpecialCard)
+	}
+	byteMessage := messaging.MarshallMessage(message)
+	if err := client.WriteMessage(websocket.TextMessage, byteMessage); err != nil {
+		r.log.Warn("Error sending deck", "user", client.UserID, "err", err)
+	}
+}
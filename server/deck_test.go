@@ -0,0 +1,131 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/jcpsimmons/poker/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDeck(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		labels  []string
+		wantErr bool
+	}{
+		{name: "builtin", spec: "fibonacci", labels: []string{"1", "2", "3", "5", "8", "13"}},
+		{name: "builtin case-insensitive", spec: " Powers-Of-Two ", labels: []string{"1", "2", "4", "8", "16", "32", "64"}},
+		{name: "modified fibonacci", spec: "modified-fibonacci", labels: []string{"0", "½", "1", "2", "3", "5", "8", "13", "20", "40", "100"}},
+		{name: "custom numeric", spec: "0, ½, 1, 2", labels: []string{"0", "½", "1", "2"}},
+		{name: "custom labelled", spec: "S=1,M=2,L=4", labels: []string{"S", "M", "L"}},
+		{name: "unknown name", spec: "planets", wantErr: true},
+		{name: "label without value", spec: "S,M,L", wantErr: true},
+		{name: "duplicate card", spec: "1,2,2", wantErr: true},
+		{name: "descending values", spec: "3,2,1", wantErr: true},
+		{name: "negative value", spec: "-1,1", wantErr: true},
+		{name: "empty card", spec: "1,,2", wantErr: true},
+		{name: "single card", spec: "1,", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deck, err := ParseDeck(tt.spec)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.labels, deck.labels())
+		})
+	}
+}
+
+func TestDeck_CardMatchesLabelOrValue(t *testing.T) {
+	deck := builtinDecks["modified-fibonacci"]
+
+	card, ok := deck.card("½")
+	require.True(t, ok)
+	require.Equal(t, 0.5, card.Value)
+
+	card, ok = deck.card("0.5")
+	require.True(t, ok)
+	require.Equal(t, "½", card.Label)
+
+	_, ok = deck.card("4")
+	require.False(t, ok)
+
+	tshirt := builtinDecks["tshirt"]
+	card, ok = tshirt.card("xl")
+	require.True(t, ok)
+	require.Equal(t, "XL", card.Label)
+}
+
+func TestGetPointAverage_SnapsToDeck(t *testing.T) {
+	tests := []struct {
+		name  string
+		deck  string
+		votes []string
+		want  string
+	}{
+		{name: "fibonacci exact", deck: "fibonacci", votes: []string{"5", "5"}, want: "5"},
+		{name: "fibonacci rounds to nearest", deck: "fibonacci", votes: []string{"3", "8"}, want: "5"},
+		{name: "fibonacci tie picks lower", deck: "fibonacci", votes: []string{"1", "2"}, want: "1"},
+		{name: "modified fibonacci fractions", deck: "modified-fibonacci", votes: []string{"0", "1"}, want: "½"},
+		{name: "modified fibonacci large", deck: "modified-fibonacci", votes: []string{"40", "100", "100"}, want: "100"},
+		{name: "tshirt", deck: "tshirt", votes: []string{"S", "L"}, want: "M"},
+		{name: "no votes", deck: "fibonacci", votes: []string{"", ""}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestServer()
+			require.NoError(t, SetDeck(tt.deck))
+
+			room := testRoom()
+			for _, vote := range tt.votes {
+				client, _ := createMockClient()
+				client.CurrentEstimate, _ = room.deck.estimate(vote)
+				room.clients[client] = true
+			}
+
+			require.Equal(t, tt.want, room.getPointAverageLabel())
+		})
+	}
+}
+
+func TestDeck_SentOnJoinAndValidatesEstimates(t *testing.T) {
+	ts := newRoomTestServer(t)
+	require.NoError(t, SetDeck("tshirt"))
+
+	client := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer client.Close()
+
+	msg := findMessage(client.welcome, types.DeckInfo)
+	require.NotNil(t, msg, "expected a deck message on join")
+	payload := msg["payload"].(map[string]interface{})
+	require.Equal(t, "tshirt", payload["name"])
+	require.Len(t, payload["cards"], 6)
+
+	// A card that isn't in the deck is rejected and not recorded
+	require.NoError(t, client.WriteJSON(types.Message{Type: types.Estimate, Payload: "7"}))
+	require.NotNil(t, findMessage(client.drain(), types.EstimateError))
+
+	room := getRoom("squad-a")
+	room.mutex.Lock()
+	require.False(t, room.findClientUnlocked("Alice").CurrentEstimate.Voted())
+	room.mutex.Unlock()
+
+	// Votes are stored as the canonical card label
+	require.NoError(t, client.WriteJSON(types.Message{Type: types.Estimate, Payload: "m"}))
+	require.NotNil(t, findMessage(client.drain(), types.VoteStatus))
+
+	room.mutex.Lock()
+	require.Equal(t, "M", room.findClientUnlocked("Alice").CurrentEstimate.Card)
+	room.mutex.Unlock()
+
+	require.NoError(t, client.WriteJSON(types.Message{Type: types.Reveal}))
+	reveal := findMessage(client.drain(), types.RevealData)
+	require.NotNil(t, reveal)
+	require.Equal(t, "M", reveal["payload"].(map[string]interface{})["pointAvg"])
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+// The package-level functions configure and run a default Server, the way
+// the package worked before New. Programs embedding poker should use New.
+
+// defaultInstance is swapped by ResetServerState, so it is atomic
+var defaultInstance atomic.Pointer[Server]
+
+func init() {
+	defaultInstance.Store(newServer(nil, embeddedAssets()))
+}
+
+// defaultServer returns the Server behind the package-level functions
+func defaultServer() *Server {
+	return defaultInstance.Load()
+}
+
+// Start serves the default server on the given port until ctx is
+// cancelled, then shuts down gracefully (see Server.Serve)
+func Start(ctx context.Context, port string) error {
+	return defaultServer().Start(ctx, port)
+}
+
+// Start serves s on the given port until ctx is cancelled, then shuts down
+// gracefully (see Serve)
+func (s *Server) Start(ctx context.Context, port string) error {
+	if err := s.checkWebApp(); err != nil {
+		return err
+	}
+
+	strPort := ":" + port
+	ln, err := net.Listen("tcp", strPort)
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("Starting server", "port", port,
+		"web", "http://localhost"+strPort, "websocket", "ws://localhost"+strPort+"/ws")
+	return s.Serve(ctx, ln)
+}
+
+// RegisterHandlers mounts the default server on the default HTTP serve
+// mux, for serving it with http.Serve on any net.Listener
+func RegisterHandlers() {
+	http.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defaultServer().ServeHTTP(w, r)
+	}))
+}
+
+// Serve serves the default server on ln until ctx is cancelled (see
+// Server.Serve)
+func Serve(ctx context.Context, ln net.Listener) error {
+	return defaultServer().Serve(ctx, ln)
+}
+
+// Shutdown shuts the default server down (see Server.Shutdown)
+func Shutdown(ctx context.Context) error {
+	return defaultServer().Shutdown(ctx)
+}
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jcpsimmons/poker/messaging"
+	"github.com/jcpsimmons/poker/types"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	errNameTaken = errors.New("username is already taken, please choose another")
+	errHostTaken = errors.New("a host is already in this session, please join as a player")
+)
+
+// CommandError is a rejected client command. Versioned clients get Code in
+// an error reply; legacy clients get the message as a Legacy message, or
+// nothing if the failure used to be silent.
+type CommandError struct {
+	Code       types.ErrorCode
+	Legacy     types.MessageType
+	Err        error
+	Disconnect bool // The client is dropped once it has been told
+}
+
+func (e *CommandError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// newCommandError wraps one of the handlers' sentinel errors with its code
+func newCommandError(legacy types.MessageType, err error) *CommandError {
+	return &CommandError{Code: errorCode(err), Legacy: legacy, Err: err}
+}
+
+// errorCode maps the sentinel errors handlers return to their wire code
+func errorCode(err error) types.ErrorCode {
+	switch {
+	case errors.Is(err, errParticipantNotFound):
+		return types.CodeNotFound
+	case errors.Is(err, errObserverCannotHost), errors.Is(err, errAlreadyHost),
+		errors.Is(err, errNotHost), errors.Is(err, errLastHost):
+		return types.CodeInvalidRole
+	case errors.Is(err, errCannotKickSelf):
+		return types.CodeInvalidTarget
+	case errors.Is(err, errVotesLocked):
+		return types.CodeVotesLocked
+	case errors.Is(err, errBanned):
+		return types.CodeBanned
+	case errors.Is(err, errInvalidResumeToken):
+		return types.CodeInvalidToken
+	case errors.Is(err, errNameTaken):
+		return types.CodeNameTaken
+	case errors.Is(err, errHostTaken):
+		return types.CodeHostTaken
+	}
+	return types.CodeBadRequest
+}
+
+// decodeEnvelope parses a client frame in either the versioned or the
+// legacy format
+func decodeEnvelope(message []byte) (types.Envelope, error) {
+	var envelope types.Envelope
+	if err := json.Unmarshal(message, &envelope); err != nil {
+		return envelope, &CommandError{Code: types.CodeBadRequest, Err: fmt.Errorf("invalid message: %w", err)}
+	}
+	return envelope, nil
+}
+
+// decodePayload unmarshals an envelope payload. A missing payload leaves v
+// untouched.
+func decodePayload(envelope types.Envelope, v interface{}) error {
+	if len(envelope.Payload) == 0 || string(envelope.Payload) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(envelope.Payload, v); err != nil {
+		return &CommandError{Code: types.CodeBadRequest, Err: fmt.Errorf("invalid %s payload: %w", envelope.Type, err)}
+	}
+	return nil
+}
+
+// payloadString decodes a string payload, as sent by newIssue, estimate
+// and resume
+func payloadString(envelope types.Envelope) (string, error) {
+	var value string
+	err := decodePayload(envelope, &value)
+	return value, err
+}
+
+// decodeJoinPayload accepts a join payload object, or the legacy bare
+// username string
+func decodeJoinPayload(envelope types.Envelope) (types.JoinPayload, error) {
+	var payload types.JoinPayload
+	if len(envelope.Payload) > 0 && envelope.Payload[0] == '"' {
+		username, err := payloadString(envelope)
+		payload.Username = username
+		return payload, err
+	}
+	err := decodePayload(envelope, &payload)
+	return payload, err
+}
+
+// reply answers a client command. Versioned frames get an ack or an error
+// echoing their request ID; legacy frames only hear about failures, in the
+// per-feature message they always got.
+func reply(client *Client, envelope types.Envelope, err error) {
+	if err != nil {
+		client.logger().Warn("Rejected command", "user", client.UserID, "type", envelope.Type, "requestId", envelope.RequestID, "err", err)
+	}
+	if envelope.Version < types.ProtocolVersion {
+		replyLegacy(client, err)
+		return
+	}
+
+	if err == nil {
+		sendJSON(client, types.AckMessage{
+			Version:   types.ProtocolVersion,
+			Type:      types.Ack,
+			RequestID: envelope.RequestID,
+			Payload:   types.AckPayload{Type: envelope.Type},
+		})
+		return
+	}
+
+	sendJSON(client, types.ErrorMessage{
+		Version:   types.ProtocolVersion,
+		Type:      types.Error,
+		RequestID: envelope.RequestID,
+		Payload:   errorPayload(err, envelope.Type),
+	})
+}
+
+// errorPayload describes why a command of the given type failed
+func errorPayload(err error, messageType types.MessageType) types.ErrorPayload {
+	payload := types.ErrorPayload{
+		Code:    types.CodeBadRequest,
+		Message: err.Error(),
+		Type:    messageType,
+	}
+	var permissionErr *PermissionError
+	var commandErr *CommandError
+	switch {
+	case errors.As(err, &permissionErr):
+		payload.Code = types.CodeForbidden
+		if _, known := permissions[permissionErr.Type]; !known {
+			payload.Code = types.CodeUnknownType
+		}
+	case errors.As(err, &commandErr):
+		payload.Code = commandErr.Code
+	}
+	return payload
+}
+
+// replyLegacy sends the pre-envelope error message for a failed command
+func replyLegacy(client *Client, err error) {
+	var permissionErr *PermissionError
+	var commandErr *CommandError
+	switch {
+	case err == nil:
+	case errors.As(err, &permissionErr):
+		sendPermissionError(client, permissionErr)
+	case errors.As(err, &commandErr) && commandErr.Legacy != "":
+		sendClientMessage(client, types.Message{
+			Type:    commandErr.Legacy,
+			Payload: commandErr.Error(),
+		})
+	}
+}
+
+// sendJSON marshals and sends a structured message to one client
+func sendJSON(client *Client, message interface{}) {
+	byteMessage := messaging.MarshallMessage(message)
+	if err := client.WriteMessage(websocket.TextMessage, byteMessage); err != nil {
+		client.logger().Warn("Error writing message", "user", client.UserID, "err", err)
+	}
+}
+
+// disconnects reports whether a failed command drops the client
+func disconnects(err error) bool {
+	var commandErr *CommandError
+	return errors.As(err, &commandErr) && commandErr.Disconnect
+}
+
+// currentIssueMessages encodes a current issue for versioned and legacy
+// clients. Legacy clients expect the payload JSON-encoded in a string.
+func currentIssueMessages(payload types.CurrentIssuePayload) (versioned []byte, legacy []byte) {
+	versioned = messaging.MarshallMessage(types.CurrentIssueMessage{
+		Version: types.ProtocolVersion,
+		Type:    types.CurrentIssue,
+		Payload: payload,
+	})
+	legacy = messaging.MarshallMessage(types.Message{
+		Type:    types.CurrentIssue,
+		Payload: string(messaging.MarshallMessage(payload)),
+	})
+	return versioned, legacy
+}
+
+// sendCurrentIssue sends the current issue to one client in its format
+func sendCurrentIssue(client *Client, payload types.CurrentIssuePayload) {
+	versioned, legacy := currentIssueMessages(payload)
+	message := legacy
+	if client.versioned() {
+		message = versioned
+	}
+	if err := client.WriteMessage(websocket.TextMessage, message); err != nil {
+		client.logger().Warn("Error writing message", "user", client.UserID, "err", err)
+	}
+}
+
+// broadcastCurrentIssue sends the current issue to everybody, each in
+// their own format
+func (r *Room) broadcastCurrentIssue(payload types.CurrentIssuePayload, sender *Client) {
+	versioned, legacy := currentIssueMessages(payload)
+	r.broadcastEach(func(client *Client) []byte {
+		if client.versioned() {
+			return versioned
+		}
+		return legacy
+	}, sender)
+}
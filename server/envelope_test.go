@@ -0,0 +1,172 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/jcpsimmons/poker/types"
+	"github.com/stretchr/testify/require"
+)
+
+// sendCommand sends a versioned command frame
+func sendCommand(t *testing.T, client *roomTestClient, messageType types.MessageType, requestID string, payload interface{}) {
+	raw, err := json.Marshal(payload)
+	require.NoError(t, err)
+	require.NoError(t, client.WriteJSON(types.Envelope{
+		Version:   types.ProtocolVersion,
+		Type:      messageType,
+		RequestID: requestID,
+		Payload:   raw,
+	}))
+}
+
+// joinVersioned joins a room with a versioned join command
+func joinVersioned(t *testing.T, serverURL string, room string, payload types.JoinPayload) *roomTestClient {
+	client := dialTestRoom(t, serverURL, room)
+	sendCommand(t, client, types.Join, "join-1", payload)
+	client.welcome = client.drain()
+	return client
+}
+
+// replyTo returns the ack or error reply with the given request ID, or nil
+func replyTo(messages []map[string]interface{}, requestID string) map[string]interface{} {
+	for _, msg := range messages {
+		if (msg["type"] == string(types.Ack) || msg["type"] == string(types.Error)) && msg["requestId"] == requestID {
+			return msg
+		}
+	}
+	return nil
+}
+
+func TestEnvelope_AcksVersionedCommands(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinVersioned(t, ts.URL, "squad-a", types.JoinPayload{Username: "Alice", IsHost: true})
+	defer host.Close()
+	ack := replyTo(host.welcome, "join-1")
+	require.NotNil(t, ack)
+	require.Equal(t, string(types.Ack), ack["type"])
+	require.Equal(t, float64(types.ProtocolVersion), ack["v"])
+	require.Equal(t, map[string]interface{}{"type": "join"}, ack["payload"])
+
+	sendCommand(t, host, types.NewIssue, "issue-1", "CDP-1")
+	messages := host.drain()
+	require.NotNil(t, replyTo(messages, "issue-1"))
+
+	// Versioned clients get the current issue as an object, not a JSON string
+	issue := findMessage(messages, types.CurrentIssue)
+	require.NotNil(t, issue)
+	require.Equal(t, map[string]interface{}{"text": "CDP-1"}, issue["payload"])
+}
+
+func TestEnvelope_LegacyFramesStillAccepted(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	require.Nil(t, findMessage(host.welcome, types.Ack), "legacy clients aren't sent acks")
+
+	// The bare username join predates the join payload object
+	bob := dialTestRoom(t, ts.URL, "squad-a")
+	defer bob.Close()
+	require.NoError(t, bob.WriteJSON(types.Message{Type: types.Join, Payload: "Bob"}))
+	require.NotNil(t, findMessage(bob.drain(), types.ResumeToken))
+
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.NewIssue, Payload: "CDP-1"}))
+	issue := findMessage(bob.drain(), types.CurrentIssue)
+	require.NotNil(t, issue)
+	require.Equal(t, `{"text":"CDP-1"}`, issue["payload"])
+
+	require.NoError(t, bob.WriteJSON(types.Message{Type: types.Estimate, Payload: "42"}))
+	messages := bob.drain()
+	require.Nil(t, findMessage(messages, types.Error))
+	require.NotNil(t, findMessage(messages, types.EstimateError))
+}
+
+func TestEnvelope_ErrorCodes(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinVersioned(t, ts.URL, "squad-a", types.JoinPayload{Username: "Alice", IsHost: true})
+	defer host.Close()
+	player := joinVersioned(t, ts.URL, "squad-a", types.JoinPayload{Username: "Bob"})
+	defer player.Close()
+	newcomer := dialTestRoom(t, ts.URL, "squad-a")
+	defer newcomer.Close()
+	host.drain()
+
+	tests := []struct {
+		name        string
+		client      *roomTestClient
+		messageType types.MessageType
+		payload     interface{}
+		want        types.ErrorCode
+	}{
+		{name: "card not in deck", client: player, messageType: types.Estimate, payload: "42", want: types.CodeInvalidEstimate},
+		{name: "host command from a player", client: player, messageType: types.Reveal, want: types.CodeForbidden},
+		{name: "unknown type", client: player, messageType: "dance", want: types.CodeUnknownType},
+		{name: "malformed payload", client: host, messageType: types.StartTimer, payload: "soon", want: types.CodeBadRequest},
+		{name: "timer out of range", client: host, messageType: types.StartTimer, payload: types.StartTimerPayload{Seconds: 0}, want: types.CodeInvalidTimer},
+		{name: "kick yourself", client: host, messageType: types.Kick, payload: types.KickPayload{Username: "Alice"}, want: types.CodeInvalidTarget},
+		{name: "transfer to nobody", client: host, messageType: types.TransferHost, payload: types.TransferHostPayload{Username: "Nobody"}, want: types.CodeNotFound},
+		{name: "remove the last host", client: host, messageType: types.SetCoHost, payload: types.CoHostPayload{Username: "Alice"}, want: types.CodeInvalidRole},
+		{name: "delete a missing queue item", client: host, messageType: types.MessageQueueDelete, payload: types.QueueDeletePayload{ID: "gone"}, want: types.CodeNotFound},
+		{name: "join twice", client: player, messageType: types.Join, payload: types.JoinPayload{Username: "Bobby"}, want: types.CodeForbidden},
+		{name: "resume with a bad token", client: newcomer, messageType: types.Resume, payload: "forged", want: types.CodeInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sendCommand(t, tt.client, tt.messageType, tt.name, tt.payload)
+			msg := replyTo(tt.client.drain(), tt.name)
+			require.NotNil(t, msg)
+			require.Equal(t, string(types.Error), msg["type"])
+			payload := msg["payload"].(map[string]interface{})
+			require.Equal(t, string(tt.want), payload["code"])
+			require.Equal(t, string(tt.messageType), payload["type"])
+			require.NotEmpty(t, payload["message"])
+		})
+	}
+}
+
+func TestEnvelope_InvalidFrame(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	client := joinVersioned(t, ts.URL, "squad-a", types.JoinPayload{Username: "Alice"})
+	defer client.Close()
+
+	require.NoError(t, client.WriteMessage(websocket.TextMessage, []byte("{not json")))
+	msg := findMessage(client.drain(), types.Error)
+	require.NotNil(t, msg)
+	require.Equal(t, string(types.CodeBadRequest), msg["payload"].(map[string]interface{})["code"])
+}
+
+func TestEnvelope_FailedJoinDisconnects(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+
+	tests := []struct {
+		name    string
+		payload types.JoinPayload
+		want    types.ErrorCode
+	}{
+		{name: "name taken", payload: types.JoinPayload{Username: "alice"}, want: types.CodeNameTaken},
+		{name: "second host", payload: types.JoinPayload{Username: "Bob", IsHost: true}, want: types.CodeHostTaken},
+		{name: "invalid username", payload: types.JoinPayload{Username: "B"}, want: types.CodeInvalidUsername},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := joinVersioned(t, ts.URL, "squad-a", tt.payload)
+			defer client.Close()
+
+			msg := replyTo(client.welcome, "join-1")
+			require.NotNil(t, msg)
+			require.Equal(t, string(tt.want), msg["payload"].(map[string]interface{})["code"])
+
+			_, open := <-client.messages
+			require.False(t, open, "the server closes the connection")
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jcpsimmons/poker/types"
+)
+
+// EstimateKind tags what kind of card an Estimate holds
+type EstimateKind int
+
+const (
+	// NoEstimate means the participant hasn't voted this round
+	NoEstimate EstimateKind = iota
+	// NumericEstimate is a card from the room's deck
+	NumericEstimate
+	// SpecialEstimate is one of the specialCards, which count as a vote
+	// but are never averaged
+	SpecialEstimate
+)
+
+// Estimate is a participant's vote. The zero value is "hasn't voted".
+type Estimate struct {
+	Kind  EstimateKind
+	Card  string  // Label of the card, as shown to clients
+	Value float64 // Only meaningful for NumericEstimate
+}
+
+// specialCard is a non-numeric card every deck offers
+type specialCard struct {
+	types.SpecialCard
+	aliases  []string
+	singular string // Reveal summary for one participant, e.g. "1 person needs clarification"
+	plural   string // Reveal summary for several participants
+}
+
+// specialCards are available in every deck, in the order they are shown
+var specialCards = []specialCard{
+	{
+		SpecialCard: types.SpecialCard{Label: "?", Description: "Not sure, needs clarification"},
+		aliases:     []string{"unsure"},
+		singular:    "1 person needs clarification",
+		plural:      "%d people need clarification",
+	},
+	{
+		SpecialCard: types.SpecialCard{Label: "☕", Description: "Need a break"},
+		aliases:     []string{"coffee", "break"},
+		singular:    "1 person needs a break",
+		plural:      "%d people need a break",
+	},
+	{
+		SpecialCard: types.SpecialCard{Label: "∞", Description: "Too big, split it up"},
+		aliases:     []string{"infinity", "inf"},
+		singular:    "1 person thinks it's too big",
+		plural:      "%d people think it's too big",
+	},
+}
+
+// Voted reports whether the estimate is a vote of any kind
+func (e Estimate) Voted() bool {
+	return e.Kind != NoEstimate
+}
+
+// Label returns what clients are shown for the estimate
+func (e Estimate) Label() string {
+	if !e.Voted() {
+		return noEstimate
+	}
+	return e.Card
+}
+
+// findSpecialCard matches a special card by label or alias
+func findSpecialCard(raw string) (specialCard, bool) {
+	raw = strings.TrimSpace(raw)
+	for _, special := range specialCards {
+		if raw == special.Label {
+			return special, true
+		}
+		for _, alias := range special.aliases {
+			if strings.EqualFold(raw, alias) {
+				return special, true
+			}
+		}
+	}
+	return specialCard{}, false
+}
+
+// estimate resolves a vote sent by a client against the deck and the
+// special cards
+func (d Deck) estimate(raw string) (Estimate, bool) {
+	if special, ok := findSpecialCard(raw); ok {
+		return Estimate{Kind: SpecialEstimate, Card: special.Label}, true
+	}
+	if card, ok := d.card(raw); ok {
+		return Estimate{Kind: NumericEstimate, Card: card.Label, Value: card.Value}, true
+	}
+	return Estimate{}, false
+}
+
+// specialSummaries counts the special cards among estimates, in the order
+// of specialCards, skipping cards nobody played
+func specialSummaries(estimates []types.UserEstimate) []types.SpecialSummary {
+	summaries := make([]types.SpecialSummary, 0)
+	for _, special := range specialCards {
+		var users []string
+		for _, est := range estimates {
+			if est.Special && est.Estimate == special.Label {
+				users = append(users, est.User)
+			}
+		}
+		if len(users) == 0 {
+			continue
+		}
+		sort.Strings(users)
+
+		summary := special.singular
+		if len(users) > 1 {
+			summary = fmt.Sprintf(special.plural, len(users))
+		}
+		summaries = append(summaries, types.SpecialSummary{
+			Card:    special.Label,
+			Count:   len(users),
+			Users:   users,
+			Summary: summary,
+		})
+	}
+	return summaries
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/jcpsimmons/poker/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeck_Estimate(t *testing.T) {
+	deck := builtinDecks[defaultDeckName]
+
+	tests := []struct {
+		raw  string
+		kind EstimateKind
+		card string
+	}{
+		{raw: "5", kind: NumericEstimate, card: "5"},
+		{raw: "?", kind: SpecialEstimate, card: "?"},
+		{raw: "unsure", kind: SpecialEstimate, card: "?"},
+		{raw: "☕", kind: SpecialEstimate, card: "☕"},
+		{raw: "Coffee", kind: SpecialEstimate, card: "☕"},
+		{raw: "∞", kind: SpecialEstimate, card: "∞"},
+		{raw: "inf", kind: SpecialEstimate, card: "∞"},
+		{raw: "4", kind: NoEstimate},
+		{raw: "", kind: NoEstimate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			estimate, ok := deck.estimate(tt.raw)
+			require.Equal(t, tt.kind != NoEstimate, ok)
+			require.Equal(t, tt.kind, estimate.Kind)
+			require.Equal(t, tt.card, estimate.Card)
+		})
+	}
+}
+
+func TestSpecialSummaries(t *testing.T) {
+	summaries := specialSummaries([]types.UserEstimate{
+		{User: "Carol", Estimate: "?", Special: true},
+		{User: "Alice", Estimate: "?", Special: true},
+		{User: "Bob", Estimate: "∞", Special: true},
+		{User: "Dave", Estimate: "5"},
+		{User: "Erin", Estimate: ""},
+	})
+
+	require.Equal(t, []types.SpecialSummary{
+		{Card: "?", Count: 2, Users: []string{"Alice", "Carol"}, Summary: "2 people need clarification"},
+		{Card: "∞", Count: 1, Users: []string{"Bob"}, Summary: "1 person thinks it's too big"},
+	}, summaries)
+
+	require.Empty(t, specialSummaries(nil))
+}
+
+func TestRevealData_ZeroCardDiffersFromNoVote(t *testing.T) {
+	setupTestServer()
+	require.NoError(t, SetDeck("modified-fibonacci"))
+	room := testRoom()
+	for user, vote := range map[string]string{"Alice": "0", "Bob": ""} {
+		client, _ := createMockClient()
+		client.UserID = user
+		client.CurrentEstimate, _ = room.deck.estimate(vote)
+		room.clients[client] = true
+	}
+
+	labels := map[string]string{}
+	for _, est := range room.getFormattedRevealData() {
+		labels[est.User] = est.Estimate
+	}
+	require.Equal(t, map[string]string{"Alice": "0", "Bob": ""}, labels)
+	require.Equal(t, "0", room.getPointAverageLabel())
+}
+
+func TestSpecialCards_CountAsVotedButNotAveraged(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	alice := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer alice.Close()
+	bob := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+	defer bob.Close()
+	carol := joinTestRoom(t, ts.URL, "squad-a", "Carol", false)
+	defer carol.Close()
+
+	deckMsg := findMessage(carol.welcome, types.DeckInfo)
+	require.NotNil(t, deckMsg)
+	require.Len(t, deckMsg["payload"].(map[string]interface{})["specials"], len(specialCards))
+
+	require.NoError(t, alice.WriteJSON(types.Message{Type: types.Estimate, Payload: "8"}))
+	require.NoError(t, bob.WriteJSON(types.Message{Type: types.Estimate, Payload: "?"}))
+	require.NoError(t, carol.WriteJSON(types.Message{Type: types.Estimate, Payload: "?"}))
+	bob.drain()
+	carol.drain()
+
+	// Everybody shows up as having voted
+	var voteStatus map[string]interface{}
+	for _, msg := range alice.drain() {
+		if msg["type"] == string(types.VoteStatus) {
+			voteStatus = msg
+		}
+	}
+	require.NotNil(t, voteStatus)
+	for _, voter := range voteStatus["payload"].(map[string]interface{})["voters"].([]interface{}) {
+		require.True(t, voter.(map[string]interface{})["hasVoted"].(bool))
+	}
+
+	require.NoError(t, alice.WriteJSON(types.Message{Type: types.Reveal}))
+	reveal := findMessage(alice.drain(), types.RevealData)
+	require.NotNil(t, reveal)
+	payload := reveal["payload"].(map[string]interface{})
+
+	require.Equal(t, "8", payload["pointAvg"], "special cards are left out of the average")
+
+	specials := payload["specials"].([]interface{})
+	require.Len(t, specials, 1)
+	require.Equal(t, "2 people need clarification", specials[0].(map[string]interface{})["summary"])
+
+	for _, est := range payload["estimates"].([]interface{}) {
+		est := est.(map[string]interface{})
+		if est["user"] == "Bob" {
+			require.Equal(t, "?", est["estimate"])
+			require.Equal(t, true, est["special"])
+		}
+	}
+}
+
+func TestSpecialCards_OnlySpecialsHaveNoAverage(t *testing.T) {
+	setupTestServer()
+	room := testRoom()
+
+	client, _ := createMockClient()
+	client.CurrentEstimate, _ = room.deck.estimate("☕")
+	room.clients[client] = true
+
+	_, ok := room.getPointAverage()
+	require.False(t, ok)
+	require.Equal(t, noEstimate, room.getPointAverageLabel())
+}
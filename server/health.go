@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/jcpsimmons/poker/types"
+)
+
+const (
+	// linearCheckInterval is how long a Linear readiness check is reused,
+	// so frequent probes don't turn into a stream of API calls
+	linearCheckInterval = 30 * time.Second
+	linearCheckTimeout  = 5 * time.Second
+)
+
+// baseFeatures are supported by every server. Optional ones are added by
+// features when they are enabled.
+var baseFeatures = []string{
+	"resume", "observers", "coHosts", "moderation",
+	"timer", "autoReveal", "customDecks", "history",
+}
+
+// linearCheck remembers the last Linear readiness check
+type linearCheck struct {
+	mutex     sync.Mutex
+	checkedAt time.Time
+	err       error
+}
+
+type readyResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+type versionResponse struct {
+	Version         string   `json:"version"`
+	ProtocolVersion int      `json:"protocolVersion"`
+	Features        []string `json:"features"`
+}
+
+// buildVersion returns the module version the binary was built from, e.g.
+// by go install, or "dev" for a local build
+func buildVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
+
+// features lists what the server supports, for clients to check before
+// relying on it
+func (s *Server) features() []string {
+	features := append([]string{}, baseFeatures...)
+	if s.authEnabled {
+		features = append(features, "auth")
+	}
+	if s.linear.Load() != nil {
+		features = append(features, "linear")
+	}
+	if s.getDataDir() != "" {
+		features = append(features, "persistence")
+	}
+	if s.metricsEnabled {
+		features = append(features, "metrics")
+	}
+	if s.apiToken != "" {
+		features = append(features, "api")
+	}
+	return features
+}
+
+// checkLinear reports whether the Linear API can be reached, reusing a
+// recent answer
+func (s *Server) checkLinear(ctx context.Context) error {
+	client := s.linear.Load()
+	if client == nil {
+		return nil
+	}
+
+	check := &s.linearCheck
+	check.mutex.Lock()
+	defer check.mutex.Unlock()
+	if !check.checkedAt.IsZero() && time.Since(check.checkedAt) < linearCheckInterval {
+		return check.err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, linearCheckTimeout)
+	defer cancel()
+	check.err = client.Ping(ctx)
+	check.checkedAt = time.Now()
+	if check.err != nil {
+		s.log.Warn("Linear readiness check failed", "err", check.err)
+	}
+	return check.err
+}
+
+// handleHealth serves GET /healthz: the process is up and serving HTTP
+func (s *Server) handleHealth(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// handleReady serves GET /readyz: the server can take sessions. It fails
+// while shutting down, without a built web app, or when a configured
+// Linear integration can't reach Linear.
+func (s *Server) handleReady(w http.ResponseWriter, req *http.Request) {
+	response := readyResponse{Status: "ready", Checks: map[string]string{}}
+	fail := func(check, reason string) {
+		response.Status = "not ready"
+		response.Checks[check] = reason
+	}
+
+	if s.closed.Load() {
+		fail("server", shutdownReason)
+	} else {
+		response.Checks["server"] = "ok"
+	}
+	switch {
+	case s.noWebApp:
+		response.Checks["assets"] = "disabled"
+	case hasIndex(s.assets):
+		response.Checks["assets"] = "ok"
+	default:
+		fail("assets", "index.html not found")
+	}
+	if s.linear.Load() != nil {
+		if err := s.checkLinear(req.Context()); err != nil {
+			fail("linear", err.Error())
+		} else {
+			response.Checks["linear"] = "ok"
+		}
+	}
+
+	status := http.StatusOK
+	if response.Status != "ready" {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleVersion serves GET /api/version
+func (s *Server) handleVersion(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versionResponse{
+		Version:         s.version,
+		ProtocolVersion: types.ProtocolVersion,
+		Features:        s.features(),
+	})
+}
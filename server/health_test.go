@@ -0,0 +1,146 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jcpsimmons/poker/linear"
+	"github.com/jcpsimmons/poker/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthz(t *testing.T) {
+	_, ts := newInstanceTestServer(t, Options{AuthPassword: "secret"})
+
+	resp, err := http.Get(ts.URL + "/healthz")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, "probes need no credentials")
+	require.Equal(t, "ok\n", string(body))
+}
+
+func TestReadyz(t *testing.T) {
+	withApp := http.FS(fstest.MapFS{"index.html": {Data: []byte("<h1>poker</h1>")}})
+	withoutApp := http.FS(fstest.MapFS{".gitkeep": {}})
+
+	tests := []struct {
+		name       string
+		assets     http.FileSystem
+		setup      func(s *Server)
+		wantStatus int
+		wantChecks map[string]string
+	}{
+		{
+			name:       "ready",
+			assets:     withApp,
+			wantStatus: http.StatusOK,
+			wantChecks: map[string]string{"server": "ok", "assets": "ok"},
+		},
+		{
+			name:       "web app missing",
+			assets:     withoutApp,
+			wantStatus: http.StatusServiceUnavailable,
+			wantChecks: map[string]string{"server": "ok", "assets": "index.html not found"},
+		},
+		{
+			name:       "shutting down",
+			assets:     withApp,
+			setup:      func(s *Server) { s.Close() },
+			wantStatus: http.StatusServiceUnavailable,
+			wantChecks: map[string]string{"server": shutdownReason, "assets": "ok"},
+		},
+		{
+			name:   "linear unreachable",
+			assets: withApp,
+			setup: func(s *Server) {
+				// A recent failed check is reused instead of calling Linear
+				s.useLinear(linear.NewClient("key"))
+				s.linearCheck.checkedAt = time.Now()
+				s.linearCheck.err = errors.New("failed to reach Linear")
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantChecks: map[string]string{"server": "ok", "assets": "ok", "linear": "failed to reach Linear"},
+		},
+		{
+			name:   "linear reachable",
+			assets: withApp,
+			setup: func(s *Server) {
+				s.useLinear(linear.NewClient("key"))
+				s.linearCheck.checkedAt = time.Now()
+			},
+			wantStatus: http.StatusOK,
+			wantChecks: map[string]string{"server": "ok", "assets": "ok", "linear": "ok"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, ts := newInstanceTestServer(t, Options{Assets: tt.assets})
+			if tt.setup != nil {
+				tt.setup(srv)
+			}
+
+			resp, err := http.Get(ts.URL + "/readyz")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			var ready readyResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&ready))
+			require.Equal(t, tt.wantChecks, ready.Checks)
+			if tt.wantStatus == http.StatusOK {
+				require.Equal(t, "ready", ready.Status)
+			} else {
+				require.Equal(t, "not ready", ready.Status)
+			}
+		})
+	}
+}
+
+func TestVersion(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         Options
+		wantVersion  string
+		wantFeatures []string
+		notFeatures  []string
+	}{
+		{
+			name:         "defaults",
+			opts:         Options{},
+			wantVersion:  "dev",
+			wantFeatures: []string{"resume", "observers", "history"},
+			notFeatures:  []string{"auth", "linear", "metrics", "persistence", "api"},
+		},
+		{
+			name:         "everything enabled",
+			opts:         Options{Version: "v1.2.3", AuthPassword: "secret", Metrics: true, Linear: linear.NewClient("key"), DataDir: t.TempDir(), APIToken: "token"},
+			wantVersion:  "v1.2.3",
+			wantFeatures: []string{"auth", "linear", "metrics", "persistence", "api"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ts := newInstanceTestServer(t, tt.opts)
+
+			resp, err := http.Get(ts.URL + "/api/version")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			var version versionResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&version))
+			require.Equal(t, tt.wantVersion, version.Version)
+			require.Equal(t, types.ProtocolVersion, version.ProtocolVersion)
+			require.Subset(t, version.Features, tt.wantFeatures)
+			for _, feature := range tt.notFeatures {
+				require.NotContains(t, version.Features, feature)
+			}
+		})
+	}
+}
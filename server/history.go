@@ -0,0 +1,201 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jcpsimmons/poker/types"
+)
+
+// historyResponse is the JSON body of GET /api/history
+type historyResponse struct {
+	Room   string                  `json:"room"`
+	Rounds []types.IssueRevealData `json:"rounds"`
+}
+
+// recordRound adds the revealed round to the session history. A round is
+// only revealed once, so every entry is a round of its own.
+func (r *Room) recordRound(reveal types.RevealPayload) {
+	average, averaged := r.getPointAverage()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Only participants who voted are recorded
+	estimates := make([]types.UserEstimate, 0, len(r.clients))
+	for client := range r.clients {
+		if client.UserID != "" && client.CurrentEstimate.Voted() {
+			estimates = append(estimates, types.UserEstimate{
+				User:     client.UserID,
+				Estimate: client.CurrentEstimate.Card,
+				Special:  client.CurrentEstimate.Kind == SpecialEstimate,
+			})
+		}
+	}
+	sort.Slice(estimates, func(i, j int) bool {
+		return estimates[i].User < estimates[j].User
+	})
+
+	entry := types.IssueRevealData{
+		Round:      r.round,
+		IssueTitle: r.currentIssue,
+		Estimates:  estimates,
+		Average:    reveal.PointAvg,
+		Stats:      reveal.Stats,
+		StartedAt:  r.startedAt,
+		RevealedAt: time.Now().UTC(),
+	}
+	if averaged {
+		rounded := int64(math.Round(average.Value))
+		entry.RoundedEstimate = &rounded
+	}
+	if r.currentLinearIssue != nil {
+		entry.IssueIdentifier = r.currentLinearIssue.Identifier
+		entry.IssueID = r.currentLinearIssue.ID
+		entry.IssueTitle = r.currentLinearIssue.Title
+	}
+	for client := range r.clients {
+		if client.IsHost {
+			entry.Host = client.UserID
+			break
+		}
+	}
+
+	r.history = append(r.history, entry)
+}
+
+// getHistory returns a copy of the room's history
+func (r *Room) getHistory() []types.IssueRevealData {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]types.IssueRevealData{}, r.history...)
+}
+
+// handleHistory serves GET /api/history?room=<slug>&format=json|csv|md
+func (s *Server) handleHistory(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	slug, err := normalizeRoomSlug(req.URL.Query().Get("room"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var rounds []types.IssueRevealData
+	if room := s.getRoom(slug); room != nil {
+		rounds = room.getHistory()
+	} else if snapshot, ok := s.loadSnapshot(slug); ok {
+		// The room closed, but its history survives on disk
+		rounds = snapshot.History
+	} else {
+		http.Error(w, fmt.Sprintf("room %q not found", slug), http.StatusNotFound)
+		return
+	}
+	if rounds == nil {
+		rounds = make([]types.IssueRevealData, 0)
+	}
+
+	switch format := req.URL.Query().Get("format"); format {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(historyResponse{Room: slug, Rounds: rounds}); err != nil {
+			s.log.Warn("Error writing history", "room", slug, "err", err)
+		}
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="history-%s.csv"`, slug))
+		if err := writeHistoryCSV(w, rounds); err != nil {
+			s.log.Warn("Error writing history", "room", slug, "err", err)
+		}
+	case "md":
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="history-%s.md"`, slug))
+		fmt.Fprint(w, formatHistoryMarkdown(slug, rounds))
+	default:
+		http.Error(w, fmt.Sprintf("unsupported format %q, use json, csv or md", format), http.StatusBadRequest)
+	}
+}
+
+// formatVotes renders the votes of a round as "Alice: 5, Bob: 8"
+func formatVotes(estimates []types.UserEstimate) string {
+	votes := make([]string, 0, len(estimates))
+	for _, est := range estimates {
+		votes = append(votes, est.User+": "+est.Estimate)
+	}
+	return strings.Join(votes, ", ")
+}
+
+// issueLabel names the issue of a round, preferring the Linear identifier
+func issueLabel(entry types.IssueRevealData) string {
+	if entry.IssueIdentifier != "" {
+		return entry.IssueIdentifier + " " + entry.IssueTitle
+	}
+	return entry.IssueTitle
+}
+
+// writeHistoryCSV writes one row per round
+func writeHistoryCSV(w io.Writer, rounds []types.IssueRevealData) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"round", "issue_identifier", "issue_title", "host", "started_at", "revealed_at", "estimate", "rounded_estimate", "votes"})
+	for _, entry := range rounds {
+		startedAt := ""
+		if !entry.StartedAt.IsZero() {
+			startedAt = entry.StartedAt.Format(time.RFC3339)
+		}
+		roundedEstimate := ""
+		if entry.RoundedEstimate != nil {
+			roundedEstimate = strconv.FormatInt(*entry.RoundedEstimate, 10)
+		}
+		writer.Write([]string{
+			strconv.Itoa(entry.Round),
+			entry.IssueIdentifier,
+			entry.IssueTitle,
+			entry.Host,
+			startedAt,
+			entry.RevealedAt.Format(time.RFC3339),
+			entry.Average,
+			roundedEstimate,
+			formatVotes(entry.Estimates),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// formatHistoryMarkdown renders the history as a table for sprint notes
+func formatHistoryMarkdown(slug string, rounds []types.IssueRevealData) string {
+	escape := strings.NewReplacer("|", `\|`, "\n", " ").Replace
+
+	var md strings.Builder
+	md.WriteString(fmt.Sprintf("# Refinement summary: %s\n\n", slug))
+	if len(rounds) == 0 {
+		md.WriteString("No rounds have been revealed yet.\n")
+		return md.String()
+	}
+
+	md.WriteString("| # | Issue | Estimate | Votes | Host | Revealed |\n")
+	md.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+	for _, entry := range rounds {
+		md.WriteString(fmt.Sprintf("| %d | %s | %s | %s | %s | %s |\n",
+			entry.Round,
+			escape(issueLabel(entry)),
+			escape(entry.Average),
+			escape(formatVotes(entry.Estimates)),
+			escape(entry.Host),
+			entry.RevealedAt.Format("2006-01-02 15:04"),
+		))
+	}
+	return md.String()
+}
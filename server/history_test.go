@@ -0,0 +1,203 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jcpsimmons/poker/types"
+	"github.com/stretchr/testify/require"
+)
+
+// fetchHistory fetches /api/history and returns the response and its body
+func fetchHistory(t *testing.T, serverURL string, query string) (*http.Response, string) {
+	resp, err := http.Get(serverURL + "/api/history?" + query)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+// playRounds runs two revealed rounds in a room with a host and a player
+func playRounds(t *testing.T, serverURL string, room string) {
+	host := joinTestRoom(t, serverURL, room, "Alice", true)
+	defer host.Close()
+	player := joinTestRoom(t, serverURL, room, "Bob", false)
+	defer player.Close()
+
+	rounds := []struct {
+		issue string
+		host  string
+		bob   string
+	}{
+		{issue: "Login page", host: "3", bob: "5"},
+		{issue: "Logout | cleanup", host: "8", bob: "?"},
+	}
+	for _, round := range rounds {
+		require.NoError(t, host.WriteJSON(types.Message{Type: types.NewIssue, Payload: round.issue}))
+		require.NoError(t, host.WriteJSON(types.Message{Type: types.Estimate, Payload: round.host}))
+		require.NoError(t, player.WriteJSON(types.Message{Type: types.Estimate, Payload: round.bob}))
+		player.drain()
+		require.NoError(t, host.WriteJSON(types.Message{Type: types.Reveal}))
+		host.drain()
+		require.NoError(t, host.WriteJSON(types.Message{Type: types.Reset}))
+		host.drain()
+	}
+}
+
+func TestHistory_RecordsRevealedRounds(t *testing.T) {
+	ts := newRoomTestServer(t)
+	playRounds(t, ts.URL, "squad-a")
+
+	resp, body := fetchHistory(t, ts.URL, "room=squad-a")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	var history historyResponse
+	require.NoError(t, json.Unmarshal([]byte(body), &history))
+	require.Equal(t, "squad-a", history.Room)
+	require.Len(t, history.Rounds, 2)
+
+	first := history.Rounds[0]
+	require.Equal(t, 1, first.Round)
+	require.Equal(t, "Login page", first.IssueTitle)
+	require.Equal(t, "Alice", first.Host)
+	require.Equal(t, []types.UserEstimate{
+		{User: "Alice", Estimate: "3"},
+		{User: "Bob", Estimate: "5"},
+	}, first.Estimates)
+	require.Equal(t, "3", first.Average)
+	require.NotNil(t, first.RoundedEstimate)
+	require.Equal(t, int64(3), *first.RoundedEstimate)
+	require.False(t, first.StartedAt.IsZero())
+	require.False(t, first.RevealedAt.Before(first.StartedAt))
+
+	second := history.Rounds[1]
+	require.Equal(t, 2, second.Round)
+	require.Equal(t, "8", second.Average, "special cards are not averaged")
+	require.True(t, second.Estimates[1].Special)
+}
+
+func TestHistory_RejectedRevealKeepsRound(t *testing.T) {
+	ts := newRoomTestServer(t)
+	playRounds(t, ts.URL, "squad-a")
+
+	// The last round is closed, so revealing it again is rejected rather
+	// than recording an empty copy over it
+	room := getRoom("squad-a")
+	before := room.getHistory()
+	host := joinTestRoom(t, ts.URL, "squad-a", "Carol", true)
+	defer host.Close()
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Reveal}))
+	require.Nil(t, findMessage(host.drain(), types.RevealData))
+
+	require.Equal(t, before, room.getHistory())
+	require.Len(t, before, 2)
+	require.Len(t, before[1].Estimates, 2)
+}
+
+func TestHistory_NoNumericVotes(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Estimate, Payload: "?"}))
+	host.drain()
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Reveal}))
+	host.drain()
+
+	history := getRoom("squad-a").getHistory()
+	require.Len(t, history, 1)
+	require.Empty(t, history[0].Average)
+	require.Nil(t, history[0].RoundedEstimate, "no average is recorded, not a 0")
+
+	resp, body := fetchHistory(t, ts.URL, "room=squad-a&format=csv")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, "", records[1][7])
+}
+
+func TestHistory_Formats(t *testing.T) {
+	ts := newRoomTestServer(t)
+	playRounds(t, ts.URL, "squad-a")
+
+	resp, body := fetchHistory(t, ts.URL, "room=squad-a&format=csv")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, resp.Header.Get("Content-Type"), "text/csv")
+	require.Contains(t, resp.Header.Get("Content-Disposition"), "history-squad-a.csv")
+
+	records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	require.Equal(t, "round", records[0][0])
+	require.Equal(t, []string{"1", "", "Login page", "Alice"}, records[1][:4])
+	require.Equal(t, "Alice: 3, Bob: 5", records[1][8])
+
+	resp, body = fetchHistory(t, ts.URL, "room=squad-a&format=md")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, resp.Header.Get("Content-Type"), "text/markdown")
+	require.Contains(t, body, "# Refinement summary: squad-a")
+	require.Contains(t, body, "| 1 | Login page | 3 | Alice: 3, Bob: 5 | Alice |")
+	require.Contains(t, body, `| 2 | Logout \| cleanup | 8 | Alice: 8, Bob: ? | Alice |`)
+}
+
+func TestHistory_Errors(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	resp, _ := fetchHistory(t, ts.URL, "format=xml")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, _ = fetchHistory(t, ts.URL, "room=Bad%20Room")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, _ = fetchHistory(t, ts.URL, "room=nowhere")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err := http.Post(ts.URL+"/api/history", "application/json", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	// The default room always exists, with an empty history at first
+	resp, body := fetchHistory(t, ts.URL, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.JSONEq(t, `{"room":"default","rounds":[]}`, body)
+}
+
+func TestHistory_RevealingAgainReplacesRound(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Estimate, Payload: "5"}))
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Reveal}))
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Reveal}))
+	host.drain()
+
+	require.Len(t, getRoom("squad-a").getHistory(), 1)
+}
+
+func TestHistory_AvailableAfterRoomCloses(t *testing.T) {
+	ts := newRoomTestServer(t)
+	SetResumeGracePeriod(time.Millisecond)
+	require.NoError(t, SetDataDir(t.TempDir()))
+
+	playRounds(t, ts.URL, "squad-a")
+	require.Eventually(t, func() bool {
+		return getRoom("squad-a") == nil
+	}, 2*time.Second, 20*time.Millisecond)
+
+	resp, body := fetchHistory(t, ts.URL, "room=squad-a")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var history historyResponse
+	require.NoError(t, json.Unmarshal([]byte(body), &history))
+	require.Len(t, history.Rounds, 2)
+}
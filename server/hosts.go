@@ -0,0 +1,225 @@
+package server
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jcpsimmons/poker/messaging"
+	"github.com/jcpsimmons/poker/types"
+)
+
+// defaultHostRecoveryTimeout is how long a room waits for its last host to
+// come back before promoting a player. It never ends before the host's
+// resume grace period does.
+const defaultHostRecoveryTimeout = defaultResumeGracePeriod
+
+// Reasons a role changed, as sent in RoleChangedPayload
+const (
+	roleChangeTransfer = "transfer"
+	roleChangeCoHost   = "cohost"
+	roleChangePromoted = "promoted"
+	roleChangeReplaced = "replaced"
+)
+
+var (
+	errParticipantNotFound = errors.New("no participant with that name is in the session")
+	errObserverCannotHost  = errors.New("observers can't be hosts, they have to join as a player first")
+	errAlreadyHost         = errors.New("that participant is already a host")
+	errNotHost             = errors.New("that participant is not a host")
+	errLastHost            = errors.New("the session needs at least one host, transfer the role instead")
+)
+
+// SetHostRecoveryTimeout configures how long a room without hosts waits
+// before promoting its longest-connected player
+func SetHostRecoveryTimeout(d time.Duration) {
+	defaultServer().setHostRecoveryTimeout(d)
+}
+
+func (s *Server) setHostRecoveryTimeout(d time.Duration) {
+	if d > 0 {
+		s.hostRecoveryTimeout.Store(int64(d))
+	}
+}
+
+// hostCountUnlocked counts the connected hosts (caller must hold mutex)
+func (r *Room) hostCountUnlocked() int {
+	count := 0
+	for client := range r.clients {
+		if client.IsHost {
+			count++
+		}
+	}
+	return count
+}
+
+// hostCandidateUnlocked finds the participant a host role can be given to
+// (caller must hold mutex)
+func (r *Room) hostCandidateUnlocked(username string) (*Client, error) {
+	target := r.findClientUnlocked(username)
+	switch {
+	case target == nil:
+		return nil, errParticipantNotFound
+	case target.IsObserver:
+		return nil, errObserverCannotHost
+	case target.IsHost:
+		return nil, errAlreadyHost
+	}
+	return target, nil
+}
+
+// handleTransferHost hands the sender's host role to another participant
+func (r *Room) handleTransferHost(payload types.TransferHostPayload, sender *Client) error {
+	r.mutex.Lock()
+	target, err := r.hostCandidateUnlocked(payload.Username)
+	if err != nil {
+		r.mutex.Unlock()
+		return newCommandError(types.RoleError, err)
+	}
+	sender.IsHost = false
+	target.IsHost = true
+	r.mutex.Unlock()
+
+	r.log.Info("Host handed over the host role", "user", sender.UserID, "target", target.UserID)
+	r.announceRoleChanges(roleChangeTransfer, sender, sender, target)
+	return nil
+}
+
+// handleSetCoHost adds a co-host, or takes the host role away from one
+func (r *Room) handleSetCoHost(payload types.CoHostPayload, sender *Client) error {
+	r.mutex.Lock()
+	var target *Client
+	var err error
+	if payload.CoHost {
+		target, err = r.hostCandidateUnlocked(payload.Username)
+	} else {
+		target = r.findClientUnlocked(payload.Username)
+		switch {
+		case target == nil:
+			err = errParticipantNotFound
+		case !target.IsHost:
+			err = errNotHost
+		case r.hostCountUnlocked() == 1:
+			err = errLastHost
+		}
+	}
+	if err != nil {
+		r.mutex.Unlock()
+		return newCommandError(types.RoleError, err)
+	}
+	target.IsHost = payload.CoHost
+	r.mutex.Unlock()
+
+	r.log.Info("Host set co-host", "user", sender.UserID, "target", target.UserID, "coHost", payload.CoHost)
+	r.announceRoleChanges(roleChangeCoHost, sender, target)
+	return nil
+}
+
+// announceRoleChanges tells everybody about new roles and gives the changed
+// participants fresh resume tokens, so they come back with their new role.
+// Every role change goes through here, which makes their older tokens stale.
+func (r *Room) announceRoleChanges(reason string, by *Client, changed ...*Client) {
+	payload := types.RoleChangedPayload{Reason: reason}
+
+	r.mutex.Lock()
+	if by != nil {
+		payload.By = by.UserID
+	}
+	for _, client := range changed {
+		r.bumpRoleRevisionUnlocked(client.UserID)
+		payload.Changes = append(payload.Changes, types.ParticipantRole{
+			Username: client.UserID,
+			Role:     string(client.role()),
+		})
+	}
+	r.mutex.Unlock()
+
+	message := types.RoleChangedMessage{
+		Type:    types.RoleChanged,
+		Payload: payload,
+	}
+	r.broadcast(messaging.MarshallMessage(message), by)
+	r.broadcastVoteStatus(by)
+
+	for _, client := range changed {
+		r.sendResumeToken(client)
+	}
+}
+
+// scheduleHostRecoveryUnlocked starts the countdown to promoting a player
+// after the last host left (caller must hold mutex)
+func (r *Room) scheduleHostRecoveryUnlocked() {
+	if r.hostRecoveryTimer != nil {
+		return
+	}
+	timeout := time.Duration(r.server.hostRecoveryTimeout.Load())
+	if grace := r.server.gracePeriod(); timeout < grace {
+		// The host can still resume, don't hand their role away yet
+		timeout = grace
+	}
+	generation := r.hostRecoveryGeneration
+	r.hostRecoveryTimer = time.AfterFunc(timeout, func() {
+		r.do(func() { r.recoverHost(generation) })
+	})
+	r.log.Info("Room has no host, promoting a player unless one returns", "timeout", timeout)
+}
+
+// bumpRoleRevisionUnlocked makes the resume tokens issued to username so far
+// stale, so they resume as a player (caller must hold mutex)
+func (r *Room) bumpRoleRevisionUnlocked(username string) {
+	r.roleRevisions[strings.ToLower(username)]++
+}
+
+// replacedSinceUnlocked reports whether a player was promoted to host after
+// the given time while the room still has a host. A host who left before
+// then comes back as a player (caller must hold mutex)
+func (r *Room) replacedSinceUnlocked(left time.Time) bool {
+	return !r.hostPromotedAt.IsZero() && !r.hostPromotedAt.Before(left) && r.hasHostUnlocked()
+}
+
+// stopHostRecoveryUnlocked cancels a pending promotion (caller must hold mutex)
+func (r *Room) stopHostRecoveryUnlocked() {
+	if r.hostRecoveryTimer == nil {
+		return
+	}
+	r.hostRecoveryTimer.Stop()
+	r.hostRecoveryTimer = nil
+	r.hostRecoveryGeneration++
+}
+
+// recoverHost promotes the longest-connected player if the room is still
+// without a host once the timeout has passed
+func (r *Room) recoverHost(generation int) {
+	r.mutex.Lock()
+	if r.hostRecoveryTimer == nil || r.hostRecoveryGeneration != generation {
+		r.mutex.Unlock()
+		return
+	}
+	r.hostRecoveryTimer = nil
+	r.hostRecoveryGeneration++
+	if r.hasHostUnlocked() {
+		r.mutex.Unlock()
+		return
+	}
+
+	var candidate *Client
+	for client := range r.clients {
+		if !client.isVoter() {
+			continue
+		}
+		if candidate == nil || client.JoinedAt.Before(candidate.JoinedAt) {
+			candidate = client
+		}
+	}
+	if candidate == nil {
+		r.mutex.Unlock()
+		r.log.Info("Room has no host and nobody to promote")
+		return
+	}
+	candidate.IsHost = true
+	r.hostPromotedAt = time.Now()
+	r.mutex.Unlock()
+
+	r.log.Info("Promoted player to host", "user", candidate.UserID)
+	r.announceRoleChanges(roleChangePromoted, nil, candidate)
+}
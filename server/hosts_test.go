@@ -0,0 +1,358 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jcpsimmons/poker/types"
+	"github.com/stretchr/testify/require"
+)
+
+// roleChanges returns the changes and reason of the last roleChanged message
+func roleChanges(t *testing.T, messages []map[string]interface{}) ([]interface{}, string) {
+	msg := lastMessage(messages, types.RoleChanged)
+	require.NotNil(t, msg, "expected a roleChanged message")
+	payload := msg["payload"].(map[string]interface{})
+	return payload["changes"].([]interface{}), payload["reason"].(string)
+}
+
+// isHostIn reports whether the named participant is a host of the room
+func isHostIn(t *testing.T, room string, username string) bool {
+	r := getRoom(room)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	client := r.findClientUnlocked(username)
+	require.NotNil(t, client)
+	return client.IsHost
+}
+
+func TestHosts_Transfer(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	alice := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer alice.Close()
+	bob := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+	defer bob.Close()
+	alice.drain()
+
+	require.NoError(t, alice.WriteJSON(types.TransferHostMessage{
+		Type:    types.TransferHost,
+		Payload: types.TransferHostPayload{Username: "bob"},
+	}))
+
+	messages := bob.drain()
+	changes, reason := roleChanges(t, messages)
+	require.Equal(t, roleChangeTransfer, reason)
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"username": "Alice", "role": "player"},
+		map[string]interface{}{"username": "Bob", "role": "host"},
+	}, changes)
+	require.NotNil(t, findMessage(messages, types.ResumeToken), "Bob's token carries the new role")
+	require.False(t, isHostIn(t, "squad-a", "Alice"))
+	require.True(t, isHostIn(t, "squad-a", "Bob"))
+
+	// Alice has handed over the controls
+	require.NoError(t, alice.WriteJSON(types.Message{Type: types.Reveal}))
+	require.NotNil(t, findMessage(alice.drain(), types.PermissionDenied))
+}
+
+func TestHosts_CoHosts(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	alice := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer alice.Close()
+	bob := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+	defer bob.Close()
+	alice.drain()
+
+	setCoHost := func(client *roomTestClient, username string, coHost bool) {
+		require.NoError(t, client.WriteJSON(types.CoHostMessage{
+			Type:    types.SetCoHost,
+			Payload: types.CoHostPayload{Username: username, CoHost: coHost},
+		}))
+	}
+
+	setCoHost(alice, "Bob", true)
+	messages := bob.drain()
+	changes, reason := roleChanges(t, messages)
+	require.Equal(t, roleChangeCoHost, reason)
+	require.Len(t, changes, 1)
+	require.True(t, isHostIn(t, "squad-a", "Alice"))
+	require.True(t, isHostIn(t, "squad-a", "Bob"))
+
+	status := lastMessage(messages, types.VoteStatus)
+	for _, voter := range status["payload"].(map[string]interface{})["voters"].([]interface{}) {
+		require.Equal(t, true, voter.(map[string]interface{})["isHost"])
+	}
+
+	// Both hosts can drive the session
+	require.NoError(t, bob.WriteJSON(types.Message{Type: types.NewIssue, Payload: "CDP-1"}))
+	require.Nil(t, findMessage(bob.drain(), types.PermissionDenied))
+
+	// Alice steps down, leaving Bob in charge
+	setCoHost(alice, "Alice", false)
+	alice.drain()
+	require.False(t, isHostIn(t, "squad-a", "Alice"))
+
+	// The last host can't step down
+	setCoHost(bob, "Bob", false)
+	require.NotNil(t, findMessage(bob.drain(), types.RoleError))
+	require.True(t, isHostIn(t, "squad-a", "Bob"))
+}
+
+func TestHosts_RoleErrors(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	alice := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer alice.Close()
+	observer := joinObserver(t, ts.URL, "squad-a", "Olivia")
+	defer observer.Close()
+	alice.drain()
+
+	tests := []struct {
+		name    string
+		message interface{}
+		want    error
+	}{
+		{
+			name:    "transfer to unknown participant",
+			message: types.TransferHostMessage{Type: types.TransferHost, Payload: types.TransferHostPayload{Username: "Nobody"}},
+			want:    errParticipantNotFound,
+		},
+		{
+			name:    "transfer to an observer",
+			message: types.TransferHostMessage{Type: types.TransferHost, Payload: types.TransferHostPayload{Username: "Olivia"}},
+			want:    errObserverCannotHost,
+		},
+		{
+			name:    "transfer to yourself",
+			message: types.TransferHostMessage{Type: types.TransferHost, Payload: types.TransferHostPayload{Username: "Alice"}},
+			want:    errAlreadyHost,
+		},
+		{
+			name:    "remove a co-host who isn't one",
+			message: types.CoHostMessage{Type: types.SetCoHost, Payload: types.CoHostPayload{Username: "Olivia"}},
+			want:    errNotHost,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, alice.WriteJSON(tt.message))
+			msg := findMessage(alice.drain(), types.RoleError)
+			require.NotNil(t, msg)
+			require.Equal(t, tt.want.Error(), msg["payload"])
+		})
+	}
+	require.True(t, isHostIn(t, "squad-a", "Alice"))
+}
+
+func TestHosts_PromotesLongestConnectedPlayer(t *testing.T) {
+	ts := newRoomTestServer(t)
+	SetHostRecoveryTimeout(100 * time.Millisecond)
+	SetResumeGracePeriod(50 * time.Millisecond)
+
+	alice := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	observer := joinObserver(t, ts.URL, "squad-a", "Olivia")
+	defer observer.Close()
+	bob := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+	defer bob.Close()
+	carol := joinTestRoom(t, ts.URL, "squad-a", "Carol", false)
+	defer carol.Close()
+	carol.drain()
+
+	alice.Close()
+	require.Eventually(t, func() bool {
+		return isHostIn(t, "squad-a", "Bob")
+	}, 2*time.Second, 20*time.Millisecond)
+	require.False(t, isHostIn(t, "squad-a", "Carol"))
+
+	changes, reason := roleChanges(t, carol.drain())
+	require.Equal(t, roleChangePromoted, reason)
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"username": "Bob", "role": "host"},
+	}, changes)
+}
+
+func TestHosts_ReturningHostCancelsPromotion(t *testing.T) {
+	ts := newRoomTestServer(t)
+	SetHostRecoveryTimeout(300 * time.Millisecond)
+	SetResumeGracePeriod(300 * time.Millisecond)
+
+	alice := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	token := resumeTokenFrom(t, alice.welcome)
+	bob := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+	defer bob.Close()
+
+	alice.Close()
+	room := getRoom("squad-a")
+	require.Eventually(t, func() bool {
+		room.mutex.Lock()
+		defer room.mutex.Unlock()
+		return len(room.slots) == 1
+	}, time.Second, 20*time.Millisecond)
+
+	resumed := resumeTestRoom(t, ts.URL, "squad-a", token)
+	defer resumed.Close()
+
+	time.Sleep(500 * time.Millisecond)
+	require.True(t, isHostIn(t, "squad-a", "Alice"))
+	require.False(t, isHostIn(t, "squad-a", "Bob"))
+	require.Nil(t, findMessage(bob.drain(), types.RoleChanged))
+}
+
+func TestHosts_RecoveryWaitsForGracePeriod(t *testing.T) {
+	ts := newRoomTestServer(t)
+	SetHostRecoveryTimeout(50 * time.Millisecond)
+	SetResumeGracePeriod(400 * time.Millisecond)
+
+	alice := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	bob := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+	defer bob.Close()
+
+	alice.Close()
+	time.Sleep(200 * time.Millisecond)
+	require.False(t, isHostIn(t, "squad-a", "Bob"), "Alice can still resume")
+
+	require.Eventually(t, func() bool {
+		return isHostIn(t, "squad-a", "Bob")
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestHosts_CoHostResumesAsHost(t *testing.T) {
+	tests := []struct {
+		name     string
+		grace    time.Duration
+		slotHeld bool
+	}{
+		{name: "within grace period", grace: time.Minute, slotHeld: true},
+		{name: "after grace period", grace: 50 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newRoomTestServer(t)
+			SetResumeGracePeriod(tt.grace)
+
+			alice := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+			defer alice.Close()
+			bob := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+			require.NoError(t, alice.WriteJSON(types.CoHostMessage{
+				Type:    types.SetCoHost,
+				Payload: types.CoHostPayload{Username: "Bob", CoHost: true},
+			}))
+			token := resumeTokenFrom(t, bob.drain())
+			alice.drain()
+
+			bob.Close()
+			room := getRoom("squad-a")
+			require.Eventually(t, func() bool {
+				room.mutex.Lock()
+				defer room.mutex.Unlock()
+				return room.findClientUnlocked("Bob") == nil && (len(room.slots) == 1) == tt.slotHeld
+			}, time.Second, 10*time.Millisecond)
+
+			resumed := resumeTestRoom(t, ts.URL, "squad-a", token)
+			defer resumed.Close()
+			require.Nil(t, findMessage(resumed.welcome, types.JoinError))
+			require.True(t, isHostIn(t, "squad-a", "Bob"))
+			require.True(t, isHostIn(t, "squad-a", "Alice"))
+			require.Nil(t, findMessage(alice.drain(), types.RoleChanged))
+		})
+	}
+}
+
+func TestHosts_ReplacedHostReturnsAsPlayer(t *testing.T) {
+	ts := newRoomTestServer(t)
+	SetHostRecoveryTimeout(50 * time.Millisecond)
+	SetResumeGracePeriod(50 * time.Millisecond)
+
+	alice := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	token := resumeTokenFrom(t, alice.welcome)
+	bob := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+	defer bob.Close()
+
+	alice.Close()
+	require.Eventually(t, func() bool {
+		return isHostIn(t, "squad-a", "Bob")
+	}, 2*time.Second, 20*time.Millisecond)
+	bob.drain()
+
+	resumed := resumeTestRoom(t, ts.URL, "squad-a", token)
+	defer resumed.Close()
+	require.False(t, isHostIn(t, "squad-a", "Alice"))
+	require.True(t, isHostIn(t, "squad-a", "Bob"))
+
+	changes, reason := roleChanges(t, bob.drain())
+	require.Equal(t, roleChangeReplaced, reason)
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"username": "Alice", "role": "player"},
+	}, changes)
+}
+
+func TestHosts_StaleTokenResumesAsPlayer(t *testing.T) {
+	setCoHost := func(t *testing.T, client *roomTestClient, username string, coHost bool) {
+		require.NoError(t, client.WriteJSON(types.CoHostMessage{
+			Type:    types.SetCoHost,
+			Payload: types.CoHostPayload{Username: username, CoHost: coHost},
+		}))
+	}
+
+	tests := []struct {
+		name string
+		// setup leaves the returned user's host token stale and closes
+		// their connection
+		setup func(t *testing.T, alice, bob *roomTestClient) (token, username string)
+	}{
+		{
+			name: "host role transferred",
+			setup: func(t *testing.T, alice, bob *roomTestClient) (string, string) {
+				token := resumeTokenFrom(t, alice.welcome)
+				require.NoError(t, alice.WriteJSON(types.TransferHostMessage{
+					Type:    types.TransferHost,
+					Payload: types.TransferHostPayload{Username: "Bob"},
+				}))
+				bob.drain()
+				alice.Close()
+				return token, "Alice"
+			},
+		},
+		{
+			name: "co-host removed",
+			setup: func(t *testing.T, alice, bob *roomTestClient) (string, string) {
+				setCoHost(t, alice, "Bob", true)
+				token := resumeTokenFrom(t, bob.drain())
+				setCoHost(t, alice, "Bob", false)
+				bob.drain()
+				bob.Close()
+				return token, "Bob"
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newRoomTestServer(t)
+			SetResumeGracePeriod(50 * time.Millisecond)
+
+			alice := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+			defer alice.Close()
+			bob := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+			defer bob.Close()
+			alice.drain()
+
+			token, username := tt.setup(t, alice, bob)
+			room := getRoom("squad-a")
+			require.Eventually(t, func() bool {
+				room.mutex.Lock()
+				defer room.mutex.Unlock()
+				return room.findClientUnlocked(username) == nil && len(room.slots) == 0
+			}, time.Second, 10*time.Millisecond)
+
+			resumed := resumeTestRoom(t, ts.URL, "squad-a", token)
+			defer resumed.Close()
+			require.False(t, isHostIn(t, "squad-a", username), "an old token can't bring the host role back")
+			room.mutex.Lock()
+			defer room.mutex.Unlock()
+			require.Equal(t, 1, room.hostCountUnlocked())
+		})
+	}
+}
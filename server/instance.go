@@ -0,0 +1,235 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jcpsimmons/poker/linear"
+	"github.com/jcpsimmons/poker/types"
+)
+
+// Options configures a Server. Zero values select the defaults.
+type Options struct {
+	// AuthPassword protects the WebSocket and the history export with
+	// HTTP Basic authentication. AuthUsername defaults to "admin".
+	AuthUsername string
+	AuthPassword string
+
+	// Linear posts results and estimates for LinearIssues, which seed the
+	// default room's queue. Its API calls are counted in the metrics from
+	// New on, so issues can also be fetched afterwards and loaded with
+	// SetLinearIssues.
+	Linear       *linear.LinearClient
+	LinearIssues []types.LinearIssue
+
+	// Deck is a deck spec as accepted by ParseDeck (default: fibonacci)
+	Deck string
+
+	// Assets is the web app served at / (default: the app built into the
+	// binary)
+	Assets http.FileSystem
+
+	// NoWebApp lets Start run without a web app, e.g. while the Vite dev
+	// server serves it
+	NoWebApp bool
+
+	// Logger receives the server's logs (default: slog.Default())
+	Logger *slog.Logger
+
+	// AutoReveal reveals the votes AutoRevealDelay after everybody voted
+	AutoReveal      bool
+	AutoRevealDelay time.Duration
+
+	HostRecoveryTimeout time.Duration
+	ResumeGracePeriod   time.Duration
+
+	// Keepalive and flow control, see SetKeepalive, SetWriteTimeout,
+	// SetMaxMessageSize and SetSendQueueSize
+	PingInterval   time.Duration
+	PongTimeout    time.Duration
+	WriteTimeout   time.Duration
+	MaxMessageSize int64
+	SendQueueSize  int
+
+	// DataDir enables session persistence, see SetDataDir
+	DataDir string
+
+	// Metrics serves Prometheus metrics at /metrics
+	Metrics bool
+
+	// APIToken enables the REST API at /api/rooms for requests carrying
+	// it as a bearer token
+	APIToken string
+
+	// Version is reported by /api/version (default: the module version
+	// the binary was built from)
+	Version string
+}
+
+// Server is an isolated planning poker instance: its rooms, settings and
+// HTTP routes are not shared with any other Server. It is an http.Handler
+// serving the web app at /, the WebSocket at /ws, the history export at
+// /api/history, the /healthz, /readyz and /api/version probes, and the
+// REST API at /api/rooms when enabled.
+type Server struct {
+	log            *slog.Logger
+	mux            *http.ServeMux
+	assets         http.FileSystem
+	noWebApp       bool
+	metrics        *metrics
+	metricsEnabled bool
+	version        string
+
+	// apiToken authenticates REST API requests. The API is disabled when
+	// empty.
+	apiToken string
+
+	// linear is the Linear client, if any, checked by /readyz
+	linear      atomic.Pointer[linear.LinearClient]
+	linearCheck linearCheck
+
+	// Basic authentication, enabled once a password is set
+	authUsername string
+	authPassword string
+	authEnabled  bool
+
+	// roomsMutex guards rooms and the defaults new rooms are created with.
+	// When both are needed it must be acquired before a Room's mutex.
+	roomsMutex        sync.Mutex
+	rooms             map[string]*Room
+	deck              Deck
+	autoRevealEnabled bool
+	autoRevealDelay   time.Duration
+
+	// Settings read by connection goroutines and timers, so they are
+	// atomic. Durations are in nanoseconds.
+	hostRecoveryTimeout atomic.Int64
+	resumeGracePeriod   atomic.Int64
+	pingInterval        atomic.Int64
+	pongTimeout         atomic.Int64
+	writeTimeout        atomic.Int64
+	maxMessageSize      atomic.Int64
+	sendQueueSize       atomic.Int64
+
+	// dataDir holds the directory room snapshots are stored in.
+	// Persistence is disabled when empty.
+	dataDir atomic.Value
+
+	// resumeSecret signs resume tokens. It is random per server unless a
+	// data directory is configured, in which case it survives restarts.
+	resumeSecret []byte
+
+	// closed is set once Shutdown starts; new connections are refused
+	closed atomic.Bool
+}
+
+// New creates a Server from opts
+func New(opts Options) (*Server, error) {
+	assets := opts.Assets
+	if assets == nil {
+		assets = embeddedAssets()
+	}
+	s := newServer(opts.Logger, assets)
+	s.noWebApp = opts.NoWebApp
+
+	if opts.AuthPassword != "" {
+		username := opts.AuthUsername
+		if username == "" {
+			username = "admin"
+		}
+		s.setBasicAuth(username, opts.AuthPassword)
+	}
+	if err := s.setDeck(opts.Deck); err != nil {
+		return nil, fmt.Errorf("invalid deck: %w", err)
+	}
+	if opts.AutoReveal || opts.AutoRevealDelay > 0 {
+		delay := opts.AutoRevealDelay
+		if delay <= 0 {
+			delay = defaultAutoRevealDelay
+		}
+		s.setAutoReveal(opts.AutoReveal, delay)
+	}
+	s.setHostRecoveryTimeout(opts.HostRecoveryTimeout)
+	s.setResumeGracePeriod(opts.ResumeGracePeriod)
+	if opts.PingInterval > 0 || opts.PongTimeout > 0 {
+		ping, timeout := opts.PingInterval, opts.PongTimeout
+		if ping <= 0 {
+			ping = defaultPingInterval
+		}
+		if timeout <= 0 {
+			timeout = defaultPongTimeout
+		}
+		if err := s.setKeepalive(ping, timeout); err != nil {
+			return nil, fmt.Errorf("invalid keepalive: %w", err)
+		}
+	}
+	s.setWriteTimeout(opts.WriteTimeout)
+	s.setMaxMessageSize(opts.MaxMessageSize)
+	s.setSendQueueSize(opts.SendQueueSize)
+
+	// Restore persisted session state before Linear seeds the queue
+	if err := s.setDataDir(opts.DataDir); err != nil {
+		return nil, err
+	}
+	s.useLinear(opts.Linear)
+	if opts.Linear != nil && len(opts.LinearIssues) > 0 {
+		s.SetLinearIssues(opts.LinearIssues, opts.Linear)
+	}
+	if opts.Metrics {
+		s.metricsEnabled = true
+		s.mux.HandleFunc("/metrics", s.handleMetrics)
+	}
+	if opts.APIToken != "" {
+		s.apiToken = opts.APIToken
+		s.registerAPI()
+	}
+	if opts.Version != "" {
+		s.version = opts.Version
+	}
+	return s, nil
+}
+
+// newServer creates a Server with the default settings
+func newServer(logger *slog.Logger, assets http.FileSystem) *Server {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	s := &Server{
+		log:             logger,
+		mux:             http.NewServeMux(),
+		assets:          assets,
+		metrics:         newMetrics(),
+		version:         buildVersion(),
+		authUsername:    "admin",
+		deck:            builtinDecks[defaultDeckName],
+		autoRevealDelay: defaultAutoRevealDelay,
+		resumeSecret:    newResumeSecret(logger),
+	}
+	s.hostRecoveryTimeout.Store(int64(defaultHostRecoveryTimeout))
+	s.resumeGracePeriod.Store(int64(defaultResumeGracePeriod))
+	s.resetKeepalive()
+	s.sendQueueSize.Store(defaultSendQueueSize)
+	s.dataDir.Store("")
+	s.rooms = map[string]*Room{defaultRoomSlug: s.newRoom(defaultRoomSlug)}
+
+	// Static files need no auth, so users can load the app and log in
+	s.mux.Handle("/", assetHandler(assets))
+	// WebSocket endpoint with auth middleware (only protect the WS connection)
+	s.mux.HandleFunc("/ws", s.basicAuthMiddleware(s.handleWebSocket))
+	// Session history export, protected like the WebSocket since it contains votes
+	s.mux.HandleFunc("/api/history", s.basicAuthMiddleware(s.handleHistory))
+	// Probes and version checks happen before logging in
+	s.mux.HandleFunc("/healthz", s.handleHealth)
+	s.mux.HandleFunc("/readyz", s.handleReady)
+	s.mux.HandleFunc("/api/version", s.handleVersion)
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
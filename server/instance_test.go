@@ -0,0 +1,113 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jcpsimmons/poker/types"
+	"github.com/stretchr/testify/require"
+)
+
+// newInstanceTestServer serves a new Server built from opts
+func newInstanceTestServer(t *testing.T, opts Options) (*Server, *httptest.Server) {
+	if opts.Logger == nil {
+		opts.Logger = slog.New(slog.DiscardHandler)
+	}
+	srv, err := New(opts)
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv)
+	t.Cleanup(func() {
+		ts.Close()
+		srv.Close()
+	})
+	return srv, ts
+}
+
+func TestNew_RejectsInvalidOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "unknown deck", opts: Options{Deck: "nope"}},
+		{name: "ping not shorter than timeout", opts: Options{PingInterval: time.Minute, PongTimeout: time.Second}},
+		{name: "data dir is a file", opts: Options{DataDir: "instance_test.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.opts)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestNew_InstancesAreIsolated(t *testing.T) {
+	_, tshirt := newInstanceTestServer(t, Options{Deck: "tshirt"})
+	_, fibonacci := newInstanceTestServer(t, Options{})
+
+	// The same name and room can be used on both
+	alice := joinTestRoom(t, tshirt.URL, "isolated", "Alice", true)
+	defer alice.Close()
+	other := joinTestRoom(t, fibonacci.URL, "isolated", "Alice", true)
+	defer other.Close()
+	require.NotNil(t, findMessage(other.welcome, types.ResumeToken), "join was accepted")
+
+	deck := findMessage(alice.welcome, types.DeckInfo)["payload"].(map[string]interface{})
+	require.Equal(t, "tshirt", deck["name"])
+	deck = findMessage(other.welcome, types.DeckInfo)["payload"].(map[string]interface{})
+	require.Equal(t, "fibonacci", deck["name"])
+
+	// Votes in one instance never reach the other
+	require.NoError(t, alice.WriteJSON(types.Message{Type: types.Estimate, Payload: "M"}))
+	require.NotNil(t, findMessage(alice.drain(), types.VoteStatus))
+	require.Empty(t, other.drain())
+
+	// Neither touches the package-level default server
+	require.Nil(t, getRoom("isolated"))
+}
+
+func TestNew_AuthAndAssets(t *testing.T) {
+	assets := fstest.MapFS{"index.html": {Data: []byte("<h1>poker</h1>")}}
+	_, ts := newInstanceTestServer(t, Options{AuthPassword: "secret", Assets: http.FS(assets)})
+
+	// The app loads without credentials
+	resp, err := http.Get(ts.URL + "/")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), "poker")
+
+	// The session doesn't
+	resp, err = http.Get(ts.URL + "/api/history")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/history", nil)
+	req.SetBasicAuth("admin", "secret")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestServer_CloseRefusesNewConnections(t *testing.T) {
+	srv, ts := newInstanceTestServer(t, Options{})
+
+	alice := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer alice.Close()
+	require.NoError(t, srv.Close())
+	require.NotNil(t, findMessage(alice.drain(), types.ServerShutdown))
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws?room=squad-a"
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	require.Error(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
@@ -46,8 +46,8 @@ func TestHandleJoin_ValidJoin(t *testing.T) {
 	err := conn.WriteJSON(joinMsg)
 	require.NoError(t, err)
 
-	// Read welcome messages (currentIssue, currentEstimate, participantCount, voteStatus, queueSync)
-	for i := 0; i < 5; i++ {
+	// Read welcome messages (currentIssue, currentEstimate, deck, roundState, resumeToken, participantCount, voteStatus, queueSync)
+	for i := 0; i < 8; i++ {
 		var msg map[string]interface{}
 		err := conn.ReadJSON(&msg)
 		require.NoError(t, err)
@@ -58,16 +58,16 @@ func TestHandleJoin_ValidJoin(t *testing.T) {
 	}
 
 	// Verify client was added
-	mutex.Lock()
-	clientCount := len(clients)
+	testRoom().mutex.Lock()
+	clientCount := len(testRoom().clients)
 	var foundClient *Client
-	for client := range clients {
+	for client := range testRoom().clients {
 		if client.UserID == "TestUser" {
 			foundClient = client
 			break
 		}
 	}
-	mutex.Unlock()
+	testRoom().mutex.Unlock()
 
 	require.Equal(t, 1, clientCount)
 	require.NotNil(t, foundClient)
@@ -98,7 +98,7 @@ func TestHandleJoin_ValidHostJoin(t *testing.T) {
 	require.NoError(t, err)
 
 	// Read welcome messages
-	for i := 0; i < 5; i++ {
+	for i := 0; i < 8; i++ {
 		var msg map[string]interface{}
 		err := conn.ReadJSON(&msg)
 		require.NoError(t, err)
@@ -107,15 +107,15 @@ func TestHandleJoin_ValidHostJoin(t *testing.T) {
 	}
 
 	// Verify host was added
-	mutex.Lock()
+	testRoom().mutex.Lock()
 	var foundHost *Client
-	for client := range clients {
+	for client := range testRoom().clients {
 		if client.UserID == "HostUser" && client.IsHost {
 			foundHost = client
 			break
 		}
 	}
-	mutex.Unlock()
+	testRoom().mutex.Unlock()
 
 	require.NotNil(t, foundHost)
 	require.True(t, foundHost.IsHost)
@@ -156,9 +156,9 @@ func TestHandleJoin_InvalidUsername(t *testing.T) {
 	require.Error(t, err)
 
 	// Verify client was NOT added
-	mutex.Lock()
-	clientCount := len(clients)
-	mutex.Unlock()
+	testRoom().mutex.Lock()
+	clientCount := len(testRoom().clients)
+	testRoom().mutex.Unlock()
 	require.Equal(t, 0, clientCount)
 }
 
@@ -186,7 +186,7 @@ func TestHandleJoin_DuplicateUsername(t *testing.T) {
 	require.NoError(t, err)
 
 	// Read welcome messages for first client
-	for i := 0; i < 5; i++ {
+	for i := 0; i < 8; i++ {
 		var msg types.Message
 		conn1.ReadJSON(&msg)
 	}
@@ -214,9 +214,9 @@ func TestHandleJoin_DuplicateUsername(t *testing.T) {
 	require.Contains(t, msg.Payload, "username is already taken")
 
 	// Verify only one client exists
-	mutex.Lock()
-	clientCount := len(clients)
-	mutex.Unlock()
+	testRoom().mutex.Lock()
+	clientCount := len(testRoom().clients)
+	testRoom().mutex.Unlock()
 	require.Equal(t, 1, clientCount)
 }
 
@@ -244,7 +244,7 @@ func TestHandleJoin_MultipleHostRejection(t *testing.T) {
 	require.NoError(t, err)
 
 	// Read welcome messages
-	for i := 0; i < 5; i++ {
+	for i := 0; i < 8; i++ {
 		var msg types.Message
 		conn1.ReadJSON(&msg)
 	}
@@ -272,14 +272,14 @@ func TestHandleJoin_MultipleHostRejection(t *testing.T) {
 	require.Contains(t, msg.Payload, "a host is already in this session")
 
 	// Verify only one client (host) exists
-	mutex.Lock()
+	testRoom().mutex.Lock()
 	hostCount := 0
-	for client := range clients {
+	for client := range testRoom().clients {
 		if client.IsHost {
 			hostCount++
 		}
 	}
-	mutex.Unlock()
+	testRoom().mutex.Unlock()
 	require.Equal(t, 1, hostCount)
 }
 
@@ -305,7 +305,7 @@ func TestHandleJoin_HostRejoinsAfterDisconnect(t *testing.T) {
 	conn1.WriteJSON(joinMsg1)
 
 	// Read welcome messages
-	for i := 0; i < 5; i++ {
+	for i := 0; i < 8; i++ {
 		var msg types.Message
 		conn1.ReadJSON(&msg)
 	}
@@ -315,9 +315,9 @@ func TestHandleJoin_HostRejoinsAfterDisconnect(t *testing.T) {
 
 	// Give time for disconnect handler to run
 	require.Eventually(t, func() bool {
-		mutex.Lock()
-		defer mutex.Unlock()
-		return len(clients) == 0
+		testRoom().mutex.Lock()
+		defer testRoom().mutex.Unlock()
+		return len(testRoom().clients) == 0
 	}, 1*time.Second, 100*time.Millisecond)
 
 	// New host should be able to join
@@ -336,7 +336,7 @@ func TestHandleJoin_HostRejoinsAfterDisconnect(t *testing.T) {
 	require.NoError(t, err)
 
 	// Should NOT receive joinError
-	for i := 0; i < 5; i++ {
+	for i := 0; i < 8; i++ {
 		var msg map[string]interface{}
 		err := conn2.ReadJSON(&msg)
 		require.NoError(t, err)
@@ -345,15 +345,15 @@ func TestHandleJoin_HostRejoinsAfterDisconnect(t *testing.T) {
 	}
 
 	// Verify new host was added
-	mutex.Lock()
+	testRoom().mutex.Lock()
 	hostFound := false
-	for client := range clients {
+	for client := range testRoom().clients {
 		if client.IsHost && client.UserID == "Host2" {
 			hostFound = true
 			break
 		}
 	}
-	mutex.Unlock()
+	testRoom().mutex.Unlock()
 	require.True(t, hostFound)
 }
 
@@ -361,21 +361,21 @@ func TestIsUsernameTaken_CaseInsensitive(t *testing.T) {
 	setupTestServer()
 
 	// Create a mock client and add to map
-	mutex.Lock()
+	testRoom().mutex.Lock()
 	mockClient := &Client{
 		UserID:          "Alice",
-		CurrentEstimate: 0,
+		CurrentEstimate: Estimate{},
 		IsHost:          false,
 	}
-	clients[mockClient] = true
-	mutex.Unlock()
+	testRoom().clients[mockClient] = true
+	testRoom().mutex.Unlock()
 
 	// Test case-insensitive matching
-	assert.True(t, isUsernameTaken("Alice"))
-	assert.True(t, isUsernameTaken("alice"))
-	assert.True(t, isUsernameTaken("ALICE"))
-	assert.True(t, isUsernameTaken("aLiCe"))
-	assert.False(t, isUsernameTaken("Bob"))
+	assert.True(t, testRoom().isUsernameTaken("Alice"))
+	assert.True(t, testRoom().isUsernameTaken("alice"))
+	assert.True(t, testRoom().isUsernameTaken("ALICE"))
+	assert.True(t, testRoom().isUsernameTaken("aLiCe"))
+	assert.False(t, testRoom().isUsernameTaken("Bob"))
 
 	setupTestServer() // Clean up
 }
@@ -384,29 +384,29 @@ func TestHasHost(t *testing.T) {
 	setupTestServer()
 
 	// Initially no host
-	assert.False(t, hasHost())
+	assert.False(t, testRoom().hasHost())
 
 	// Add a non-host client
-	mutex.Lock()
+	testRoom().mutex.Lock()
 	client1 := &Client{
 		UserID: "Player",
 		IsHost: false,
 	}
-	clients[client1] = true
-	mutex.Unlock()
+	testRoom().clients[client1] = true
+	testRoom().mutex.Unlock()
 
-	assert.False(t, hasHost())
+	assert.False(t, testRoom().hasHost())
 
 	// Add a host client
-	mutex.Lock()
+	testRoom().mutex.Lock()
 	client2 := &Client{
 		UserID: "Host",
 		IsHost: true,
 	}
-	clients[client2] = true
-	mutex.Unlock()
+	testRoom().clients[client2] = true
+	testRoom().mutex.Unlock()
 
-	assert.True(t, hasHost())
+	assert.True(t, testRoom().hasHost())
 
 	setupTestServer() // Clean up
 }
@@ -433,7 +433,7 @@ func TestHandleJoin_OldFormatCompatibility(t *testing.T) {
 	require.NoError(t, err)
 
 	// Read welcome messages
-	for i := 0; i < 5; i++ {
+	for i := 0; i < 8; i++ {
 		var msg map[string]interface{}
 		err := conn.ReadJSON(&msg)
 		require.NoError(t, err)
@@ -442,15 +442,15 @@ func TestHandleJoin_OldFormatCompatibility(t *testing.T) {
 	}
 
 	// Verify client was added (not as host)
-	mutex.Lock()
+	testRoom().mutex.Lock()
 	var foundClient *Client
-	for client := range clients {
+	for client := range testRoom().clients {
 		if client.UserID == "OldFormatUser" {
 			foundClient = client
 			break
 		}
 	}
-	mutex.Unlock()
+	testRoom().mutex.Unlock()
 
 	require.NotNil(t, foundClient)
 	require.False(t, foundClient.IsHost) // Old format defaults to non-host
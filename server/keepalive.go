@@ -0,0 +1,105 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// defaultPingInterval is how often the server pings each client
+	defaultPingInterval = 25 * time.Second
+	// defaultPongTimeout is how long a client may stay silent, pongs
+	// included, before it is considered gone
+	defaultPongTimeout = 60 * time.Second
+	// defaultWriteTimeout bounds every write, so a stuck peer can't block
+	// the goroutine writing to it
+	defaultWriteTimeout = 10 * time.Second
+	// defaultMaxMessageSize is the largest frame a client may send
+	defaultMaxMessageSize = 64 * 1024
+)
+
+// resetKeepalive restores the default keepalive settings
+func (s *Server) resetKeepalive() {
+	s.pingInterval.Store(int64(defaultPingInterval))
+	s.pongTimeout.Store(int64(defaultPongTimeout))
+	s.writeTimeout.Store(int64(defaultWriteTimeout))
+	s.maxMessageSize.Store(defaultMaxMessageSize)
+}
+
+// SetKeepalive configures how often clients are pinged and how long one may
+// go without a pong (or any other frame) before it is disconnected
+func SetKeepalive(ping, timeout time.Duration) error {
+	return defaultServer().setKeepalive(ping, timeout)
+}
+
+func (s *Server) setKeepalive(ping, timeout time.Duration) error {
+	if ping <= 0 || timeout <= 0 {
+		return errors.New("ping interval and pong timeout must be positive")
+	}
+	if ping >= timeout {
+		return fmt.Errorf("ping interval %s must be shorter than the pong timeout %s", ping, timeout)
+	}
+	s.pingInterval.Store(int64(ping))
+	s.pongTimeout.Store(int64(timeout))
+	return nil
+}
+
+// SetWriteTimeout configures how long a single write to a client may take
+func SetWriteTimeout(d time.Duration) {
+	defaultServer().setWriteTimeout(d)
+}
+
+func (s *Server) setWriteTimeout(d time.Duration) {
+	if d > 0 {
+		s.writeTimeout.Store(int64(d))
+	}
+}
+
+// SetMaxMessageSize configures the largest frame, in bytes, a client may
+// send. Larger frames close the connection.
+func SetMaxMessageSize(size int64) {
+	defaultServer().setMaxMessageSize(size)
+}
+
+func (s *Server) setMaxMessageSize(size int64) {
+	if size > 0 {
+		s.maxMessageSize.Store(size)
+	}
+}
+
+// configureConn applies the read limit and the first read deadline, and
+// extends the deadline whenever a pong arrives
+func (s *Server) configureConn(conn *websocket.Conn) {
+	conn.SetReadLimit(s.maxMessageSize.Load())
+	s.extendReadDeadline(conn)
+	conn.SetPongHandler(func(string) error {
+		s.extendReadDeadline(conn)
+		return nil
+	})
+}
+
+// extendReadDeadline gives the client another pong timeout to be heard from
+func (s *Server) extendReadDeadline(conn *websocket.Conn) {
+	conn.SetReadDeadline(time.Now().Add(time.Duration(s.pongTimeout.Load())))
+}
+
+// writeDeadline is the deadline for a write starting now
+func (s *Server) writeDeadline() time.Time {
+	return time.Now().Add(time.Duration(s.writeTimeout.Load()))
+}
+
+// readFailure describes why reading from a client stopped, for the logs
+func readFailure(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "no heartbeat within the pong timeout"
+	case errors.Is(err, websocket.ErrReadLimit):
+		return "frame larger than the read limit"
+	}
+	return err.Error()
+}
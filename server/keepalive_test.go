@@ -0,0 +1,108 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jcpsimmons/poker/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetKeepalive(t *testing.T) {
+	tests := []struct {
+		name    string
+		ping    time.Duration
+		timeout time.Duration
+		wantErr bool
+	}{
+		{name: "valid", ping: time.Second, timeout: 3 * time.Second},
+		{name: "ping not shorter than timeout", ping: 3 * time.Second, timeout: 3 * time.Second, wantErr: true},
+		{name: "zero ping", timeout: time.Second, wantErr: true},
+		{name: "negative timeout", ping: time.Second, timeout: -time.Second, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := New(Options{})
+			require.NoError(t, err)
+			err = s.setKeepalive(tt.ping, tt.timeout)
+			if tt.wantErr {
+				require.Error(t, err)
+				require.Equal(t, int64(defaultPingInterval), s.pingInterval.Load(), "settings are unchanged")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, int64(tt.ping), s.pingInterval.Load())
+			require.Equal(t, int64(tt.timeout), s.pongTimeout.Load())
+		})
+	}
+}
+
+func TestKeepalive_EvictsSilentClients(t *testing.T) {
+	ts := newRoomTestServer(t)
+	require.NoError(t, SetKeepalive(50*time.Millisecond, 200*time.Millisecond))
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+
+	// A client that never reads never answers pings, like a laptop that
+	// went to sleep
+	zombie := connectTestClientToRoom(t, ts.URL, "squad-a")
+	defer zombie.Close()
+	require.NoError(t, zombie.WriteJSON(types.JoinMessage{
+		Type:    types.Join,
+		Payload: types.JoinPayload{Username: "Bob"},
+	}))
+
+	room := getRoom("squad-a")
+	require.Eventually(t, func() bool {
+		room.mutex.Lock()
+		defer room.mutex.Unlock()
+		return room.findClientUnlocked("Bob") != nil
+	}, time.Second, 10*time.Millisecond)
+
+	require.Eventually(t, func() bool {
+		room.mutex.Lock()
+		defer room.mutex.Unlock()
+		return room.findClientUnlocked("Bob") == nil
+	}, 2*time.Second, 20*time.Millisecond)
+
+	messages := host.drain()
+	require.Equal(t, "1", lastMessage(messages, types.ParticipantCount)["payload"])
+	require.Equal(t, []string{"Alice"}, voterNames(messages))
+}
+
+func TestKeepalive_KeepsResponsiveClients(t *testing.T) {
+	ts := newRoomTestServer(t)
+	require.NoError(t, SetKeepalive(50*time.Millisecond, 200*time.Millisecond))
+
+	// The test client's reader answers pings, so it outlives the timeout
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+
+	time.Sleep(500 * time.Millisecond)
+	room := getRoom("squad-a")
+	room.mutex.Lock()
+	defer room.mutex.Unlock()
+	require.NotNil(t, room.findClientUnlocked("Alice"))
+}
+
+func TestKeepalive_OversizedFrameClosesConnection(t *testing.T) {
+	ts := newRoomTestServer(t)
+	SetMaxMessageSize(1024)
+
+	client := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer client.Close()
+
+	require.NoError(t, client.WriteJSON(types.Message{
+		Type:    types.NewIssue,
+		Payload: strings.Repeat("x", 2048),
+	}))
+	client.drain()
+
+	_, open := <-client.messages
+	require.False(t, open, "the server closes the connection")
+	_, _, err := client.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseMessageTooBig), "unexpected error: %v", err)
+}
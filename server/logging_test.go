@@ -0,0 +1,133 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jcpsimmons/poker/types"
+	"github.com/stretchr/testify/require"
+)
+
+// logBuffer collects JSON log lines written from any goroutine
+type logBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+// records returns the log lines written so far
+func (b *logBuffer) records(t *testing.T) []map[string]interface{} {
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+// findRecord returns the first record with the given message and
+// attributes, or nil
+func findRecord(records []map[string]interface{}, msg string, attrs ...string) map[string]interface{} {
+next:
+	for _, record := range records {
+		if record["msg"] != msg {
+			continue
+		}
+		for i := 0; i+1 < len(attrs); i += 2 {
+			if record[attrs[i]] != attrs[i+1] {
+				continue next
+			}
+		}
+		return record
+	}
+	return nil
+}
+
+func TestLogging_Levels(t *testing.T) {
+	tests := []struct {
+		name         string
+		level        slog.Level
+		wantPayloads bool
+	}{
+		{name: "info leaves out payloads", level: slog.LevelInfo, wantPayloads: false},
+		{name: "debug logs payloads", level: slog.LevelDebug, wantPayloads: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := &logBuffer{}
+			logger := slog.New(slog.NewJSONHandler(logs, &slog.HandlerOptions{Level: tt.level}))
+			_, ts := newInstanceTestServer(t, Options{Logger: logger})
+
+			alice := joinTestRoom(t, ts.URL, "logging", "Alice", true)
+			defer alice.Close()
+			require.NoError(t, alice.WriteJSON(types.Envelope{
+				Version:   types.ProtocolVersion,
+				Type:      types.NewIssue,
+				RequestID: "r1",
+				Payload:   json.RawMessage(`"Secret roadmap item"`),
+			}))
+			require.NotNil(t, findMessage(alice.drain(), types.Ack))
+
+			records := logs.records(t)
+			joined := findRecord(records, "User joined")
+			require.NotNil(t, joined)
+			require.Equal(t, "logging", joined["room"])
+			require.Equal(t, "Alice", joined["user"])
+
+			// The issue title only shows up in payloads
+			require.Equal(t, tt.wantPayloads, strings.Contains(logs.String(), "Secret roadmap item"))
+			received := findRecord(records, "Message received", "type", string(types.NewIssue))
+			if !tt.wantPayloads {
+				require.Nil(t, received)
+				return
+			}
+			require.NotNil(t, received)
+			require.Equal(t, "logging", received["room"])
+			require.Equal(t, "Alice", received["user"])
+			require.Equal(t, "r1", received["requestId"])
+			require.Contains(t, received["payload"], "Secret roadmap item")
+		})
+	}
+}
+
+func TestLogging_RejectedCommandCarriesRequestID(t *testing.T) {
+	logs := &logBuffer{}
+	logger := slog.New(slog.NewJSONHandler(logs, nil))
+	_, ts := newInstanceTestServer(t, Options{Logger: logger})
+
+	bob := joinTestRoom(t, ts.URL, "logging", "Bob", false)
+	defer bob.Close()
+	require.NoError(t, bob.WriteJSON(types.Envelope{
+		Version:   types.ProtocolVersion,
+		Type:      types.Reveal,
+		RequestID: "r2",
+	}))
+	require.NotNil(t, findMessage(bob.drain(), types.Error))
+
+	rejected := findRecord(logs.records(t), "Rejected command")
+	require.NotNil(t, rejected)
+	require.Equal(t, "WARN", rejected["level"])
+	require.Equal(t, "logging", rejected["room"])
+	require.Equal(t, "Bob", rejected["user"])
+	require.Equal(t, string(types.Reveal), rejected["type"])
+	require.Equal(t, "r2", rejected["requestId"])
+}
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jcpsimmons/poker/linear"
+	"github.com/jcpsimmons/poker/types"
+)
+
+// Latency buckets in seconds. Broadcasts only queue messages, so they take
+// microseconds; Linear calls go over the internet.
+var (
+	broadcastBuckets = []float64{0.00001, 0.00005, 0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05}
+	linearBuckets    = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+)
+
+// metrics are the counters behind /metrics. They are always kept, since
+// they are cheap; the endpoint itself is opt-in.
+type metrics struct {
+	roundsRevealed    atomic.Uint64
+	messages          counterVec // By message type
+	clientsDropped    counterVec // By reason
+	broadcastDuration *histogram
+
+	// By operation, i.e. the Linear client method
+	linearRequests counterVec
+	linearErrors   counterVec
+	linearDuration histogramVec
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		broadcastDuration: newHistogram(broadcastBuckets),
+		linearDuration:    histogramVec{buckets: linearBuckets},
+	}
+}
+
+// messageLabel is the type label a received message is counted under.
+// Clients can send any type, so unknown ones share a label.
+func messageLabel(envelope types.Envelope, err error) string {
+	if err != nil {
+		return "invalid"
+	}
+	if _, known := permissions[envelope.Type]; !known {
+		return "unknown"
+	}
+	return string(envelope.Type)
+}
+
+// useLinear counts client's API calls in the metrics and checks it is
+// reachable in /readyz
+func (s *Server) useLinear(client *linear.LinearClient) {
+	if client != nil {
+		client.SetObserver(s.metrics.observeLinear)
+		s.linear.Store(client)
+	}
+}
+
+// observeLinear records a Linear API call. It is the Linear client's
+// observer.
+func (m *metrics) observeLinear(operation string, duration time.Duration, err error) {
+	m.linearRequests.inc(operation)
+	if err != nil {
+		m.linearErrors.inc(operation)
+	}
+	m.linearDuration.observe(operation, duration)
+}
+
+// counterVec is a set of counters told apart by one label
+type counterVec struct {
+	mutex  sync.Mutex
+	values map[string]uint64
+}
+
+func (v *counterVec) inc(label string) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if v.values == nil {
+		v.values = make(map[string]uint64)
+	}
+	v.values[label]++
+}
+
+// snapshot returns a copy of the counters
+func (v *counterVec) snapshot() map[string]uint64 {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	values := make(map[string]uint64, len(v.values))
+	for label, value := range v.values {
+		values[label] = value
+	}
+	return values
+}
+
+// histogram counts observations into cumulative buckets, the way
+// Prometheus expects them
+type histogram struct {
+	mutex   sync.Mutex
+	buckets []float64 // Upper bounds in seconds, ascending
+	counts  []uint64  // Observations at or below each bound
+	count   uint64
+	sum     float64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(duration time.Duration) {
+	seconds := duration.Seconds()
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for i, bound := range h.buckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// histogramVec is a set of histograms told apart by one label
+type histogramVec struct {
+	mutex      sync.Mutex
+	buckets    []float64
+	histograms map[string]*histogram
+}
+
+func (v *histogramVec) observe(label string, duration time.Duration) {
+	v.mutex.Lock()
+	h, ok := v.histograms[label]
+	if !ok {
+		if v.histograms == nil {
+			v.histograms = make(map[string]*histogram)
+		}
+		h = newHistogram(v.buckets)
+		v.histograms[label] = h
+	}
+	v.mutex.Unlock()
+	h.observe(duration)
+}
+
+// labels returns the labels observed so far, sorted
+func (v *histogramVec) labels() []string {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return sortedKeys(v.histograms)
+}
+
+func (v *histogramVec) get(label string) *histogram {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.histograms[label]
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// clientCounts returns the number of rooms, open connections and joined
+// participants
+func (s *Server) clientCounts() (rooms, connected, joined int) {
+	s.roomsMutex.Lock()
+	defer s.roomsMutex.Unlock()
+	for _, room := range s.rooms {
+		room.mutex.Lock()
+		for client := range room.clients {
+			connected++
+			if client.UserID != "" {
+				joined++
+			}
+		}
+		room.mutex.Unlock()
+	}
+	return len(s.rooms), connected, joined
+}
+
+// handleMetrics serves the metrics in the Prometheus text format
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	rooms, connected, joined := s.clientCounts()
+	m := s.metrics
+
+	writeMetric(w, "poker_rooms", "gauge", "Rooms currently open.")
+	writeSample(w, "poker_rooms", "", float64(rooms))
+	writeMetric(w, "poker_connected_clients", "gauge", "WebSocket connections currently open, joined or not.")
+	writeSample(w, "poker_connected_clients", "", float64(connected))
+	writeMetric(w, "poker_joined_clients", "gauge", "Participants currently joined to a room.")
+	writeSample(w, "poker_joined_clients", "", float64(joined))
+
+	writeMetric(w, "poker_rounds_revealed_total", "counter", "Rounds whose votes were revealed.")
+	writeSample(w, "poker_rounds_revealed_total", "", float64(m.roundsRevealed.Load()))
+
+	writeMetric(w, "poker_messages_received_total", "counter", "Messages received from clients, by type.")
+	writeCounterVec(w, "poker_messages_received_total", "type", m.messages.snapshot())
+
+	writeMetric(w, "poker_broadcast_duration_seconds", "histogram", "Time taken to queue a broadcast for every client in a room.")
+	writeHistogram(w, "poker_broadcast_duration_seconds", "", m.broadcastDuration)
+	writeMetric(w, "poker_broadcast_dropped_clients_total", "counter", "Clients a message couldn't be sent to, broadcast or not, by reason.")
+	writeCounterVec(w, "poker_broadcast_dropped_clients_total", "reason", m.clientsDropped.snapshot())
+
+	writeMetric(w, "poker_linear_requests_total", "counter", "Linear API calls, by operation.")
+	writeCounterVec(w, "poker_linear_requests_total", "operation", m.linearRequests.snapshot())
+	writeMetric(w, "poker_linear_request_errors_total", "counter", "Failed Linear API calls, by operation.")
+	writeCounterVec(w, "poker_linear_request_errors_total", "operation", m.linearErrors.snapshot())
+	writeMetric(w, "poker_linear_request_duration_seconds", "histogram", "Linear API call latency, by operation.")
+	for _, operation := range m.linearDuration.labels() {
+		writeHistogram(w, "poker_linear_request_duration_seconds", label("operation", operation), m.linearDuration.get(operation))
+	}
+}
+
+func writeMetric(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// writeSample writes one sample. labels is a rendered label list without
+// braces, or empty.
+func writeSample(w io.Writer, name, labels string, value float64) {
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(w, "%s%s %s\n", name, labels, formatValue(value))
+}
+
+func writeCounterVec(w io.Writer, name, labelName string, values map[string]uint64) {
+	for _, key := range sortedKeys(values) {
+		writeSample(w, name, label(labelName, key), float64(values[key]))
+	}
+}
+
+func writeHistogram(w io.Writer, name, labels string, h *histogram) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	prefix := labels
+	if prefix != "" {
+		prefix += ","
+	}
+	for i, bound := range h.buckets {
+		writeSample(w, name+"_bucket", prefix+label("le", formatValue(bound)), float64(h.counts[i]))
+	}
+	writeSample(w, name+"_bucket", prefix+label("le", "+Inf"), float64(h.count))
+	writeSample(w, name+"_sum", labels, h.sum)
+	writeSample(w, name+"_count", labels, float64(h.count))
+}
+
+// label renders a label pair, escaping the value
+func label(name, value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+	return fmt.Sprintf(`%s="%s"`, name, value)
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jcpsimmons/poker/types"
+	"github.com/stretchr/testify/require"
+)
+
+// scrapeMetrics fetches /metrics and returns its body
+func scrapeMetrics(t *testing.T, serverURL string) string {
+	resp, err := http.Get(serverURL + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, resp.Header.Get("Content-Type"), "text/plain")
+	return string(body)
+}
+
+func TestMetrics_OptIn(t *testing.T) {
+	assets := http.FS(fstest.MapFS{"index.html": {Data: []byte("<h1>poker</h1>")}})
+	_, ts := newInstanceTestServer(t, Options{Assets: assets})
+
+	resp, err := http.Get(ts.URL + "/metrics")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NotContains(t, string(body), "poker_rooms")
+}
+
+func TestMetrics_SessionActivity(t *testing.T) {
+	_, ts := newInstanceTestServer(t, Options{Metrics: true})
+
+	host := joinTestRoom(t, ts.URL, "metrics", "Alice", true)
+	defer host.Close()
+	player := joinTestRoom(t, ts.URL, "metrics", "Bob", false)
+	defer player.Close()
+	host.drain()
+
+	require.NoError(t, player.WriteJSON(types.Message{Type: types.Estimate, Payload: "5"}))
+	host.drain()
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Reveal}))
+	require.NotNil(t, findMessage(host.drain(), types.RevealData))
+	require.NoError(t, player.WriteJSON(types.Message{Type: "bogus"}))
+	require.NoError(t, player.WriteMessage(websocket.TextMessage, []byte("not json")))
+	player.drain()
+
+	metrics := scrapeMetrics(t, ts.URL)
+	for _, line := range []string{
+		"# TYPE poker_connected_clients gauge",
+		"poker_connected_clients 2",
+		"poker_joined_clients 2",
+		"poker_rounds_revealed_total 1",
+		`poker_messages_received_total{type="join"} 2`,
+		`poker_messages_received_total{type="estimate"} 1`,
+		`poker_messages_received_total{type="reveal"} 1`,
+		`poker_messages_received_total{type="unknown"} 1`,
+		`poker_messages_received_total{type="invalid"} 1`,
+		"# TYPE poker_broadcast_duration_seconds histogram",
+	} {
+		require.Contains(t, metrics, line+"\n")
+	}
+	require.Contains(t, metrics, `poker_broadcast_duration_seconds_bucket{le="+Inf"} `)
+	require.NotContains(t, metrics, "poker_broadcast_duration_seconds_count 0\n")
+}
+
+func TestMetrics_DroppedOutsideBroadcast(t *testing.T) {
+	srv, ts := newInstanceTestServer(t, Options{Metrics: true})
+
+	// A queue sync is written to each client directly, not through broadcast
+	room := srv.openRoom("metrics")
+	closed := &Client{server: srv, Room: room, closing: true}
+	room.mutex.Lock()
+	room.clients[closed] = true
+	room.broadcastQueueSyncUnlocked()
+	delete(room.clients, closed)
+	room.mutex.Unlock()
+
+	// So is a message to a single client
+	room.sendResumeToken(&Client{server: srv, Room: room, UserID: "Alice", closing: true})
+
+	metrics := scrapeMetrics(t, ts.URL)
+	require.Contains(t, metrics, `poker_broadcast_dropped_clients_total{reason="write_failed"} 2`+"\n")
+}
+
+func TestMetrics_LinearCalls(t *testing.T) {
+	srv, ts := newInstanceTestServer(t, Options{Metrics: true})
+
+	srv.metrics.observeLinear("PostComment", 120*time.Millisecond, nil)
+	srv.metrics.observeLinear("PostComment", 3*time.Second, errors.New("API returned status 500"))
+	srv.metrics.observeLinear("UpdateEstimate", 40*time.Millisecond, nil)
+
+	metrics := scrapeMetrics(t, ts.URL)
+	for _, line := range []string{
+		`poker_linear_requests_total{operation="PostComment"} 2`,
+		`poker_linear_requests_total{operation="UpdateEstimate"} 1`,
+		`poker_linear_request_errors_total{operation="PostComment"} 1`,
+		`poker_linear_request_duration_seconds_bucket{operation="PostComment",le="0.1"} 0`,
+		`poker_linear_request_duration_seconds_bucket{operation="PostComment",le="0.25"} 1`,
+		`poker_linear_request_duration_seconds_bucket{operation="PostComment",le="5"} 2`,
+		`poker_linear_request_duration_seconds_bucket{operation="PostComment",le="+Inf"} 2`,
+		`poker_linear_request_duration_seconds_sum{operation="PostComment"} 3.12`,
+		`poker_linear_request_duration_seconds_count{operation="UpdateEstimate"} 1`,
+	} {
+		require.Contains(t, metrics, line+"\n")
+	}
+	require.NotContains(t, metrics, `poker_linear_request_errors_total{operation="UpdateEstimate"}`)
+
+	// Every sample line is a name, optional labels and a value
+	for _, line := range strings.Split(strings.TrimSpace(metrics), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		require.Len(t, strings.Fields(line), 2, line)
+	}
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jcpsimmons/poker/messaging"
+	"github.com/jcpsimmons/poker/types"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	errCannotKickSelf = errors.New("you can't remove yourself, leave the session instead")
+	errVotesLocked    = errors.New("votes are locked until the next round")
+	errBanned         = errors.New("you have been removed from this session")
+)
+
+// isBannedUnlocked reports whether a name was banned from the room
+// (caller must hold mutex)
+func (r *Room) isBannedUnlocked(username string) bool {
+	return r.banned[strings.ToLower(username)]
+}
+
+// handleKick removes a participant from the session, optionally banning
+// their name until the room closes
+func (r *Room) handleKick(payload types.KickPayload, sender *Client) error {
+	r.mutex.Lock()
+	target := r.findClientUnlocked(payload.Username)
+	_, held := r.slots[strings.ToLower(payload.Username)]
+	switch {
+	case target == sender:
+		r.mutex.Unlock()
+		return newCommandError(types.ModerationError, errCannotKickSelf)
+	case target == nil && !held:
+		r.mutex.Unlock()
+		return newCommandError(types.ModerationError, errParticipantNotFound)
+	}
+	if payload.Ban {
+		r.banned[strings.ToLower(payload.Username)] = true
+	}
+	// Their resume tokens only bring them back as a player
+	r.bumpRoleRevisionUnlocked(payload.Username)
+	if target == nil {
+		// Only a resume slot is left, so there is no socket to close
+		r.dropSlotUnlocked(payload.Username)
+		r.mutex.Unlock()
+		r.log.Info("Host removed disconnected participant", "user", sender.UserID, "target", payload.Username, "ban", payload.Ban)
+		return nil
+	}
+	username := target.UserID
+	r.mutex.Unlock()
+
+	reason := "you were removed from the session by " + sender.UserID
+	if payload.Ban {
+		reason += " and can't rejoin"
+	}
+	kickedMsg := messaging.MarshallMessage(types.Message{Type: types.Kicked, Payload: reason})
+	if err := target.WriteMessage(websocket.TextMessage, kickedMsg); err != nil {
+		r.log.Warn("Error notifying participant of removal", "user", username, "err", err)
+	}
+
+	// Same bookkeeping as a disconnect, minus the resume slot: a kicked
+	// participant has to join again
+	wasConnected := leaveRoom(target)
+	r.mutex.Lock()
+	r.dropSlotUnlocked(username)
+	r.mutex.Unlock()
+	target.Close()
+
+	r.log.Info("Host removed participant", "user", sender.UserID, "target", username, "ban", payload.Ban)
+	if wasConnected {
+		r.broadcastParticipCount(sender)
+		r.broadcastVoteStatus(sender)
+	}
+	return nil
+}
+
+// handleRename changes a participant's display name
+func (r *Room) handleRename(payload types.RenamePayload, sender *Client) error {
+	newName, err := validateUsername(payload.NewName)
+	if err != nil {
+		return &CommandError{Code: types.CodeInvalidUsername, Legacy: types.ModerationError, Err: err}
+	}
+
+	r.mutex.Lock()
+	target := r.findClientUnlocked(payload.Username)
+	if target == nil {
+		r.mutex.Unlock()
+		return newCommandError(types.ModerationError, errParticipantNotFound)
+	}
+	if existing := r.findClientUnlocked(newName); existing != nil && existing != target {
+		r.mutex.Unlock()
+		return newCommandError(types.ModerationError, errNameTaken)
+	}
+	oldName := target.UserID
+	target.UserID = newName
+	r.dropSlotUnlocked(newName)
+	// Tokens naming the old identity only bring it back as a player
+	r.bumpRoleRevisionUnlocked(oldName)
+	r.mutex.Unlock()
+
+	r.log.Info("Host renamed participant", "user", sender.UserID, "target", oldName, "name", newName)
+	message := types.RenamedMessage{
+		Type: types.Renamed,
+		Payload: types.RenamedPayload{
+			From: oldName,
+			To:   newName,
+			By:   sender.UserID,
+		},
+	}
+	r.broadcast(messaging.MarshallMessage(message), sender)
+	r.broadcastVoteStatus(sender)
+
+	// The old token names the old identity
+	r.sendResumeToken(target)
+	return nil
+}
+
+// handleClearVote takes back a participant's vote, e.g. one cast by mistake
+// in a stale tab
+func (r *Room) handleClearVote(payload types.ClearVotePayload, sender *Client) error {
+	r.mutex.Lock()
+	target := r.findClientUnlocked(payload.Username)
+	var err error
+	switch {
+	case target == nil:
+		err = errParticipantNotFound
+	case r.phase == types.RoundRevealed:
+		err = errVotesLocked
+	}
+	if err != nil {
+		r.mutex.Unlock()
+		return newCommandError(types.ModerationError, err)
+	}
+	target.CurrentEstimate = Estimate{}
+	username := target.UserID
+	r.mutex.Unlock()
+
+	r.log.Info("Host cleared a vote", "user", sender.UserID, "target", username)
+	message := types.VoteClearedMessage{
+		Type: types.VoteCleared,
+		Payload: types.VoteClearedPayload{
+			Username: username,
+			By:       sender.UserID,
+		},
+	}
+	r.broadcast(messaging.MarshallMessage(message), sender)
+	r.broadcastVoteStatus(sender)
+	return nil
+}
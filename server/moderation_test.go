@@ -0,0 +1,223 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jcpsimmons/poker/types"
+	"github.com/stretchr/testify/require"
+)
+
+// voterNames returns the usernames in the last voteStatus message
+func voterNames(messages []map[string]interface{}) []string {
+	status := lastMessage(messages, types.VoteStatus)
+	if status == nil {
+		return nil
+	}
+	var names []string
+	for _, voter := range status["payload"].(map[string]interface{})["voters"].([]interface{}) {
+		names = append(names, voter.(map[string]interface{})["username"].(string))
+	}
+	return names
+}
+
+// kick asks the server to remove a participant
+func kick(t *testing.T, host *roomTestClient, username string, ban bool) {
+	require.NoError(t, host.WriteJSON(types.KickMessage{
+		Type:    types.Kick,
+		Payload: types.KickPayload{Username: username, Ban: ban},
+	}))
+}
+
+func TestModeration_Kick(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	bob := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+	defer bob.Close()
+	host.drain()
+
+	kick(t, host, "bob", false)
+
+	kicked := findMessage(bob.drain(), types.Kicked)
+	require.NotNil(t, kicked)
+	require.Contains(t, kicked["payload"], "removed from the session by Alice")
+
+	messages := host.drain()
+	require.Equal(t, "1", lastMessage(messages, types.ParticipantCount)["payload"])
+	require.Equal(t, []string{"Alice"}, voterNames(messages))
+
+	room := getRoom("squad-a")
+	room.mutex.Lock()
+	require.Empty(t, room.slots, "kicked participants can't resume their seat")
+	room.mutex.Unlock()
+
+	// Without a ban the name can join again
+	again := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+	defer again.Close()
+	require.Nil(t, findMessage(again.welcome, types.JoinError))
+}
+
+func TestModeration_KickedCoHostResumesAsPlayer(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	bob := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+	defer bob.Close()
+	require.NoError(t, host.WriteJSON(types.CoHostMessage{
+		Type:    types.SetCoHost,
+		Payload: types.CoHostPayload{Username: "Bob", CoHost: true},
+	}))
+	token := resumeTokenFrom(t, bob.drain())
+	require.True(t, isHostIn(t, "squad-a", "Bob"))
+
+	kick(t, host, "Bob", false)
+	require.NotNil(t, findMessage(bob.drain(), types.Kicked))
+
+	resumed := resumeTestRoom(t, ts.URL, "squad-a", token)
+	defer resumed.Close()
+	require.Nil(t, findMessage(resumed.welcome, types.JoinError))
+	require.False(t, isHostIn(t, "squad-a", "Bob"), "a kick takes the host role away")
+	require.True(t, isHostIn(t, "squad-a", "Alice"))
+}
+
+func TestModeration_KickWithBan(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	bob := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+	token := resumeTokenFrom(t, bob.welcome)
+	host.drain()
+
+	kick(t, host, "Bob", true)
+	require.NotNil(t, findMessage(bob.drain(), types.Kicked))
+	bob.Close()
+
+	rejoin := joinTestRoom(t, ts.URL, "squad-a", "BOB", false)
+	defer rejoin.Close()
+	joinError := findMessage(rejoin.welcome, types.JoinError)
+	require.NotNil(t, joinError)
+	require.Equal(t, errBanned.Error(), joinError["payload"])
+
+	// The resume token doesn't get around the ban either
+	resumed := resumeTestRoom(t, ts.URL, "squad-a", token)
+	defer resumed.Close()
+	require.NotNil(t, findMessage(resumed.welcome, types.JoinError))
+}
+
+func TestModeration_KickDisconnectedParticipant(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	bob := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+	bob.Close()
+
+	room := getRoom("squad-a")
+	require.Eventually(t, func() bool {
+		room.mutex.Lock()
+		defer room.mutex.Unlock()
+		return len(room.slots) == 1
+	}, time.Second, 20*time.Millisecond)
+
+	kick(t, host, "Bob", false)
+	require.Nil(t, findMessage(host.drain(), types.ModerationError))
+
+	room.mutex.Lock()
+	defer room.mutex.Unlock()
+	require.Empty(t, room.slots)
+}
+
+func TestModeration_Rename(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	bob := joinTestRoom(t, ts.URL, "squad-a", "test", false)
+	defer bob.Close()
+	host.drain()
+
+	require.NoError(t, host.WriteJSON(types.RenameMessage{
+		Type:    types.Rename,
+		Payload: types.RenamePayload{Username: "test", NewName: "Bob"},
+	}))
+
+	messages := bob.drain()
+	renamed := findMessage(messages, types.Renamed)
+	require.NotNil(t, renamed)
+	require.Equal(t, map[string]interface{}{"from": "test", "to": "Bob", "by": "Alice"}, renamed["payload"])
+	require.ElementsMatch(t, []string{"Alice", "Bob"}, voterNames(messages))
+	require.NotNil(t, findMessage(messages, types.ResumeToken), "Bob gets a token for the new name")
+
+	tests := []struct {
+		name    string
+		payload types.RenamePayload
+		want    string
+	}{
+		{name: "name taken", payload: types.RenamePayload{Username: "Bob", NewName: "alice"}, want: "username is already taken, please choose another"},
+		{name: "invalid name", payload: types.RenamePayload{Username: "Bob", NewName: "B"}, want: "username must be at least 2 characters"},
+		{name: "unknown participant", payload: types.RenamePayload{Username: "Nobody", NewName: "Carol"}, want: errParticipantNotFound.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, host.WriteJSON(types.RenameMessage{Type: types.Rename, Payload: tt.payload}))
+			msg := findMessage(host.drain(), types.ModerationError)
+			require.NotNil(t, msg)
+			require.Equal(t, tt.want, msg["payload"])
+		})
+	}
+}
+
+func TestModeration_ClearVote(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	bob := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+	defer bob.Close()
+
+	require.NoError(t, bob.WriteJSON(types.Message{Type: types.Estimate, Payload: "13"}))
+	host.drain()
+
+	clearVote := types.ClearVoteMessage{Type: types.ClearVote, Payload: types.ClearVotePayload{Username: "Bob"}}
+	require.NoError(t, host.WriteJSON(clearVote))
+
+	messages := bob.drain()
+	cleared := findMessage(messages, types.VoteCleared)
+	require.NotNil(t, cleared)
+	require.Equal(t, "Bob", cleared["payload"].(map[string]interface{})["username"])
+
+	status := lastMessage(messages, types.VoteStatus)
+	for _, voter := range status["payload"].(map[string]interface{})["voters"].([]interface{}) {
+		require.Equal(t, false, voter.(map[string]interface{})["hasVoted"])
+	}
+
+	// Revealed votes are locked
+	require.NoError(t, bob.WriteJSON(types.Message{Type: types.Estimate, Payload: "5"}))
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Reveal}))
+	host.drain()
+	require.NoError(t, host.WriteJSON(clearVote))
+	msg := findMessage(host.drain(), types.ModerationError)
+	require.NotNil(t, msg)
+	require.Equal(t, errVotesLocked.Error(), msg["payload"])
+}
+
+func TestModeration_CannotKickSelf(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+
+	kick(t, host, "Alice", false)
+	msg := findMessage(host.drain(), types.ModerationError)
+	require.NotNil(t, msg)
+	require.Equal(t, errCannotKickSelf.Error(), msg["payload"])
+
+	kick(t, host, "Nobody", false)
+	msg = findMessage(host.drain(), types.ModerationError)
+	require.NotNil(t, msg)
+	require.Equal(t, errParticipantNotFound.Error(), msg["payload"])
+}
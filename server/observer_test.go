@@ -0,0 +1,132 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jcpsimmons/poker/types"
+	"github.com/stretchr/testify/require"
+)
+
+// lastMessage returns the last message of the given type, or nil
+func lastMessage(messages []map[string]interface{}, messageType types.MessageType) map[string]interface{} {
+	var last map[string]interface{}
+	for _, msg := range messages {
+		if msg["type"] == string(messageType) {
+			last = msg
+		}
+	}
+	return last
+}
+
+// joinObserver joins a room as an observer
+func joinObserver(t *testing.T, serverURL string, room string, username string) *roomTestClient {
+	return joinTestRoomAs(t, serverURL, room, types.JoinPayload{Username: username, IsObserver: true})
+}
+
+func TestObserver_LeftOutOfCountsAndVoteStatus(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	observer := joinObserver(t, ts.URL, "squad-a", "Olivia")
+	defer observer.Close()
+
+	messages := host.drain()
+	count := lastMessage(messages, types.ParticipantCount)
+	require.NotNil(t, count)
+	require.Equal(t, "1", count["payload"])
+
+	status := lastMessage(messages, types.VoteStatus)
+	require.NotNil(t, status)
+	voters := status["payload"].(map[string]interface{})["voters"].([]interface{})
+	require.Len(t, voters, 1)
+	require.Equal(t, "Alice", voters[0].(map[string]interface{})["username"])
+
+	// Observers still receive every broadcast
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.NewIssue, Payload: "CDP-1"}))
+	require.NotNil(t, findMessage(observer.drain(), types.CurrentIssue))
+}
+
+func TestObserver_CannotVote(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	observer := joinObserver(t, ts.URL, "squad-a", "Olivia")
+	defer observer.Close()
+
+	require.NoError(t, observer.WriteJSON(types.Message{Type: types.Estimate, Payload: "5"}))
+	denied := findMessage(observer.drain(), types.PermissionDenied)
+	require.NotNil(t, denied)
+	require.Equal(t, string(RoleObserver), denied["payload"].(map[string]interface{})["role"])
+}
+
+func TestObserver_LeftOutOfReveal(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	observer := joinObserver(t, ts.URL, "squad-a", "Olivia")
+	defer observer.Close()
+
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Estimate, Payload: "8"}))
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Reveal}))
+
+	reveal := findMessage(observer.drain(), types.RevealData)
+	require.NotNil(t, reveal)
+	payload := reveal["payload"].(map[string]interface{})
+	require.Equal(t, "8", payload["pointAvg"])
+	estimates := payload["estimates"].([]interface{})
+	require.Len(t, estimates, 1)
+	require.Equal(t, "Alice", estimates[0].(map[string]interface{})["user"])
+}
+
+func TestObserver_DoesNotHoldUpAutoReveal(t *testing.T) {
+	ts := newRoomTestServer(t)
+	SetAutoReveal(true, 10*time.Millisecond)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	observer := joinObserver(t, ts.URL, "squad-a", "Olivia")
+	defer observer.Close()
+
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Estimate, Payload: "5"}))
+	require.Eventually(t, func() bool {
+		return getRoom("squad-a").getPhase() == types.RoundRevealed
+	}, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestObserver_TakesPrecedenceOverHost(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	observer := joinTestRoomAs(t, ts.URL, "squad-a", types.JoinPayload{Username: "Olivia", IsHost: true, IsObserver: true})
+	defer observer.Close()
+
+	room := getRoom("squad-a")
+	room.mutex.Lock()
+	defer room.mutex.Unlock()
+	olivia := room.findClientUnlocked("Olivia")
+	require.Equal(t, RoleObserver, olivia.role())
+	require.False(t, olivia.IsHost)
+}
+
+func TestObserver_RoleSurvivesResume(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	observer := joinObserver(t, ts.URL, "squad-a", "Olivia")
+	token := resumeTokenFrom(t, observer.welcome)
+	observer.Close()
+
+	room := getRoom("squad-a")
+	require.Eventually(t, func() bool {
+		room.mutex.Lock()
+		defer room.mutex.Unlock()
+		return len(room.slots) == 1
+	}, time.Second, 20*time.Millisecond)
+
+	resumed := resumeTestRoom(t, ts.URL, "squad-a", token)
+	defer resumed.Close()
+
+	room.mutex.Lock()
+	defer room.mutex.Unlock()
+	require.True(t, room.findClientUnlocked("Olivia").IsObserver)
+}
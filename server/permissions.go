@@ -0,0 +1,136 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/jcpsimmons/poker/messaging"
+	"github.com/jcpsimmons/poker/types"
+
+	"github.com/gorilla/websocket"
+)
+
+// Role is what a connection may do in its room
+type Role string
+
+const (
+	RoleNone     Role = "none"     // Connected but not joined yet
+	RolePlayer   Role = "player"   // Joined and votes
+	RoleHost     Role = "host"     // Joined and runs the session
+	RoleObserver Role = "observer" // Joined to watch, never votes
+)
+
+// Sets of roles allowed to send a message
+var (
+	newcomers = []Role{RoleNone}
+	anyone    = []Role{RoleNone, RolePlayer, RoleHost, RoleObserver}
+	voters    = []Role{RolePlayer, RoleHost}
+	hostsOnly = []Role{RoleHost}
+)
+
+// permissions maps every message a client may send to the roles allowed to
+// send it. Messages that aren't listed are rejected.
+var permissions = map[types.MessageType][]Role{
+	// A connection joins once; switching name or role takes a new one
+	types.Join:   newcomers,
+	types.Resume: newcomers,
+	types.Leave:  anyone,
+
+	types.Estimate: voters,
+
+	types.NewIssue:              hostsOnly,
+	types.Reveal:                hostsOnly,
+	types.Reset:                 hostsOnly,
+	types.MessageIssueConfirm:   hostsOnly,
+	types.MessageQueueAdd:       hostsOnly,
+	types.MessageQueueUpdate:    hostsOnly,
+	types.MessageQueueDelete:    hostsOnly,
+	types.MessageQueueReorder:   hostsOnly,
+	types.MessageAssignEstimate: hostsOnly,
+	types.StartTimer:            hostsOnly,
+	types.StopTimer:             hostsOnly,
+	types.SetAutoReveal:         hostsOnly,
+	types.TransferHost:          hostsOnly,
+	types.SetCoHost:             hostsOnly,
+	types.Kick:                  hostsOnly,
+	types.Rename:                hostsOnly,
+	types.ClearVote:             hostsOnly,
+}
+
+// PermissionError is returned when a client sends a message its role does
+// not allow
+type PermissionError struct {
+	Type types.MessageType
+	Role Role
+}
+
+func (e *PermissionError) Error() string {
+	if _, known := permissions[e.Type]; !known {
+		return fmt.Sprintf("unknown message type %q", e.Type)
+	}
+	return fmt.Sprintf("%s is not allowed to send %s", e.Role, e.Type)
+}
+
+// role returns the client's current role (caller must hold the room mutex
+// if the client has joined a room)
+func (c *Client) role() Role {
+	switch {
+	case c.UserID == "":
+		return RoleNone
+	case c.IsObserver:
+		return RoleObserver
+	case c.IsHost:
+		return RoleHost
+	default:
+		return RolePlayer
+	}
+}
+
+// isVoter reports whether the client has joined as a participant who votes
+// (caller must hold the room mutex)
+func (c *Client) isVoter() bool {
+	return c.UserID != "" && !c.IsObserver
+}
+
+// joinRole returns the role a join message asks for
+func joinRole(payload types.JoinPayload) Role {
+	switch {
+	case payload.IsObserver:
+		return RoleObserver
+	case payload.IsHost:
+		return RoleHost
+	default:
+		return RolePlayer
+	}
+}
+
+// authorize checks whether the client may send a message of the given type,
+// returning nil if it may
+func (r *Room) authorize(client *Client, messageType types.MessageType) *PermissionError {
+	r.mutex.Lock()
+	role := client.role()
+	r.mutex.Unlock()
+
+	for _, allowed := range permissions[messageType] {
+		if allowed == role {
+			return nil
+		}
+	}
+	return &PermissionError{Type: messageType, Role: role}
+}
+
+// sendPermissionError tells the client why its message was ignored
+func sendPermissionError(client *Client, err *PermissionError) {
+	client.logger().Warn("Rejected command", "user", client.UserID, "type", err.Type, "err", err)
+	message := types.PermissionDeniedMessage{
+		Type: types.PermissionDenied,
+		Payload: types.PermissionDeniedPayload{
+			Action:  err.Type,
+			Role:    string(err.Role),
+			Message: err.Error(),
+		},
+	}
+	byteMessage := messaging.MarshallMessage(message)
+	if err := client.WriteMessage(websocket.TextMessage, byteMessage); err != nil {
+		client.logger().Warn("Error sending permission error", "user", client.UserID, "err", err)
+	}
+}
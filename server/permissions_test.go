@@ -0,0 +1,165 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/jcpsimmons/poker/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorize(t *testing.T) {
+	tests := []struct {
+		messageType types.MessageType
+		none        bool
+		player      bool
+		host        bool
+		observer    bool
+	}{
+		{messageType: types.Join, none: true},
+		{messageType: types.Resume, none: true},
+		{messageType: types.Leave, none: true, player: true, host: true, observer: true},
+		{messageType: types.Estimate, none: false, player: true, host: true},
+		{messageType: types.NewIssue, none: false, player: false, host: true},
+		{messageType: types.Reveal, none: false, player: false, host: true},
+		{messageType: types.Reset, none: false, player: false, host: true},
+		{messageType: types.MessageIssueConfirm, none: false, player: false, host: true},
+		{messageType: types.MessageQueueAdd, none: false, player: false, host: true},
+		{messageType: types.MessageQueueUpdate, none: false, player: false, host: true},
+		{messageType: types.MessageQueueDelete, none: false, player: false, host: true},
+		{messageType: types.MessageQueueReorder, none: false, player: false, host: true},
+		{messageType: types.MessageAssignEstimate, none: false, player: false, host: true},
+		{messageType: types.StartTimer, none: false, player: false, host: true},
+		{messageType: types.StopTimer, none: false, player: false, host: true},
+		{messageType: types.SetAutoReveal, none: false, player: false, host: true},
+		{messageType: types.TransferHost, none: false, player: false, host: true},
+		{messageType: types.SetCoHost, none: false, player: false, host: true},
+		{messageType: types.Kick, none: false, player: false, host: true},
+		{messageType: types.Rename, none: false, player: false, host: true},
+		{messageType: types.ClearVote, none: false, player: false, host: true},
+		// Server-to-client messages are never accepted from clients
+		{messageType: types.RevealData},
+		{messageType: types.ClearBoard},
+		{messageType: types.VoteStatus},
+		{messageType: types.RoundState},
+		{messageType: types.PermissionDenied},
+		{messageType: "bogus"},
+	}
+
+	setupTestServer()
+	room := testRoom()
+
+	none, _ := createMockClient()
+	player, _ := createMockClient()
+	player.UserID = "Bob"
+	host, _ := createMockClient()
+	host.UserID = "Alice"
+	host.IsHost = true
+	observer, _ := createMockClient()
+	observer.UserID = "Olivia"
+	observer.IsObserver = true
+
+	covered := 0
+	for _, tt := range tests {
+		t.Run(string(tt.messageType), func(t *testing.T) {
+			roles := []struct {
+				client  *Client
+				role    Role
+				allowed bool
+			}{
+				{client: none, role: RoleNone, allowed: tt.none},
+				{client: player, role: RolePlayer, allowed: tt.player},
+				{client: host, role: RoleHost, allowed: tt.host},
+				{client: observer, role: RoleObserver, allowed: tt.observer},
+			}
+			for _, r := range roles {
+				err := room.authorize(r.client, tt.messageType)
+				if r.allowed {
+					require.Nil(t, err, "%s should be allowed to send %s", r.role, tt.messageType)
+					continue
+				}
+				require.NotNil(t, err, "%s should not be allowed to send %s", r.role, tt.messageType)
+				require.Equal(t, tt.messageType, err.Type)
+				require.Equal(t, r.role, err.Role)
+			}
+		})
+		if _, ok := permissions[tt.messageType]; ok {
+			covered++
+		}
+	}
+	require.Equal(t, len(permissions), covered, "every permission entry needs a test case")
+}
+
+func TestPermissionError_Message(t *testing.T) {
+	err := &PermissionError{Type: types.Reset, Role: RolePlayer}
+	require.EqualError(t, err, "player is not allowed to send reset")
+
+	err = &PermissionError{Type: "bogus", Role: RoleHost}
+	require.EqualError(t, err, `unknown message type "bogus"`)
+}
+
+func TestPermissions_PlayerCannotWipeBoard(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	player := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+	defer player.Close()
+
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.NewIssue, Payload: "CDP-1"}))
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Estimate, Payload: "5"}))
+	host.drain()
+	player.drain()
+
+	for _, messageType := range []types.MessageType{types.Reset, types.Reveal, types.NewIssue} {
+		require.NoError(t, player.WriteJSON(types.Message{Type: messageType, Payload: "hijack"}))
+
+		denied := findMessage(player.drain(), types.PermissionDenied)
+		require.NotNil(t, denied, "%s should be rejected", messageType)
+		payload := denied["payload"].(map[string]interface{})
+		require.Equal(t, string(messageType), payload["action"])
+		require.Equal(t, string(RolePlayer), payload["role"])
+	}
+
+	// Nobody else heard about it, and the round is untouched
+	require.Empty(t, host.drain())
+	room := getRoom("squad-a")
+	require.Equal(t, types.RoundVoting, room.getPhase())
+	room.mutex.Lock()
+	defer room.mutex.Unlock()
+	require.Equal(t, "CDP-1", room.currentIssue)
+	require.True(t, room.findClientUnlocked("Alice").CurrentEstimate.Voted())
+}
+
+func TestPermissions_MustJoinBeforeVoting(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	client := dialTestRoom(t, ts.URL, "squad-a")
+	defer client.Close()
+
+	require.NoError(t, client.WriteJSON(types.Message{Type: types.Estimate, Payload: "5"}))
+	denied := findMessage(client.drain(), types.PermissionDenied)
+	require.NotNil(t, denied)
+	require.Equal(t, string(RoleNone), denied["payload"].(map[string]interface{})["role"])
+}
+
+func TestPermissions_JoinOnlyOnce(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	player := joinVersioned(t, ts.URL, "squad-a", types.JoinPayload{Username: "Bob"})
+	defer player.Close()
+	host.drain()
+
+	// Joining again could rename the player or make them a host
+	sendCommand(t, player, types.Join, "join-2", types.JoinPayload{Username: "Mallory", IsHost: true})
+	reply := replyTo(player.drain(), "join-2")
+	require.NotNil(t, reply)
+	require.Equal(t, string(types.Error), reply["type"])
+	require.Equal(t, string(types.CodeForbidden), reply["payload"].(map[string]interface{})["code"])
+
+	room := getRoom("squad-a")
+	require.True(t, room.isUsernameTaken("Bob"))
+	require.False(t, room.isUsernameTaken("Mallory"))
+	require.False(t, isHostIn(t, "squad-a", "Bob"))
+}
@@ -0,0 +1,259 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jcpsimmons/poker/types"
+)
+
+// snapshotVersion is bumped whenever roomSnapshot changes incompatibly
+const snapshotVersion = 2
+
+// getDataDir returns the configured snapshot directory
+func (s *Server) getDataDir() string {
+	dir, _ := s.dataDir.Load().(string)
+	return dir
+}
+
+// roomSnapshot is the on-disk representation of a room's session state
+type roomSnapshot struct {
+	Version            int                     `json:"version"`
+	Slug               string                  `json:"slug"`
+	SavedAt            time.Time               `json:"savedAt"`
+	CurrentIssue       string                  `json:"currentIssue"`
+	CurrentLinearIssue *types.LinearIssue      `json:"currentLinearIssue,omitempty"`
+	LinearIssues       []types.LinearIssue     `json:"linearIssues,omitempty"`
+	CurrentIssueIndex  int                     `json:"currentIssueIndex"`
+	PendingQueueIndex  int                     `json:"pendingQueueIndex"`
+	QueueItems         []types.QueueItem       `json:"queueItems"`
+	QueueItemCounter   int                     `json:"queueItemCounter"`
+	Votes              map[string]string       `json:"votes,omitempty"` // Card labels keyed by username, including special cards
+	Phase              types.RoundPhase        `json:"phase,omitempty"`
+	Round              int                     `json:"round,omitempty"`
+	Reveal             *types.RevealPayload    `json:"reveal,omitempty"`
+	RoundStartedAt     time.Time               `json:"roundStartedAt"`
+	History            []types.IssueRevealData `json:"history,omitempty"`
+	RoleRevisions      map[string]int          `json:"roleRevisions,omitempty"`
+	AutoReveal         *bool                   `json:"autoReveal,omitempty"` // Set by a host, overrides the server default
+}
+
+// SetDataDir enables session persistence. Every room snapshots its state
+// into dir after each mutation and is restored from it when (re)created.
+func SetDataDir(dir string) error {
+	return defaultServer().setDataDir(dir)
+}
+
+func (s *Server) setDataDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if err := s.loadResumeSecret(dir); err != nil {
+		return err
+	}
+	s.dataDir.Store(dir)
+	s.log.Info("Session persistence enabled", "dir", dir)
+
+	// The default room exists before any client connects, so restore it now
+	if room := s.getRoom(defaultRoomSlug); room != nil {
+		room.restore()
+	}
+	return nil
+}
+
+// snapshotPath returns the file a room's snapshot is stored in
+func (s *Server) snapshotPath(slug string) string {
+	return filepath.Join(s.getDataDir(), slug+".json")
+}
+
+// snapshotUnlocked captures the room state (caller must hold mutex)
+func (r *Room) snapshotUnlocked() roomSnapshot {
+	autoReveal := r.autoReveal
+	snapshot := roomSnapshot{
+		Version:            snapshotVersion,
+		Slug:               r.Slug,
+		SavedAt:            time.Now().UTC(),
+		CurrentIssue:       r.currentIssue,
+		CurrentLinearIssue: r.currentLinearIssue,
+		LinearIssues:       r.linearIssues,
+		CurrentIssueIndex:  r.currentIssueIndex,
+		PendingQueueIndex:  r.pendingQueueIndex,
+		QueueItems:         r.queueItems,
+		QueueItemCounter:   r.queueItemCounter,
+		Phase:              r.phase,
+		Round:              r.round,
+		Reveal:             r.lastReveal,
+		RoundStartedAt:     r.startedAt,
+		History:            r.history,
+		Votes:              make(map[string]string),
+		RoleRevisions:      maps.Clone(r.roleRevisions),
+		AutoReveal:         &autoReveal,
+	}
+
+	for client := range r.clients {
+		if client.UserID != "" && client.CurrentEstimate.Voted() {
+			snapshot.Votes[client.UserID] = client.CurrentEstimate.Card
+		}
+	}
+	// Keep votes of users who are within their resume grace period
+	for _, slot := range r.slots {
+		if slot.CurrentEstimate.Voted() {
+			snapshot.Votes[slot.UserID] = slot.CurrentEstimate.Card
+		}
+	}
+	// Keep votes of users who haven't reconnected since the last restore
+	for user, estimate := range r.restoredVotes {
+		if _, exists := snapshot.Votes[user]; !exists {
+			snapshot.Votes[user] = estimate.Card
+		}
+	}
+
+	return snapshot
+}
+
+// persist writes the room snapshot to disk if persistence is enabled
+func (r *Room) persist() {
+	if r.server.getDataDir() == "" {
+		return
+	}
+
+	// Serialize writers so an older snapshot can never replace a newer one
+	r.persistMutex.Lock()
+	defer r.persistMutex.Unlock()
+
+	r.mutex.Lock()
+	snapshot := r.snapshotUnlocked()
+	r.mutex.Unlock()
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		r.log.Error("Error marshalling snapshot", "err", err)
+		return
+	}
+
+	if err := writeFileAtomic(r.server.snapshotPath(r.Slug), data); err != nil {
+		r.log.Error("Error saving snapshot", "err", err)
+	}
+}
+
+// loadSnapshot reads a room's snapshot from disk. It reports false if
+// persistence is disabled or there is no usable snapshot.
+func (s *Server) loadSnapshot(slug string) (roomSnapshot, bool) {
+	var snapshot roomSnapshot
+	if s.getDataDir() == "" {
+		return snapshot, false
+	}
+
+	data, err := os.ReadFile(s.snapshotPath(slug))
+	if errors.Is(err, os.ErrNotExist) {
+		return snapshot, false
+	}
+	if err != nil {
+		s.log.Error("Error reading snapshot", "room", slug, "err", err)
+		return snapshot, false
+	}
+
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		s.log.Error("Error parsing snapshot", "room", slug, "err", err)
+		return snapshot, false
+	}
+	if snapshot.Version != snapshotVersion {
+		s.log.Warn("Ignoring snapshot with unsupported version", "room", slug, "version", snapshot.Version)
+		return snapshot, false
+	}
+	return snapshot, true
+}
+
+// restore loads the room's snapshot from disk if one exists
+func (r *Room) restore() {
+	snapshot, ok := r.server.loadSnapshot(r.Slug)
+	if !ok {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.currentIssue = snapshot.CurrentIssue
+	r.currentLinearIssue = snapshot.CurrentLinearIssue
+	r.linearIssues = snapshot.LinearIssues
+	r.currentIssueIndex = snapshot.CurrentIssueIndex
+	r.pendingQueueIndex = snapshot.PendingQueueIndex
+	r.queueItems = snapshot.QueueItems
+	r.queueItemCounter = snapshot.QueueItemCounter
+	if snapshot.Phase != "" {
+		r.phase = snapshot.Phase
+		r.round = snapshot.Round
+		r.lastReveal = snapshot.Reveal
+		r.startedAt = snapshot.RoundStartedAt
+	}
+	r.history = snapshot.History
+	if snapshot.RoleRevisions != nil {
+		r.roleRevisions = snapshot.RoleRevisions
+	}
+	if snapshot.AutoReveal != nil {
+		r.autoReveal = *snapshot.AutoReveal
+	}
+	// Votes are only kept if the card still exists in the configured deck
+	r.restoredVotes = make(map[string]Estimate)
+	for user, label := range snapshot.Votes {
+		if estimate, ok := r.deck.estimate(label); ok {
+			r.restoredVotes[user] = estimate
+		}
+	}
+	r.restored = true
+
+	r.log.Info("Restored room from snapshot", "savedAt", snapshot.SavedAt.Format(time.RFC3339),
+		"queueItems", len(r.queueItems), "votes", len(r.restoredVotes))
+}
+
+// takeRestoredVoteUnlocked returns and forgets a restored vote for a
+// rejoining user (caller must hold mutex)
+func (r *Room) takeRestoredVoteUnlocked(username string) Estimate {
+	for user, estimate := range r.restoredVotes {
+		if strings.EqualFold(user, username) {
+			delete(r.restoredVotes, user)
+			return estimate
+		}
+	}
+	return Estimate{}
+}
+
+// writeFileAtomic writes data to a temp file in the same directory and
+// renames it over path, so readers never observe a partial snapshot
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpName := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to sync temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("failed to rename temp file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jcpsimmons/poker/types"
+	"github.com/stretchr/testify/require"
+)
+
+// readSnapshot loads a room snapshot written during a test
+func readSnapshot(t *testing.T, dir string, slug string) roomSnapshot {
+	data, err := os.ReadFile(filepath.Join(dir, slug+".json"))
+	require.NoError(t, err)
+
+	var snapshot roomSnapshot
+	require.NoError(t, json.Unmarshal(data, &snapshot))
+	return snapshot
+}
+
+func TestPersist_SnapshotOnMutation(t *testing.T) {
+	ts := newRoomTestServer(t)
+	dir := t.TempDir()
+	require.NoError(t, SetDataDir(dir))
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.NewIssue, Payload: "CDP-1: Login"}))
+	require.NoError(t, host.WriteJSON(types.QueueAddMessage{
+		Type:    types.MessageQueueAdd,
+		Payload: types.QueueAddPayload{Identifier: "CDP-2", Title: "Logout"},
+	}))
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Estimate, Payload: "5"}))
+	host.drain()
+
+	snapshot := readSnapshot(t, dir, "squad-a")
+	require.Equal(t, snapshotVersion, snapshot.Version)
+	require.Equal(t, "CDP-1: Login", snapshot.CurrentIssue)
+	require.Len(t, snapshot.QueueItems, 1)
+	require.Equal(t, "CDP-2", snapshot.QueueItems[0].Identifier)
+	require.Equal(t, "5", snapshot.Votes["Alice"])
+
+	// Atomic writes leave no temp files behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		require.NotContains(t, entry.Name(), ".tmp")
+	}
+}
+
+func TestPersist_RestoreAfterRestart(t *testing.T) {
+	ts := newRoomTestServer(t)
+	dir := t.TempDir()
+	require.NoError(t, SetDataDir(dir))
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	player := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.NewIssue, Payload: "CDP-1: Login"}))
+	require.NoError(t, player.WriteJSON(types.Message{Type: types.Estimate, Payload: "8"}))
+	host.drain()
+	player.drain()
+	host.Close()
+	player.Close()
+
+	// Simulate a process restart: all in-memory state is gone
+	ResetServerState()
+	require.NoError(t, SetDataDir(dir))
+
+	player = joinTestRoom(t, ts.URL, "squad-a", "bob", false)
+	defer player.Close()
+
+	room := getRoom("squad-a")
+	require.NotNil(t, room)
+	room.mutex.Lock()
+	defer room.mutex.Unlock()
+	require.Equal(t, "CDP-1: Login", room.currentIssue)
+	for client := range room.clients {
+		require.Equal(t, "8", client.CurrentEstimate.Card, "rejoining user gets their vote back")
+	}
+}
+
+func TestPersist_ModerationSurvivesRestart(t *testing.T) {
+	ts := newRoomTestServer(t)
+	dir := t.TempDir()
+	require.NoError(t, SetDataDir(dir))
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	bob := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+	carol := joinTestRoom(t, ts.URL, "squad-a", "Carol", false)
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.NewIssue, Payload: "CDP-1: Login"}))
+	host.drain()
+	require.NoError(t, bob.WriteJSON(types.Message{Type: types.Estimate, Payload: "8"}))
+	bob.drain()
+	require.NoError(t, carol.WriteJSON(types.Message{Type: types.Estimate, Payload: "5"}))
+	carol.drain()
+
+	// The host's last commands must be saved on their own
+	require.NoError(t, host.WriteJSON(types.ClearVoteMessage{
+		Type:    types.ClearVote,
+		Payload: types.ClearVotePayload{Username: "Bob"},
+	}))
+	require.NoError(t, host.WriteJSON(types.RenameMessage{
+		Type:    types.Rename,
+		Payload: types.RenamePayload{Username: "Carol", NewName: "Caroline"},
+	}))
+	require.NoError(t, host.WriteJSON(types.AutoRevealMessage{
+		Type:    types.SetAutoReveal,
+		Payload: types.AutoRevealPayload{Enabled: true},
+	}))
+	host.drain()
+	snapshot, ok := defaultServer().loadSnapshot("squad-a")
+	require.True(t, ok)
+	require.Equal(t, map[string]string{"Caroline": "5"}, snapshot.Votes)
+	host.Close()
+	bob.Close()
+	carol.Close()
+
+	// Simulate a process restart: all in-memory state is gone
+	ResetServerState()
+	require.NoError(t, SetDataDir(dir))
+
+	dave := joinTestRoom(t, ts.URL, "squad-a", "Dave", false)
+	defer dave.Close()
+
+	room := getRoom("squad-a")
+	require.NotNil(t, room)
+	room.mutex.Lock()
+	defer room.mutex.Unlock()
+	require.Len(t, room.restoredVotes, 1, "the cleared vote stays cleared")
+	require.Equal(t, "5", room.restoredVotes["Caroline"].Card)
+	require.True(t, room.autoReveal)
+}
+
+func TestPersist_DefaultRoomKeepsRestoredLinearCursor(t *testing.T) {
+	setupTestServer()
+	dir := t.TempDir()
+
+	issues := []types.LinearIssue{
+		{ID: "id-1", Identifier: "CDP-1", Title: "One"},
+		{ID: "id-2", Identifier: "CDP-2", Title: "Two"},
+	}
+	require.NoError(t, SetDataDir(dir))
+	SetLinearIssues(issues, nil)
+
+	room := testRoom()
+	room.mutex.Lock()
+	room.currentIssueIndex = 0
+	room.pendingQueueIndex = 1
+	room.queueItems = room.queueItems[1:]
+	room.mutex.Unlock()
+	room.persist()
+
+	// Restart and re-fetch from Linear
+	ResetServerState()
+	require.NoError(t, SetDataDir(dir))
+	SetLinearIssues(issues, nil)
+
+	room = testRoom()
+	room.mutex.Lock()
+	defer room.mutex.Unlock()
+	require.Equal(t, 0, room.currentIssueIndex)
+	require.Equal(t, 1, room.pendingQueueIndex)
+	require.Len(t, room.queueItems, 1)
+	require.Equal(t, "CDP-2", room.queueItems[0].Identifier)
+}
+
+func TestPersist_DisabledWithoutDataDir(t *testing.T) {
+	ts := newRoomTestServer(t)
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.NewIssue, Payload: "CDP-1"}))
+	host.drain()
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
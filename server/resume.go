@@ -0,0 +1,334 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jcpsimmons/poker/messaging"
+	"github.com/jcpsimmons/poker/types"
+
+	"github.com/gorilla/websocket"
+)
+
+// defaultResumeGracePeriod is how long a disconnected participant's slot is
+// held unless configured otherwise
+const defaultResumeGracePeriod = 60 * time.Second
+
+// resumeTokenLifetime bounds how long a token can be used to rejoin at all
+const resumeTokenLifetime = 12 * time.Hour
+
+// resumeKeyFile is the name of the persisted signing key inside the data directory
+const resumeKeyFile = "resume.key"
+
+var errInvalidResumeToken = errors.New("invalid or expired resume token")
+
+// resumeClaims identifies the participant a resume token was issued to
+type resumeClaims struct {
+	Room     string `json:"r"`
+	User     string `json:"u"`
+	Host     bool   `json:"h"`
+	Observer bool   `json:"o,omitempty"`  // Joined as an observer
+	Revision int    `json:"rv,omitempty"` // The user's role revision when the token was issued
+	Expires  int64  `json:"exp"`
+}
+
+// issuedAt returns when the token was signed, to the second
+func (c resumeClaims) issuedAt() time.Time {
+	return time.Unix(c.Expires, 0).Add(-resumeTokenLifetime)
+}
+
+// role returns the role the token was issued for
+func (c resumeClaims) role() Role {
+	switch {
+	case c.Observer:
+		return RoleObserver
+	case c.Host:
+		return RoleHost
+	default:
+		return RolePlayer
+	}
+}
+
+// disconnectedSlot keeps a participant's identity, role and vote while they
+// are within the resume grace period
+type disconnectedSlot struct {
+	UserID          string
+	IsHost          bool
+	IsObserver      bool
+	JoinedAt        time.Time
+	CurrentEstimate Estimate
+	LeftAt          time.Time
+	timer           *time.Timer
+}
+
+// SetResumeGracePeriod configures how long disconnected participants keep their slot
+func SetResumeGracePeriod(d time.Duration) {
+	defaultServer().setResumeGracePeriod(d)
+}
+
+func (s *Server) setResumeGracePeriod(d time.Duration) {
+	if d > 0 {
+		s.resumeGracePeriod.Store(int64(d))
+	}
+}
+
+// gracePeriod returns the configured resume grace period
+func (s *Server) gracePeriod() time.Duration {
+	return time.Duration(s.resumeGracePeriod.Load())
+}
+
+func newResumeSecret(logger *slog.Logger) []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		logger.Error("Error generating resume secret", "err", err)
+	}
+	return secret
+}
+
+// loadResumeSecret reads the signing key from dir, creating it on first use,
+// so tokens issued before a restart stay valid afterwards
+func (s *Server) loadResumeSecret(dir string) error {
+	path := filepath.Join(dir, resumeKeyFile)
+
+	data, err := os.ReadFile(path)
+	if err == nil && len(data) >= 32 {
+		s.resumeSecret = data
+		return nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read resume key: %w", err)
+	}
+
+	if err := os.WriteFile(path, s.resumeSecret, 0o600); err != nil {
+		return fmt.Errorf("failed to write resume key: %w", err)
+	}
+	return nil
+}
+
+// signResumeToken encodes and signs claims as "<payload>.<signature>"
+func (s *Server) signResumeToken(claims resumeClaims) string {
+	payload, _ := json.Marshal(claims)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+
+	mac := hmac.New(sha256.New, s.resumeSecret)
+	mac.Write([]byte(encoded))
+	signature := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	return encoded + "." + signature
+}
+
+// verifyResumeToken checks the signature and expiry of a token
+func (s *Server) verifyResumeToken(token string) (resumeClaims, error) {
+	var claims resumeClaims
+
+	encoded, signature, found := strings.Cut(token, ".")
+	if !found {
+		return claims, errInvalidResumeToken
+	}
+
+	mac := hmac.New(sha256.New, s.resumeSecret)
+	mac.Write([]byte(encoded))
+	expected := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return claims, errInvalidResumeToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return claims, errInvalidResumeToken
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return claims, errInvalidResumeToken
+	}
+	if claims.User == "" || time.Now().Unix() > claims.Expires {
+		return claims, errInvalidResumeToken
+	}
+
+	return claims, nil
+}
+
+// sendResumeToken issues a fresh token for the client's current identity
+func (r *Room) sendResumeToken(client *Client) {
+	// Recording the revision tells a known user apart from one whose token
+	// this room has never seen, e.g. after it closed
+	key := strings.ToLower(client.UserID)
+	r.mutex.Lock()
+	revision := r.roleRevisions[key]
+	r.roleRevisions[key] = revision
+	r.mutex.Unlock()
+
+	token := r.server.signResumeToken(resumeClaims{
+		Room:     r.Slug,
+		User:     client.UserID,
+		Host:     client.IsHost,
+		Observer: client.IsObserver,
+		Revision: revision,
+		Expires:  time.Now().Add(resumeTokenLifetime).Unix(),
+	})
+
+	message := types.ResumeTokenMessage{
+		Type: types.ResumeToken,
+		Payload: types.ResumeTokenPayload{
+			Token:        token,
+			GraceSeconds: int(r.server.gracePeriod().Seconds()),
+		},
+	}
+	byteMessage := messaging.MarshallMessage(message)
+	if err := client.WriteMessage(websocket.TextMessage, byteMessage); err != nil {
+		r.log.Warn("Error sending resume token", "user", client.UserID, "err", err)
+	}
+}
+
+// holdSlotUnlocked keeps a disconnected participant's seat for the grace
+// period (caller must hold roomsMutex and mutex)
+func (r *Room) holdSlotUnlocked(client *Client) {
+	key := strings.ToLower(client.UserID)
+	if existing, ok := r.slots[key]; ok {
+		existing.timer.Stop()
+	}
+
+	slot := &disconnectedSlot{
+		UserID:          client.UserID,
+		IsHost:          client.IsHost,
+		IsObserver:      client.IsObserver,
+		JoinedAt:        client.JoinedAt,
+		CurrentEstimate: client.CurrentEstimate,
+		LeftAt:          time.Now(),
+	}
+	grace := r.server.gracePeriod()
+	slot.timer = time.AfterFunc(grace, func() {
+		r.do(func() { r.expireSlot(key, slot) })
+	})
+	r.slots[key] = slot
+
+	r.log.Info("Holding slot", "user", client.UserID, "grace", grace)
+}
+
+// dropSlotUnlocked forgets a held slot, e.g. when the name is claimed by a
+// plain join (caller must hold mutex)
+func (r *Room) dropSlotUnlocked(username string) {
+	key := strings.ToLower(username)
+	if slot, ok := r.slots[key]; ok {
+		slot.timer.Stop()
+		delete(r.slots, key)
+	}
+}
+
+// expireSlot releases a slot whose grace period ran out
+func (r *Room) expireSlot(key string, slot *disconnectedSlot) {
+	r.server.roomsMutex.Lock()
+	defer r.server.roomsMutex.Unlock()
+
+	r.mutex.Lock()
+	if r.slots[key] == slot {
+		delete(r.slots, key)
+		r.log.Info("Resume grace period expired", "user", slot.UserID)
+	}
+	r.mutex.Unlock()
+
+	teardownIfEmpty(r)
+}
+
+// handleResume reattaches a new socket to the identity in a resume token.
+// It returns nil once the client is part of the session.
+func (r *Room) handleResume(token string, sender *Client) error {
+	claims, err := r.server.verifyResumeToken(token)
+	if err == nil && claims.Room != r.Slug {
+		err = errInvalidResumeToken
+	}
+	if err != nil {
+		r.log.Warn("Resume rejected", "err", err)
+		// The client stays connected and can fall back to a regular join
+		return newCommandError(types.ResumeError, err)
+	}
+
+	key := strings.ToLower(claims.User)
+
+	r.mutex.Lock()
+	var stale *Client
+	var replaced bool
+	if slot, ok := r.slots[key]; ok {
+		// Disconnected within the grace period
+		slot.timer.Stop()
+		delete(r.slots, key)
+		replaced = slot.IsHost && r.replacedSinceUnlocked(slot.LeftAt)
+		sender.UserID = slot.UserID
+		sender.IsHost = slot.IsHost && !replaced
+		sender.IsObserver = slot.IsObserver
+		sender.JoinedAt = slot.JoinedAt
+		sender.CurrentEstimate = slot.CurrentEstimate
+	} else if stale = r.findClientUnlocked(claims.User); stale != nil && stale != sender {
+		// The old socket hasn't noticed it is dead yet, so take its place
+		delete(r.clients, stale)
+		sender.UserID = stale.UserID
+		sender.IsHost = stale.IsHost
+		sender.IsObserver = stale.IsObserver
+		sender.JoinedAt = stale.JoinedAt
+		sender.CurrentEstimate = stale.CurrentEstimate
+	} else if stale == sender {
+		r.mutex.Unlock()
+		return nil
+	} else {
+		// Grace period is over or the server restarted: join again with
+		// the identity the token was issued for, as long as it still
+		// matches the room's roles
+		role := claims.role()
+		revision, known := r.roleRevisions[key]
+		current := known && claims.Revision == revision
+		switch {
+		case known && claims.Revision < revision:
+			// Their role changed or they were kicked since
+			role = RolePlayer
+		case role == RoleHost && r.replacedSinceUnlocked(claims.issuedAt()):
+			role = RolePlayer
+			replaced = true
+		case role == RoleHost && !current && r.hasHostUnlocked():
+			// Nothing vouches for the host role any more
+			role = RolePlayer
+		}
+		r.mutex.Unlock()
+		r.log.Info("No slot held, rejoining from resume token", "user", claims.User, "role", role)
+		if err := r.handleJoin(claims.User, sender, role, current); err != nil {
+			return err
+		}
+		if replaced {
+			r.announceRoleChanges(roleChangeReplaced, nil, sender)
+		}
+		return nil
+	}
+	if sender.IsHost {
+		r.stopHostRecoveryUnlocked()
+	}
+	r.mutex.Unlock()
+
+	if stale != nil {
+		stale.Close()
+	}
+
+	r.log.Info("User resumed", "user", sender.UserID)
+	if replaced {
+		r.announceRoleChanges(roleChangeReplaced, nil, sender)
+	}
+	return nil
+}
+
+// findClientUnlocked returns the joined client with the given username
+// (case-insensitive), if any (caller must hold mutex)
+func (r *Room) findClientUnlocked(username string) *Client {
+	for client := range r.clients {
+		if client.UserID != "" && strings.EqualFold(client.UserID, username) {
+			return client
+		}
+	}
+	return nil
+}
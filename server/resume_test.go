@@ -0,0 +1,234 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jcpsimmons/poker/types"
+	"github.com/stretchr/testify/require"
+)
+
+// findMessage returns the first message of the given type
+func findMessage(messages []map[string]interface{}, messageType types.MessageType) map[string]interface{} {
+	for _, msg := range messages {
+		if msg["type"] == string(messageType) {
+			return msg
+		}
+	}
+	return nil
+}
+
+// resumeTokenFrom extracts the resume token from a client's welcome messages
+func resumeTokenFrom(t *testing.T, messages []map[string]interface{}) string {
+	msg := findMessage(messages, types.ResumeToken)
+	require.NotNil(t, msg, "expected a resumeToken message")
+	payload := msg["payload"].(map[string]interface{})
+	token := payload["token"].(string)
+	require.NotEmpty(t, token)
+	return token
+}
+
+// resumeTestRoom opens a new socket and resumes with token
+func resumeTestRoom(t *testing.T, serverURL string, room string, token string) *roomTestClient {
+	client := dialTestRoom(t, serverURL, room)
+	require.NoError(t, client.WriteJSON(types.Message{Type: types.Resume, Payload: token}))
+	client.welcome = client.drain()
+	return client
+}
+
+func TestResumeToken_SignAndVerify(t *testing.T) {
+	s, err := New(Options{})
+	require.NoError(t, err)
+
+	claims := resumeClaims{Room: "squad-a", User: "Alice", Host: true, Expires: time.Now().Add(time.Hour).Unix()}
+	token := s.signResumeToken(claims)
+
+	verified, err := s.verifyResumeToken(token)
+	require.NoError(t, err)
+	require.Equal(t, claims, verified)
+
+	// Tampered signature
+	_, err = s.verifyResumeToken(token + "x")
+	require.ErrorIs(t, err, errInvalidResumeToken)
+
+	// Tampered claims with the original signature
+	forged := s.signResumeToken(resumeClaims{Room: "squad-a", User: "Mallory", Host: true, Expires: claims.Expires})
+	_, err = s.verifyResumeToken(forged[:len(forged)/2] + token[len(token)/2:])
+	require.ErrorIs(t, err, errInvalidResumeToken)
+
+	// Expired
+	expired := s.signResumeToken(resumeClaims{Room: "squad-a", User: "Alice", Expires: time.Now().Add(-time.Minute).Unix()})
+	_, err = s.verifyResumeToken(expired)
+	require.ErrorIs(t, err, errInvalidResumeToken)
+
+	// Signed by another server
+	other, err := New(Options{})
+	require.NoError(t, err)
+	_, err = other.verifyResumeToken(token)
+	require.ErrorIs(t, err, errInvalidResumeToken)
+
+	// Garbage
+	_, err = s.verifyResumeToken("not-a-token")
+	require.ErrorIs(t, err, errInvalidResumeToken)
+}
+
+func TestResume_WithinGracePeriodKeepsRoleAndVote(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	token := resumeTokenFrom(t, host.welcome)
+	player := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+	defer player.Close()
+
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Estimate, Payload: "5"}))
+	host.drain()
+	host.Close()
+
+	room := getRoom("squad-a")
+	require.Eventually(t, func() bool {
+		room.mutex.Lock()
+		defer room.mutex.Unlock()
+		return len(room.slots) == 1
+	}, time.Second, 20*time.Millisecond)
+
+	resumed := resumeTestRoom(t, ts.URL, "squad-a", token)
+	defer resumed.Close()
+
+	require.Nil(t, findMessage(resumed.welcome, types.ResumeError))
+	require.NotNil(t, findMessage(resumed.welcome, types.ResumeToken), "a fresh token is issued on resume")
+
+	room.mutex.Lock()
+	defer room.mutex.Unlock()
+	require.Empty(t, room.slots)
+	alice := room.findClientUnlocked("Alice")
+	require.NotNil(t, alice)
+	require.True(t, alice.IsHost)
+	require.Equal(t, "5", alice.CurrentEstimate.Card)
+}
+
+func TestResume_ReplacesStaleSocket(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	original := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer original.Close()
+	token := resumeTokenFrom(t, original.welcome)
+
+	require.NoError(t, original.WriteJSON(types.Message{Type: types.Estimate, Payload: "3"}))
+	original.drain()
+
+	// The old socket is still open when the new one resumes
+	resumed := resumeTestRoom(t, ts.URL, "squad-a", token)
+	defer resumed.Close()
+	require.Nil(t, findMessage(resumed.welcome, types.ResumeError))
+
+	// The stale socket gets closed by the server
+	require.Eventually(t, func() bool {
+		select {
+		case _, ok := <-original.messages:
+			return !ok
+		default:
+			return false
+		}
+	}, time.Second, 20*time.Millisecond)
+
+	room := getRoom("squad-a")
+	room.mutex.Lock()
+	defer room.mutex.Unlock()
+	require.Len(t, room.clients, 1)
+	require.Empty(t, room.slots, "a replaced socket must not leave a slot behind")
+	alice := room.findClientUnlocked("alice")
+	require.NotNil(t, alice)
+	require.True(t, alice.IsHost)
+	require.Equal(t, "3", alice.CurrentEstimate.Card)
+}
+
+func TestResume_InvalidTokenAllowsJoin(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	client := dialTestRoom(t, ts.URL, "squad-a")
+	defer client.Close()
+
+	require.NoError(t, client.WriteJSON(types.Message{Type: types.Resume, Payload: "bogus"}))
+	messages := client.drain()
+	require.NotNil(t, findMessage(messages, types.ResumeError))
+
+	// The socket stays usable for a regular join
+	require.NoError(t, client.WriteJSON(types.JoinMessage{
+		Type:    types.Join,
+		Payload: types.JoinPayload{Username: "Alice"},
+	}))
+	messages = client.drain()
+	require.NotNil(t, findMessage(messages, types.ResumeToken))
+	require.Nil(t, findMessage(messages, types.JoinError))
+}
+
+func TestResume_TokenFromAnotherRoomRejected(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	alice := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer alice.Close()
+	token := resumeTokenFrom(t, alice.welcome)
+
+	client := dialTestRoom(t, ts.URL, "squad-b")
+	defer client.Close()
+	require.NoError(t, client.WriteJSON(types.Message{Type: types.Resume, Payload: token}))
+	require.NotNil(t, findMessage(client.drain(), types.ResumeError))
+}
+
+func TestResume_AfterGracePeriodRejoinsFromToken(t *testing.T) {
+	ts := newRoomTestServer(t)
+	SetResumeGracePeriod(50 * time.Millisecond)
+
+	keeper := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+	defer keeper.Close()
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	token := resumeTokenFrom(t, host.welcome)
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Estimate, Payload: "8"}))
+	host.drain()
+	host.Close()
+
+	room := getRoom("squad-a")
+	require.Eventually(t, func() bool {
+		room.mutex.Lock()
+		defer room.mutex.Unlock()
+		return len(room.clients) == 1 && len(room.slots) == 0
+	}, time.Second, 20*time.Millisecond)
+
+	resumed := resumeTestRoom(t, ts.URL, "squad-a", token)
+	defer resumed.Close()
+	require.Nil(t, findMessage(resumed.welcome, types.JoinError))
+
+	room.mutex.Lock()
+	defer room.mutex.Unlock()
+	alice := room.findClientUnlocked("Alice")
+	require.NotNil(t, alice)
+	require.True(t, alice.IsHost, "host role comes from the token")
+	require.False(t, alice.CurrentEstimate.Voted(), "the vote expired with the slot")
+}
+
+func TestResume_PlainJoinDiscardsSlot(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Estimate, Payload: "8"}))
+	host.drain()
+	host.Close()
+
+	room := getRoom("squad-a")
+	require.Eventually(t, func() bool {
+		room.mutex.Lock()
+		defer room.mutex.Unlock()
+		return len(room.slots) == 1
+	}, time.Second, 20*time.Millisecond)
+
+	// Legacy clients rejoin by name; they get a fresh seat
+	rejoined := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer rejoined.Close()
+	require.Nil(t, findMessage(rejoined.welcome, types.JoinError))
+
+	room.mutex.Lock()
+	defer room.mutex.Unlock()
+	require.Empty(t, room.slots)
+	require.False(t, room.findClientUnlocked("Alice").CurrentEstimate.Voted())
+}
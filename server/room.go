@@ -0,0 +1,332 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jcpsimmons/poker/linear"
+	"github.com/jcpsimmons/poker/types"
+)
+
+// defaultRoomSlug is the room used when a client connects without ?room=.
+// It is never torn down, so Linear integration state survives empty periods.
+const defaultRoomSlug = "default"
+
+// roomSlugPattern restricts room slugs to URL-friendly identifiers.
+var roomSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// Room holds the state of a single planning poker session. Every room is
+// fully isolated: clients, the current issue, the queue and the Linear
+// cursor are never shared between rooms.
+//
+// Session state is only changed from the room's event loop: client
+// commands, disconnects and timers are all run through do, one at a time.
+// The mutex additionally guards the fields read from other goroutines,
+// such as HTTP handlers and the join path.
+type Room struct {
+	Slug string
+
+	server *Server      // Server the room belongs to
+	log    *slog.Logger // The server's logger, with the room attached
+
+	// actions feeds the event loop; stopped is closed when the room closes
+	actions  chan func()
+	stopped  chan struct{}
+	stopOnce sync.Once
+
+	// mutex is used to synchronize access to the room state below.
+	mutex sync.Mutex
+
+	// clients stores all active client connections in this room.
+	clients      map[*Client]bool
+	currentIssue string
+
+	// deck is the set of cards votes are validated and averaged against
+	deck Deck
+
+	// Round lifecycle
+	phase      types.RoundPhase
+	round      int                  // Incremented each time voting starts
+	lastReveal *types.RevealPayload // Results of the revealed round, for late joiners
+	startedAt  time.Time            // When voting started in the current round
+
+	// Countdown for the current round, nil timer when none is running.
+	// timerGeneration tells an expired countdown apart from its replacement.
+	timer           *time.Timer
+	timerDeadline   time.Time
+	timerSeconds    int
+	timerAutoReveal bool
+	timerGeneration int
+
+	// Auto-reveal once everybody has voted. autoRevealTimer is pending
+	// while the last voter can still change their mind.
+	autoReveal           bool
+	autoRevealDelay      time.Duration
+	autoRevealTimer      *time.Timer
+	autoRevealGeneration int
+
+	// Pending promotion of a player after the last host left
+	hostRecoveryTimer      *time.Timer
+	hostRecoveryGeneration int
+	hostPromotedAt         time.Time // When a player was last promoted
+
+	// Keeps a room the API used open for a while when nobody is in it
+	idleTimer      *time.Timer
+	idleGeneration int
+
+	// history records every revealed round, oldest first
+	history []types.IssueRevealData
+
+	// Linear integration state
+	linearClient       *linear.LinearClient
+	linearIssues       []types.LinearIssue
+	currentIssueIndex  int
+	currentLinearIssue *types.LinearIssue
+	pendingQueueIndex  int
+	confirmedIssues    map[string]bool
+
+	// Queue state
+	queueItems        []types.QueueItem
+	currentQueueIndex int // Track which queue item is currently being voted on (not used yet, reserved for future)
+	queueItemCounter  int // For generating unique IDs for custom items
+
+	// Track if we just assigned an estimate (for auto-advance notification)
+	justAssignedEstimate bool
+
+	// Persistence state
+	persistMutex  sync.Mutex          // Serializes snapshot writes
+	restored      bool                // Room state was loaded from a snapshot
+	restoredVotes map[string]Estimate // Votes from the snapshot awaiting their user's rejoin
+
+	// banned holds lowercase names kicked with a ban, refused until the room closes
+	banned map[string]bool
+
+	// slots holds disconnected participants within their resume grace
+	// period, keyed by lowercase username
+	slots map[string]*disconnectedSlot
+
+	// roleRevisions counts the role changes of every participant a resume
+	// token was issued to, keyed by lowercase username. Tokens carry the
+	// revision they were issued at, so older ones can't bring a role back.
+	roleRevisions map[string]int
+}
+
+// newRoom creates an empty room with the given slug, using the server's
+// room defaults (caller must hold roomsMutex unless the server is new)
+func (s *Server) newRoom(slug string) *Room {
+	room := &Room{
+		Slug:              slug,
+		server:            s,
+		log:               s.log.With("room", slug),
+		actions:           make(chan func()),
+		stopped:           make(chan struct{}),
+		clients:           make(map[*Client]bool),
+		deck:              s.deck,
+		phase:             types.RoundIdle,
+		autoReveal:        s.autoRevealEnabled,
+		autoRevealDelay:   s.autoRevealDelay,
+		currentIssueIndex: -1,
+		pendingQueueIndex: -1,
+		confirmedIssues:   make(map[string]bool),
+		currentQueueIndex: -1,
+		restoredVotes:     make(map[string]Estimate),
+		slots:             make(map[string]*disconnectedSlot),
+		banned:            make(map[string]bool),
+		roleRevisions:     make(map[string]int),
+	}
+	go room.run()
+	return room
+}
+
+// run is the room's event loop. It runs actions one at a time until the
+// room is stopped.
+func (r *Room) run() {
+	for {
+		// An action may have stopped the room, so check before taking
+		// another one
+		select {
+		case <-r.stopped:
+			return
+		default:
+		}
+
+		select {
+		case action := <-r.actions:
+			action()
+		case <-r.stopped:
+			return
+		}
+	}
+}
+
+// do runs fn on the room's event loop and waits for it to finish. It
+// reports false, without running fn, once the room has been closed.
+// Actions must never call do themselves.
+func (r *Room) do(fn func()) bool {
+	done := make(chan struct{})
+	action := func() {
+		defer close(done)
+		fn()
+	}
+	select {
+	case r.actions <- action:
+	case <-r.stopped:
+		return false
+	}
+	<-done
+	return true
+}
+
+// stop ends the event loop. Actions already running finish first.
+func (r *Room) stop() {
+	r.stopOnce.Do(func() {
+		close(r.stopped)
+	})
+}
+
+// normalizeRoomSlug validates a room slug from a request, falling back to
+// the default room when none is given
+func normalizeRoomSlug(raw string) (string, error) {
+	slug := strings.ToLower(strings.TrimSpace(raw))
+	if slug == "" {
+		return defaultRoomSlug, nil
+	}
+	if !roomSlugPattern.MatchString(slug) {
+		return "", fmt.Errorf("invalid room name %q", raw)
+	}
+	return slug, nil
+}
+
+// getRoom returns the room with the given slug, or nil if it doesn't exist
+func (s *Server) getRoom(slug string) *Room {
+	s.roomsMutex.Lock()
+	defer s.roomsMutex.Unlock()
+	return s.rooms[slug]
+}
+
+// joinRoom adds a client to the room with the given slug, creating the room
+// on demand
+func (s *Server) joinRoom(slug string, client *Client) *Room {
+	s.roomsMutex.Lock()
+	defer s.roomsMutex.Unlock()
+
+	room := s.openRoomUnlocked(slug)
+	room.mutex.Lock()
+	room.clients[client] = true
+	client.Room = room
+	room.mutex.Unlock()
+
+	return room
+}
+
+// openRoom returns the room with the given slug, creating it on demand. A
+// room opened without a client is closed again by holdIdleUnlocked unless
+// somebody joins.
+func (s *Server) openRoom(slug string) *Room {
+	s.roomsMutex.Lock()
+	defer s.roomsMutex.Unlock()
+	return s.openRoomUnlocked(slug)
+}
+
+// openRoomUnlocked is openRoom for callers holding roomsMutex
+func (s *Server) openRoomUnlocked(slug string) *Room {
+	room, exists := s.rooms[slug]
+	if !exists {
+		room = s.newRoom(slug)
+		room.restore()
+		s.rooms[slug] = room
+		s.log.Info("Room created", "room", slug)
+	}
+	return room
+}
+
+// leaveRoom removes a client from its room and tears the room down once the
+// last client has left and no resume slots are pending. It reports whether
+// the client was still connected.
+func leaveRoom(client *Client) bool {
+	room := client.Room
+	if room == nil {
+		return false
+	}
+
+	room.server.roomsMutex.Lock()
+	defer room.server.roomsMutex.Unlock()
+
+	room.mutex.Lock()
+	_, wasConnected := room.clients[client]
+	delete(room.clients, client)
+	if wasConnected && client.UserID != "" {
+		room.holdSlotUnlocked(client)
+	}
+	if wasConnected && client.IsHost && !room.hasHostUnlocked() {
+		room.scheduleHostRecoveryUnlocked()
+	}
+	room.mutex.Unlock()
+
+	teardownIfEmpty(room)
+
+	return wasConnected
+}
+
+// teardownIfEmpty removes an on-demand room that has no clients, no
+// pending resume slots and isn't held open for the API (caller must hold
+// roomsMutex)
+func teardownIfEmpty(room *Room) {
+	room.mutex.Lock()
+	empty := len(room.clients) == 0 && len(room.slots) == 0 && room.idleTimer == nil
+	room.mutex.Unlock()
+
+	if empty && room.Slug != defaultRoomSlug && room.server.rooms[room.Slug] == room {
+		room.mutex.Lock()
+		room.stopTimerUnlocked()
+		room.stopAutoRevealUnlocked()
+		room.stopHostRecoveryUnlocked()
+		room.mutex.Unlock()
+		room.stop()
+		delete(room.server.rooms, room.Slug)
+		room.log.Info("Room closed")
+	}
+}
+
+// holdIdleUnlocked keeps a room nobody is in open for the resume grace
+// period, the way a slot keeps the room its last participant left, and
+// closes it afterwards unless somebody joined (caller must hold mutex)
+func (r *Room) holdIdleUnlocked() {
+	if len(r.clients) > 0 {
+		return
+	}
+	r.stopIdleUnlocked()
+	generation := r.idleGeneration
+	r.idleTimer = time.AfterFunc(r.server.gracePeriod(), func() {
+		r.do(func() { r.expireIdle(generation) })
+	})
+}
+
+// stopIdleUnlocked cancels a pending idle close (caller must hold mutex)
+func (r *Room) stopIdleUnlocked() {
+	if r.idleTimer == nil {
+		return
+	}
+	r.idleTimer.Stop()
+	r.idleTimer = nil
+	r.idleGeneration++
+}
+
+// expireIdle closes a room that stayed empty for the grace period
+func (r *Room) expireIdle(generation int) {
+	r.server.roomsMutex.Lock()
+	defer r.server.roomsMutex.Unlock()
+
+	r.mutex.Lock()
+	if r.idleGeneration == generation {
+		r.idleTimer = nil
+		r.idleGeneration++
+	}
+	r.mutex.Unlock()
+
+	teardownIfEmpty(r)
+}
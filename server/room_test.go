@@ -0,0 +1,254 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jcpsimmons/poker/types"
+	"github.com/stretchr/testify/require"
+)
+
+// connectTestClientToRoom dials the test server's WebSocket endpoint for a specific room
+func connectTestClientToRoom(t *testing.T, serverURL string, room string) *websocket.Conn {
+	wsURL := "ws" + strings.TrimPrefix(serverURL, "http") + "/ws?room=" + room
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+	return conn
+}
+
+// roomTestClient is a test connection whose incoming messages are read by a
+// background goroutine, so tests can wait for quiet periods without tripping
+// gorilla's permanent read-deadline errors
+type roomTestClient struct {
+	*websocket.Conn
+	messages chan map[string]interface{}
+	welcome  []map[string]interface{} // Messages received right after joining
+}
+
+// dialTestRoom connects to a room without joining it
+func dialTestRoom(t *testing.T, serverURL string, room string) *roomTestClient {
+	conn := connectTestClientToRoom(t, serverURL, room)
+	client := &roomTestClient{Conn: conn, messages: make(chan map[string]interface{}, 100)}
+	go func() {
+		defer close(client.messages)
+		for {
+			var msg map[string]interface{}
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			client.messages <- msg
+		}
+	}()
+	return client
+}
+
+// joinTestRoom connects to a room, joins and drains the welcome messages
+func joinTestRoom(t *testing.T, serverURL string, room string, username string, isHost bool) *roomTestClient {
+	return joinTestRoomAs(t, serverURL, room, types.JoinPayload{Username: username, IsHost: isHost})
+}
+
+// joinTestRoomAs joins with the given payload and drains the welcome messages
+func joinTestRoomAs(t *testing.T, serverURL string, room string, payload types.JoinPayload) *roomTestClient {
+	client := dialTestRoom(t, serverURL, room)
+
+	err := client.WriteJSON(types.JoinMessage{
+		Type:    types.Join,
+		Payload: payload,
+	})
+	require.NoError(t, err)
+
+	client.welcome = client.drain()
+	return client
+}
+
+// drain collects messages until the connection has been quiet for a short while
+func (c *roomTestClient) drain() []map[string]interface{} {
+	var messages []map[string]interface{}
+	for {
+		select {
+		case msg, ok := <-c.messages:
+			if !ok {
+				return messages
+			}
+			messages = append(messages, msg)
+		case <-time.After(200 * time.Millisecond):
+			return messages
+		}
+	}
+}
+
+func newRoomTestServer(t *testing.T) *httptest.Server {
+	setupTestServer()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", handler)
+	mux.HandleFunc("/api/history", historyHandler)
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestNormalizeRoomSlug(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    string
+		expectError bool
+	}{
+		{input: "", expected: defaultRoomSlug},
+		{input: "  ", expected: defaultRoomSlug},
+		{input: "squad-a", expected: "squad-a"},
+		{input: "Squad_B", expected: "squad_b"},
+		{input: "-leading", expectError: true},
+		{input: "has space", expectError: true},
+		{input: "../etc", expectError: true},
+		{input: strings.Repeat("a", 65), expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			slug, err := normalizeRoomSlug(tt.input)
+			if tt.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, slug)
+		})
+	}
+}
+
+func TestRoom_InvalidSlugRejected(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws?room=bad%20room"
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestRoom_SameUsernameAndHostInDifferentRooms(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	hostA := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer hostA.Close()
+	hostB := joinTestRoom(t, ts.URL, "squad-b", "Alice", true)
+	defer hostB.Close()
+
+	roomA := getRoom("squad-a")
+	roomB := getRoom("squad-b")
+	require.NotNil(t, roomA)
+	require.NotNil(t, roomB)
+	require.NotSame(t, roomA, roomB)
+	require.True(t, roomA.hasHost())
+	require.True(t, roomB.hasHost())
+	require.True(t, roomA.isUsernameTaken("alice"))
+	require.True(t, roomB.isUsernameTaken("alice"))
+	require.False(t, testRoom().isUsernameTaken("alice"))
+}
+
+func TestRoom_NoCrossRoomBroadcasts(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	alice := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer alice.Close()
+	bob := joinTestRoom(t, ts.URL, "squad-b", "Bob", true)
+	defer bob.Close()
+
+	// Joining squad-a must not have reached squad-b
+	require.Empty(t, bob.drain())
+
+	// Issue, vote and reveal in squad-a
+	require.NoError(t, alice.WriteJSON(types.Message{Type: types.NewIssue, Payload: "A-1"}))
+	require.NoError(t, alice.WriteJSON(types.Message{Type: types.Estimate, Payload: "8"}))
+	require.NoError(t, alice.WriteJSON(types.Message{Type: types.Reveal}))
+
+	received := alice.drain()
+	require.NotEmpty(t, received)
+	require.Empty(t, bob.drain(), "squad-b must not see squad-a traffic")
+
+	// State stays per room
+	roomA := getRoom("squad-a")
+	roomB := getRoom("squad-b")
+	roomA.mutex.Lock()
+	require.Equal(t, "A-1", roomA.currentIssue)
+	roomA.mutex.Unlock()
+	roomB.mutex.Lock()
+	require.Equal(t, "", roomB.currentIssue)
+	for client := range roomB.clients {
+		require.False(t, client.CurrentEstimate.Voted())
+	}
+	roomB.mutex.Unlock()
+}
+
+func TestRoom_QueueIsPerRoom(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	alice := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer alice.Close()
+	bob := joinTestRoom(t, ts.URL, "squad-b", "Bob", true)
+	defer bob.Close()
+
+	err := alice.WriteJSON(types.QueueAddMessage{
+		Type: types.MessageQueueAdd,
+		Payload: types.QueueAddPayload{
+			Identifier: "A-2",
+			Title:      "Only in squad-a",
+		},
+	})
+	require.NoError(t, err)
+
+	alice.drain()
+	require.Empty(t, bob.drain())
+
+	roomA := getRoom("squad-a")
+	roomA.mutex.Lock()
+	require.Len(t, roomA.queueItems, 1)
+	roomA.mutex.Unlock()
+
+	roomB := getRoom("squad-b")
+	roomB.mutex.Lock()
+	require.Len(t, roomB.queueItems, 0)
+	roomB.mutex.Unlock()
+}
+
+func TestRoom_TornDownWhenEmpty(t *testing.T) {
+	ts := newRoomTestServer(t)
+	SetResumeGracePeriod(50 * time.Millisecond)
+
+	conn := joinTestRoom(t, ts.URL, "ephemeral", "Alice", false)
+	require.NotNil(t, getRoom("ephemeral"))
+
+	conn.Close()
+
+	require.Eventually(t, func() bool {
+		return getRoom("ephemeral") == nil
+	}, time.Second, 20*time.Millisecond)
+
+	// A fresh connection recreates the room with clean state
+	conn = joinTestRoom(t, ts.URL, "ephemeral", "Alice", true)
+	defer conn.Close()
+	room := getRoom("ephemeral")
+	require.NotNil(t, room)
+	require.True(t, room.hasHost())
+}
+
+func TestRoom_DefaultRoomPersists(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	conn := joinTestRoom(t, ts.URL, "", "Alice", false)
+	room := testRoom()
+	conn.Close()
+
+	require.Eventually(t, func() bool {
+		room.mutex.Lock()
+		defer room.mutex.Unlock()
+		return len(room.clients) == 0
+	}, time.Second, 20*time.Millisecond)
+
+	require.Same(t, room, testRoom())
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jcpsimmons/poker/messaging"
+	"github.com/jcpsimmons/poker/types"
+
+	"github.com/gorilla/websocket"
+)
+
+// roundStateMessageUnlocked describes the current round (caller must hold mutex)
+func (r *Room) roundStateMessageUnlocked() types.RoundStateMessage {
+	return types.RoundStateMessage{
+		Type: types.RoundState,
+		Payload: types.RoundStatePayload{
+			Phase:  r.phase,
+			Round:  r.round,
+			Issue:  r.currentIssue,
+			Reveal: r.lastReveal,
+			Timer:  r.timerPayloadUnlocked(),
+
+			AutoReveal:        r.autoReveal,
+			AutoRevealDelayMs: r.autoRevealDelay.Milliseconds(),
+		},
+	}
+}
+
+// getPhase returns the phase of the current round
+func (r *Room) getPhase() types.RoundPhase {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.phase
+}
+
+// setPhase moves the round to phase and broadcasts the transition. Moving
+// into the phase the round is already in only updates the reveal results.
+func (r *Room) setPhase(phase types.RoundPhase, reveal *types.RevealPayload, sender *Client) {
+	r.mutex.Lock()
+	unchanged := r.phase == phase
+	r.lastReveal = reveal
+	if unchanged {
+		r.mutex.Unlock()
+		return
+	}
+	if phase == types.RoundVoting {
+		r.round++
+		r.startedAt = time.Now().UTC()
+	}
+	r.phase = phase
+	message := r.roundStateMessageUnlocked()
+	r.mutex.Unlock()
+
+	r.log.Info("Round phase changed", "round", message.Payload.Round, "phase", phase)
+	r.broadcast(messaging.MarshallMessage(message), sender)
+}
+
+// startVoting opens a round when an issue is loaded or the first vote is
+// cast. Starting a round over a revealed one clears the old votes first.
+func (r *Room) startVoting(sender *Client) {
+	switch r.getPhase() {
+	case types.RoundVoting:
+		return
+	case types.RoundRevealed:
+		r.handleReset(sender)
+	}
+	r.setPhase(types.RoundVoting, nil, sender)
+}
+
+// handleEstimate records a participant's vote, opening the round if it is
+// the first one
+func (r *Room) handleEstimate(raw string, sender *Client) error {
+	if r.getPhase() == types.RoundRevealed {
+		r.log.Info("Rejected estimate, round already revealed", "user", sender.UserID)
+		return newCommandError(types.EstimateError, errVotesLocked)
+	}
+	estimate, ok := r.deck.estimate(raw)
+	if !ok {
+		r.log.Info("Rejected estimate not in the deck", "user", sender.UserID, "estimate", raw, "deck", r.deck.Name)
+		return &CommandError{
+			Code:   types.CodeInvalidEstimate,
+			Legacy: types.EstimateError,
+			Err:    fmt.Errorf("%q is not a card in the %s deck", raw, r.deck.Name),
+		}
+	}
+	r.mutex.Lock()
+	sender.CurrentEstimate = estimate
+	r.mutex.Unlock()
+	r.broadcastVoteStatus(sender)
+	r.startVoting(sender)
+	return nil
+}
+
+// errNotVoting rejects revealing a round that isn't being voted on
+var errNotVoting = errors.New("no round is being voted on")
+
+// revealRound shows everybody's votes and locks them until the next round.
+// Only a round that is being voted on can be revealed.
+func (r *Room) revealRound(sender *Client) error {
+	if phase := r.getPhase(); phase != types.RoundVoting {
+		return &CommandError{Code: types.CodeUnavailable, Err: fmt.Errorf("%w, the round is %s", errNotVoting, phase)}
+	}
+
+	// The countdown and a pending auto-reveal are moot once the votes are out
+	r.cancelTimer(sender)
+	r.mutex.Lock()
+	r.stopAutoRevealUnlocked()
+	r.mutex.Unlock()
+
+	estimates := r.getFormattedRevealData()
+	reveal := types.RevealPayload{
+		PointAvg:  r.getPointAverageLabel(),
+		Estimates: estimates,
+		Specials:  specialSummaries(estimates),
+		Stats:     r.getRevealStats(),
+	}
+	r.recordRound(reveal)
+	r.server.metrics.roundsRevealed.Add(1)
+
+	message := types.RevealMessage{
+		Type:    types.RevealData,
+		Payload: reveal,
+	}
+	byteMessage := messaging.MarshallMessage(message)
+	r.broadcast(byteMessage, sender)
+
+	r.setPhase(types.RoundRevealed, &reveal, sender)
+	return nil
+}
+
+// closeRound marks the round as finished once its votes have been cleared
+func (r *Room) closeRound(sender *Client) {
+	r.setPhase(types.RoundClosed, nil, sender)
+}
+
+// sendRoundState tells a joining client which phase the round is in,
+// including the results if it has already been revealed
+func (r *Room) sendRoundState(client *Client) {
+	r.mutex.Lock()
+	message := r.roundStateMessageUnlocked()
+	r.mutex.Unlock()
+
+	byteMessage := messaging.MarshallMessage(message)
+	if err := client.WriteMessage(websocket.TextMessage, byteMessage); err != nil {
+		r.log.Warn("Error sending round state", "user", client.UserID, "err", err)
+	}
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jcpsimmons/poker/types"
+	"github.com/stretchr/testify/require"
+)
+
+// roundStates returns the payloads of all roundState messages in order
+func roundStates(messages []map[string]interface{}) []map[string]interface{} {
+	var states []map[string]interface{}
+	for _, msg := range messages {
+		if msg["type"] == string(types.RoundState) {
+			states = append(states, msg["payload"].(map[string]interface{}))
+		}
+	}
+	return states
+}
+
+func TestRound_Lifecycle(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+
+	states := roundStates(host.welcome)
+	require.Len(t, states, 1)
+	require.Equal(t, string(types.RoundIdle), states[0]["phase"])
+
+	steps := []struct {
+		message types.Message
+		phase   types.RoundPhase
+		round   float64
+	}{
+		{message: types.Message{Type: types.Estimate, Payload: "5"}, phase: types.RoundVoting, round: 1},
+		{message: types.Message{Type: types.Reveal}, phase: types.RoundRevealed, round: 1},
+		{message: types.Message{Type: types.Reset}, phase: types.RoundClosed, round: 1},
+		{message: types.Message{Type: types.NewIssue, Payload: "CDP-2"}, phase: types.RoundVoting, round: 2},
+	}
+	for _, step := range steps {
+		require.NoError(t, host.WriteJSON(step.message))
+		states := roundStates(host.drain())
+		require.Len(t, states, 1, "%s should cause one transition", step.message.Type)
+		require.Equal(t, string(step.phase), states[0]["phase"])
+		require.Equal(t, step.round, states[0]["round"])
+	}
+
+	// Further votes in an open round don't repeat the transition
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Estimate, Payload: "3"}))
+	require.Empty(t, roundStates(host.drain()))
+}
+
+func TestRound_RevealOnlyWhileVoting(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinVersioned(t, ts.URL, "squad-a", types.JoinPayload{Username: "Alice", IsHost: true})
+	defer host.Close()
+
+	// Each step leaves the round in the phase a reveal is rejected in
+	steps := []struct {
+		phase types.RoundPhase
+		setup []types.MessageType
+	}{
+		{phase: types.RoundIdle},
+		{phase: types.RoundRevealed, setup: []types.MessageType{types.Estimate, types.Reveal}},
+		{phase: types.RoundClosed, setup: []types.MessageType{types.Reset}},
+	}
+	for _, step := range steps {
+		for i, messageType := range step.setup {
+			var payload interface{}
+			if messageType == types.Estimate {
+				payload = "5"
+			}
+			sendCommand(t, host, messageType, fmt.Sprintf("%s-setup-%d", step.phase, i), payload)
+		}
+		host.drain()
+		require.Equal(t, step.phase, getRoom("squad-a").getPhase())
+
+		requestID := fmt.Sprintf("%s-reveal", step.phase)
+		sendCommand(t, host, types.Reveal, requestID, nil)
+		messages := host.drain()
+		reply := replyTo(messages, requestID)
+		require.NotNil(t, reply)
+		require.Equal(t, string(types.Error), reply["type"])
+		require.Equal(t, string(types.CodeUnavailable), reply["payload"].(map[string]interface{})["code"])
+		require.Empty(t, roundStates(messages), "a rejected reveal doesn't change the phase")
+		require.Nil(t, findMessage(messages, types.RevealData))
+	}
+}
+
+func TestRound_VotesLockedAfterReveal(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Estimate, Payload: "5"}))
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Reveal}))
+	host.drain()
+
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Estimate, Payload: "8"}))
+	require.NotNil(t, findMessage(host.drain(), types.EstimateError))
+
+	room := getRoom("squad-a")
+	room.mutex.Lock()
+	defer room.mutex.Unlock()
+	require.Equal(t, "5", room.findClientUnlocked("Alice").CurrentEstimate.Card)
+}
+
+func TestRound_LateJoinerSeesRevealedResults(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.NewIssue, Payload: "CDP-1"}))
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Estimate, Payload: "8"}))
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Reveal}))
+	host.drain()
+
+	late := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+	defer late.Close()
+
+	states := roundStates(late.welcome)
+	require.Len(t, states, 1)
+	require.Equal(t, string(types.RoundRevealed), states[0]["phase"])
+	require.Equal(t, "CDP-1", states[0]["issue"])
+
+	reveal := states[0]["reveal"].(map[string]interface{})
+	require.Equal(t, "8", reveal["pointAvg"])
+	require.Len(t, reveal["estimates"], 1, "the snapshot shows the revealed votes, not the late joiner")
+}
+
+func TestRound_NewIssueAfterRevealClearsVotes(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Estimate, Payload: "5"}))
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Reveal}))
+	host.drain()
+
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.NewIssue, Payload: "CDP-2"}))
+	messages := host.drain()
+	require.NotNil(t, findMessage(messages, types.ClearBoard))
+
+	states := roundStates(messages)
+	require.Len(t, states, 1)
+	require.Equal(t, string(types.RoundVoting), states[0]["phase"])
+	require.Nil(t, states[0]["reveal"])
+
+	room := getRoom("squad-a")
+	room.mutex.Lock()
+	defer room.mutex.Unlock()
+	require.False(t, room.findClientUnlocked("Alice").CurrentEstimate.Voted())
+}
+
+func TestRound_PhaseRestoredAfterRestart(t *testing.T) {
+	ts := newRoomTestServer(t)
+	dir := t.TempDir()
+	require.NoError(t, SetDataDir(dir))
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Estimate, Payload: "5"}))
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Reveal}))
+	host.drain()
+	host.Close()
+
+	ResetServerState()
+	require.NoError(t, SetDataDir(dir))
+
+	player := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+	defer player.Close()
+
+	states := roundStates(player.welcome)
+	require.Len(t, states, 1)
+	require.Equal(t, string(types.RoundRevealed), states[0]["phase"])
+	require.Equal(t, float64(1), states[0]["round"])
+	require.NotNil(t, states[0]["reveal"])
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// defaultSendQueueSize is how many messages may wait for a client before it
+// is considered too slow and disconnected
+const defaultSendQueueSize = 256
+
+var errSlowConsumer = errors.New("send queue is full, client can't keep up")
+
+// SetSendQueueSize configures how many outbound messages are buffered per
+// client. A client whose buffer overflows is disconnected.
+func SetSendQueueSize(size int) {
+	defaultServer().setSendQueueSize(size)
+}
+
+func (s *Server) setSendQueueSize(size int) {
+	if size > 0 {
+		s.sendQueueSize.Store(int64(size))
+	}
+}
+
+// outbound is a message waiting in a client's send queue
+type outbound struct {
+	messageType int
+	data        []byte
+}
+
+// newClient wraps a connection with its send queue. Nothing is written
+// until writePump runs.
+func (s *Server) newClient(conn *websocket.Conn) *Client {
+	return &Client{
+		Conn:    conn,
+		server:  s,
+		send:    make(chan outbound, s.sendQueueSize.Load()),
+		flushed: make(chan struct{}),
+	}
+}
+
+// logger returns the logger of the client's room, or of its server
+// before it joined one. Clients built by hand in tests have neither and
+// use the default logger.
+func (c *Client) logger() *slog.Logger {
+	switch {
+	case c.Room != nil:
+		return c.Room.log
+	case c.server != nil:
+		return c.server.log
+	}
+	return slog.Default()
+}
+
+// WriteMessage queues a message for the client's writer. It never blocks:
+// a client whose queue is full is disconnected instead of holding up the
+// rest of the room. Clients without a queue are written to directly, and
+// messages to API stand-ins are dropped. Every failed message is counted
+// in the metrics, whether it was broadcast or sent to this client alone.
+func (c *Client) WriteMessage(messageType int, data []byte) error {
+	if c.api {
+		return nil
+	}
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	if c.Conn == nil || c.closing {
+		c.countDropped("write_failed")
+		return websocket.ErrCloseSent // Connection already closed
+	}
+	if c.send == nil {
+		c.Conn.SetWriteDeadline(c.server.writeDeadline())
+		err := c.Conn.WriteMessage(messageType, data)
+		if err != nil {
+			c.countDropped("write_failed")
+		}
+		return err
+	}
+
+	select {
+	case c.send <- outbound{messageType: messageType, data: data}:
+		return nil
+	default:
+		c.logger().Warn("Client can't keep up, disconnecting it", "user", c.UserID, "queued", len(c.send))
+		c.countDropped("slow_consumer")
+		c.closing = true
+		c.Conn.Close()
+		return errSlowConsumer
+	}
+}
+
+// countDropped records a message the client couldn't be sent, by reason.
+// Clients built by hand in tests have no server to count it on.
+func (c *Client) countDropped(reason string) {
+	if c.server != nil {
+		c.server.metrics.clientsDropped.inc(reason)
+	}
+}
+
+// Close writes whatever is still queued and then closes the connection, so
+// a final message such as a join error or a kick notice isn't lost
+func (c *Client) Close() {
+	c.closeWith(websocket.CloseNormalClosure, "")
+}
+
+// closeWith is Close with the given close code and reason
+func (c *Client) closeWith(code int, reason string) {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	if c.Conn == nil || c.closing {
+		return
+	}
+	c.closing = true
+	c.closeMessage = websocket.FormatCloseMessage(code, reason)
+	if c.send == nil {
+		c.Conn.WriteControl(websocket.CloseMessage, c.closeMessage, c.server.writeDeadline())
+		c.Conn.Close()
+		return
+	}
+	close(c.send)
+}
+
+// waitFlushed blocks until writePump has stopped, or ctx is done
+func (c *Client) waitFlushed(ctx context.Context) error {
+	if c.flushed == nil {
+		return nil
+	}
+	select {
+	case <-c.flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// writePump is the only goroutine writing messages to the connection. It
+// also pings the client, and stops once the queue is closed or a write
+// fails.
+func (c *Client) writePump() {
+	ticker := time.NewTicker(time.Duration(c.server.pingInterval.Load()))
+	defer func() {
+		ticker.Stop()
+		c.Conn.Close()
+		close(c.flushed)
+	}()
+
+	for {
+		select {
+		case message, ok := <-c.send:
+			deadline := c.server.writeDeadline()
+			if !ok {
+				c.Conn.WriteControl(websocket.CloseMessage, c.closeMessage, deadline)
+				return
+			}
+			c.Conn.SetWriteDeadline(deadline)
+			if err := c.Conn.WriteMessage(message.messageType, message.data); err != nil {
+				c.logger().Warn("Error writing to client, closing connection", "remote", c.Conn.RemoteAddr().String(), "err", err)
+				return
+			}
+		case <-ticker.C:
+			deadline := c.server.writeDeadline()
+			if err := c.Conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				c.logger().Info("Ping failed, closing connection", "remote", c.Conn.RemoteAddr().String(), "err", err)
+				return
+			}
+		}
+	}
+}
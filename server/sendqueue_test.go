@@ -0,0 +1,147 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jcpsimmons/poker/messaging"
+	"github.com/jcpsimmons/poker/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendQueue_SlowConsumerDisconnected(t *testing.T) {
+	ts := newRoomTestServer(t)
+	SetSendQueueSize(16)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+
+	// Bob joins and then stops reading
+	stalled := connectTestClientToRoom(t, ts.URL, "squad-a")
+	defer stalled.Close()
+	require.NoError(t, stalled.WriteJSON(types.JoinMessage{
+		Type:    types.Join,
+		Payload: types.JoinPayload{Username: "Bob"},
+	}))
+	host.drain()
+
+	// Large messages fill the socket buffers, after which Bob's queue
+	// overflows. Sending to him must never block.
+	room := getRoom("squad-a")
+	room.mutex.Lock()
+	bob := room.findClientUnlocked("Bob")
+	room.mutex.Unlock()
+	require.NotNil(t, bob)
+
+	filler := types.Message{Type: "filler", Payload: strings.Repeat("x", 1<<20)}
+	start := time.Now()
+	for i := 0; i < 40; i++ {
+		sendClientMessage(bob, filler)
+	}
+	// A blocking write would wait out the 10s write timeout
+	require.Less(t, time.Since(start), 5*time.Second, "sending waited for the stalled client")
+
+	require.Eventually(t, func() bool {
+		room.mutex.Lock()
+		defer room.mutex.Unlock()
+		return room.findClientUnlocked("Bob") == nil
+	}, 2*time.Second, 20*time.Millisecond)
+	var messages []map[string]interface{}
+	require.Eventually(t, func() bool {
+		messages = append(messages, host.drain()...)
+		count := lastMessage(messages, types.ParticipantCount)
+		return count != nil && count["payload"] == "1"
+	}, 10*time.Second, 50*time.Millisecond)
+}
+
+func TestSendQueue_CloseFlushesQueuedMessages(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	client := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer client.Close()
+
+	room := getRoom("squad-a")
+	room.mutex.Lock()
+	alice := room.findClientUnlocked("Alice")
+	room.mutex.Unlock()
+
+	for i := 0; i < 10; i++ {
+		sendClientMessage(alice, types.Message{Type: types.CurrentEstimate, Payload: fmt.Sprint(i)})
+	}
+	alice.Close()
+	require.Error(t, alice.WriteMessage(websocket.TextMessage, []byte("{}")), "nothing is queued after Close")
+
+	messages := client.drain()
+	require.Len(t, messages, 10)
+	require.Equal(t, "9", messages[9]["payload"])
+}
+
+// BenchmarkBroadcast_StalledClient measures how long a broadcast takes to
+// reach 99 clients while a 100th has stopped reading
+func BenchmarkBroadcast_StalledClient(b *testing.B) {
+	ResetServerState()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "?room=bench"
+
+	join := func(username string) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		require.NoError(b, err)
+		require.NoError(b, conn.WriteJSON(types.JoinMessage{
+			Type:    types.Join,
+			Payload: types.JoinPayload{Username: username},
+		}))
+		return conn
+	}
+
+	delivered := make(chan struct{}, 1024)
+	for i := 0; i < 99; i++ {
+		conn := join(fmt.Sprintf("player-%d", i))
+		defer conn.Close()
+		go func() {
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+				delivered <- struct{}{}
+			}
+		}()
+	}
+	stalled := join("stalled")
+	defer stalled.Close()
+
+	room := getRoom("bench")
+	require.Eventually(b, func() bool {
+		room.mutex.Lock()
+		defer room.mutex.Unlock()
+		return room.participantCountUnlocked() == 100
+	}, 10*time.Second, 10*time.Millisecond)
+	// Let the join announcements drain
+	for quiet := false; !quiet; {
+		select {
+		case <-delivered:
+		case <-time.After(100 * time.Millisecond):
+			quiet = true
+		}
+	}
+
+	message := messaging.MarshallMessage(types.Message{Type: types.CurrentEstimate, Payload: "5"})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		room.broadcast(message, nil)
+		for j := 0; j < 99; j++ {
+			<-delivered
+		}
+	}
+}
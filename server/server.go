@@ -1,16 +1,19 @@
 package server
 
 import (
+	"context"
 	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"math"
 	"net/http"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unicode/utf8"
 
@@ -23,67 +26,46 @@ import (
 
 type Client struct {
 	Conn            *websocket.Conn
-	writeMutex      sync.Mutex // Serializes writes to this connection
+	server          *Server       // Server the client connected to
+	api             bool          // Stands in for an API request; messages to it are dropped
+	writeMutex      sync.Mutex    // Guards send and closing
+	send            chan outbound // Drained by writePump; nil for clients written to directly
+	closing         bool          // Set once the connection is being closed
+	closeMessage    []byte        // Close frame writePump ends with
+	flushed         chan struct{} // Closed once writePump has stopped
+	Room            *Room         // Room this client is connected to
 	UserID          string
-	CurrentEstimate int64
+	CurrentEstimate Estimate
 	IsHost          bool
+	IsObserver      bool         // Receives everything but never votes
+	JoinedAt        time.Time    // First joined the session, kept across resumes
+	protocol        atomic.Int32 // Envelope version the client last sent
 }
 
-// WriteMessage safely writes to the WebSocket connection with mutex protection
-func (c *Client) WriteMessage(messageType int, data []byte) error {
-	c.writeMutex.Lock()
-	defer c.writeMutex.Unlock()
-	if c.Conn == nil {
-		return websocket.ErrCloseSent // Connection already closed
-	}
-	return c.Conn.WriteMessage(messageType, data)
+// versioned reports whether the client speaks the versioned protocol
+func (c *Client) versioned() bool {
+	return c.protocol.Load() >= types.ProtocolVersion
 }
 
-// clients stores all active client connections.
-var clients = make(map[*Client]bool)
-var currentIssue string = ""
-
-// Linear integration state
-var linearClient *linear.LinearClient
-var linearIssues []types.LinearIssue
-var currentIssueIndex int = -1
-var currentLinearIssue *types.LinearIssue
-var pendingQueueIndex int = -1
-var confirmedIssues = make(map[string]bool)
-
-// Queue state
-var queueItems []types.QueueItem
-var currentQueueIndex int = -1 // Track which queue item is currently being voted on (not used yet, reserved for future)
-var queueItemCounter int = 0   // For generating unique IDs for custom items
-
-// Track if we just assigned an estimate (for auto-advance notification)
-var justAssignedEstimate bool = false
-
-// mutex is used to synchronize access to the clients map.
-var mutex = &sync.Mutex{}
-
-// Basic authentication configuration
-var (
-	basicAuthUsername = "admin"
-	basicAuthPassword = ""
-	authEnabled       = false
-)
-
 // SetBasicAuth configures HTTP Basic Authentication
 func SetBasicAuth(username, password string) {
+	defaultServer().setBasicAuth(username, password)
+}
+
+func (s *Server) setBasicAuth(username, password string) {
 	if password != "" {
-		basicAuthUsername = username
-		basicAuthPassword = password
-		authEnabled = true
-		log.Println("HTTP Basic Authentication enabled")
+		s.authUsername = username
+		s.authPassword = password
+		s.authEnabled = true
+		s.log.Info("HTTP Basic Authentication enabled")
 	}
 }
 
 // basicAuthMiddleware checks HTTP Basic Auth for both HTTP and WebSocket requests
-func basicAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
+func (s *Server) basicAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Skip auth if not enabled
-		if !authEnabled {
+		if !s.authEnabled {
 			next(w, r)
 			return
 		}
@@ -122,7 +104,7 @@ func basicAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		}
 
 		parts := strings.SplitN(string(decoded), ":", 2)
-		if len(parts) != 2 || parts[0] != basicAuthUsername || parts[1] != basicAuthPassword {
+		if len(parts) != 2 || parts[0] != s.authUsername || parts[1] != s.authPassword {
 			w.Header().Set("WWW-Authenticate", `Basic realm="Planning Poker"`)
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
@@ -140,41 +122,38 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-func Start(port string) {
-	strPort := ":" + port
-
-	// Register HTTP handlers on the default mux
-	RegisterHandlers()
-
-	log.Println("Starting server on port", strPort)
-	log.Println("Serving web app on http://localhost" + strPort)
-	log.Println("WebSocket endpoint: ws://localhost" + strPort + "/ws")
-	log.Fatal(http.ListenAndServe(strPort, nil))
+// SetLinearIssues initializes Linear integration with issues and client.
+// Linear issues are always loaded into the default room.
+func SetLinearIssues(issues []types.LinearIssue, client *linear.LinearClient) {
+	defaultServer().SetLinearIssues(issues, client)
 }
 
-// RegisterHandlers sets up the static file server and WebSocket endpoint
-// on the default HTTP serve mux. This allows serving either on a local
-// TCP listener or any custom net.Listener (e.g., ngrok) using http.Serve.
-func RegisterHandlers() {
-	// Serve static files from web/dist (no auth - let users load the app)
-	fs := http.FileServer(http.Dir("./web/dist"))
-	http.Handle("/", fs)
+// SetLinearIssues loads issues into the default room's queue and posts
+// results and estimates for them with client. Call it before serving.
+func (s *Server) SetLinearIssues(issues []types.LinearIssue, client *linear.LinearClient) {
+	s.useLinear(client)
+	r := s.getRoom(defaultRoomSlug)
+	defer r.persist()
 
-	// WebSocket endpoint with auth middleware (only protect the WS connection)
-	http.HandleFunc("/ws", basicAuthMiddleware(handler))
-}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 
-// SetLinearIssues initializes Linear integration with issues and client
-func SetLinearIssues(issues []types.LinearIssue, client *linear.LinearClient) {
-	linearIssues = issues
-	linearClient = client
-	currentIssueIndex = -1
-	pendingQueueIndex = 0 // First issue ready to be suggested
+	// A restored snapshot already carries the Linear queue and cursor
+	if r.restored && len(r.linearIssues) > 0 {
+		r.linearClient = client
+		s.log.Info("Linear integration enabled, keeping restored queue", "room", r.Slug, "queueItems", len(r.queueItems))
+		return
+	}
+
+	r.linearIssues = issues
+	r.linearClient = client
+	r.currentIssueIndex = -1
+	r.pendingQueueIndex = 0 // First issue ready to be suggested
 
 	// Initialize queue from Linear issues
-	queueItems = make([]types.QueueItem, 0, len(issues))
+	r.queueItems = make([]types.QueueItem, 0, len(issues))
 	for i, issue := range issues {
-		queueItems = append(queueItems, types.QueueItem{
+		r.queueItems = append(r.queueItems, types.QueueItem{
 			ID:          issue.ID,
 			Source:      "linear",
 			Identifier:  issue.Identifier,
@@ -185,269 +164,365 @@ func SetLinearIssues(issues []types.LinearIssue, client *linear.LinearClient) {
 			Index:       i,
 		})
 	}
-	currentQueueIndex = -1
-	queueItemCounter = 0
+	r.currentQueueIndex = -1
+	r.queueItemCounter = 0
 
-	log.Printf("Linear integration enabled with %d issues", len(issues))
-	log.Printf("Queue initialized with %d items", len(queueItems))
+	s.log.Info("Linear integration enabled", "room", r.Slug, "issues", len(issues), "queueItems", len(r.queueItems))
 
 	// Broadcast queue to all connected clients
-	mutex.Lock()
-	if len(clients) > 0 {
-		broadcastQueueSyncUnlocked()
+	if len(r.clients) > 0 {
+		r.broadcastQueueSyncUnlocked()
 	}
-	mutex.Unlock()
 }
 
-// handler handles incoming WebSocket connections.
-func handler(w http.ResponseWriter, r *http.Request) {
+// handleWebSocket handles incoming WebSocket connections. The room is
+// selected with the ?room= query parameter and created on demand.
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if s.closed.Load() {
+		http.Error(w, shutdownReason, http.StatusServiceUnavailable)
+		return
+	}
+	slug, err := normalizeRoomSlug(r.URL.Query().Get("room"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		w.Write([]byte("Server running. Must connect via WS."))
 		return
 	}
-	client := &Client{Conn: conn}
+	client := s.newClient(conn)
+	room := s.joinRoom(slug, client)
 
-	mutex.Lock()
-	clients[client] = true
-	mutex.Unlock()
+	go room.handleMessages(client)
+}
 
-	go handleMessages(client)
+// persistedMessageTypes lists the messages that mutate session state and
+// therefore trigger a snapshot when persistence is enabled
+var persistedMessageTypes = map[types.MessageType]bool{
+	types.Join:                  true,
+	types.Resume:                true,
+	types.NewIssue:              true,
+	types.Estimate:              true,
+	types.Reveal:                true,
+	types.Reset:                 true,
+	types.MessageIssueConfirm:   true,
+	types.MessageQueueAdd:       true,
+	types.MessageQueueUpdate:    true,
+	types.MessageQueueDelete:    true,
+	types.MessageQueueReorder:   true,
+	types.MessageAssignEstimate: true,
+	types.Kick:                  true,
+	types.Rename:                true,
+	types.ClearVote:             true,
+	types.TransferHost:          true,
+	types.SetCoHost:             true,
+	types.SetAutoReveal:         true,
 }
 
-// handleMessages reads messages from the client and broadcasts them to other clients.
-func handleMessages(client *Client) {
+// handleMessages reads messages from the client and runs them on the
+// room's event loop, one at a time
+func (r *Room) handleMessages(client *Client) {
+	r.server.configureConn(client.Conn)
+	go client.writePump()
+
 	// Ensure client is removed on disconnect
 	defer func() {
-		log.Printf("[DEFER] handleMessages defer running for client %p (UserID: %s)", client, client.UserID)
-		mutex.Lock()
-		_, wasConnected := clients[client]
-		log.Printf("[DEFER] Client %p wasConnected: %v", client, wasConnected)
-		if wasConnected {
-			delete(clients, client)
-			log.Printf("[DEFER] Client disconnected (defer): %s", client.UserID)
-		}
-		mutex.Unlock()
-		client.Conn.Close()
-		// Only broadcast if we actually removed a connected client
-		if wasConnected {
-			log.Printf("[DEFER] Broadcasting participant count update (client %s was connected)", client.UserID)
-			broadcastParticipCount(client)
-		} else {
-			log.Printf("[DEFER] Skipping broadcast - client %p was not in clients map", client)
-		}
+		client.Close()
+		r.do(func() {
+			wasConnected := leaveRoom(client)
+			// Only broadcast if we actually removed a connected client
+			if !wasConnected {
+				r.log.Debug("Connection closed after leaving the room", "user", client.UserID)
+				return
+			}
+			r.log.Info("User disconnected", "user", client.UserID)
+			r.broadcastParticipCount(client)
+			// Drop them from everybody's voter list; this also auto-reveals
+			// if they were the last one yet to vote
+			r.broadcastVoteStatus(client)
+		})
 	}()
 
 	for {
 		_, message, err := client.Conn.ReadMessage()
 		if err != nil {
-			log.Println("Error reading message:", err)
+			r.log.Info("Connection closed", "remote", client.Conn.RemoteAddr().String(), "reason", readFailure(err))
 			break
 		}
-		log.Println("raw message received: ", string(message))
-		messageObject := messaging.UnmarshallMessage(message)
-		switch messageObject.Type {
-		case types.Join:
-			log.Printf("[JOIN] Processing join request from client %p (UserID: %s)", client, client.UserID)
-			// Try to parse as new format first (with isHost), fallback to old format
-			var username string
-			var isHost bool
-
-			var joinMsg types.JoinMessage
-			if err := json.Unmarshal(message, &joinMsg); err == nil && joinMsg.Payload.Username != "" {
-				// New format with isHost
-				username = joinMsg.Payload.Username
-				isHost = joinMsg.Payload.IsHost
-				log.Printf("[JOIN] Parsed join message - username: %s, isHost: %v", username, isHost)
-			} else {
-				// Old format (just username string)
-				if messageObject.Payload == "" {
-					log.Println("[JOIN] Error joining: no username specified")
-					break
-				}
-				username = messageObject.Payload
-				isHost = false
-				log.Printf("[JOIN] Parsed old format join - username: %s", username)
+		r.server.extendReadDeadline(client.Conn)
+		envelope, err := decodeEnvelope(message)
+		r.server.metrics.messages.inc(messageLabel(envelope, err))
+		switch {
+		case err != nil:
+			// Answer garbage in the protocol the client last spoke
+			envelope.Version = int(client.protocol.Load())
+		case envelope.Version >= types.ProtocolVersion:
+			client.protocol.Store(int32(envelope.Version))
+		}
+
+		dropped := false
+		ran := r.do(func() {
+			r.log.Debug("Message received", "user", client.UserID, "type", envelope.Type,
+				"requestId", envelope.RequestID, "payload", string(message))
+			if err == nil {
+				err = r.handleCommand(envelope, client)
 			}
+			reply(client, envelope, err)
+
+			switch {
+			case disconnects(err):
+				r.log.Info("Dropping client after failed command", "user", client.UserID, "type", envelope.Type, "requestId", envelope.RequestID)
+				client.Close()
+				// Remove from room (tears down an on-demand room if this was its only client)
+				leaveRoom(client)
+				dropped = true
+			case err == nil && persistedMessageTypes[envelope.Type]:
+				r.persist()
+			}
+		})
+		if !ran || dropped {
+			return
+		}
+	}
+}
 
-			// Only proceed if join was successful
-			log.Printf("[JOIN] Calling handleJoin for username: %s, isHost: %v", username, isHost)
-			joinSuccess := handleJoin(username, client, isHost)
-			log.Printf("[JOIN] handleJoin returned: %v", joinSuccess)
+// handleCommand authorizes and runs one client command. The returned error
+// is sent back to the client by reply.
+func (r *Room) handleCommand(envelope types.Envelope, client *Client) error {
+	if err := r.authorize(client, envelope.Type); err != nil {
+		return err
+	}
 
-			if !joinSuccess {
-				// Join failed (validation error or duplicate username)
-				log.Printf("[JOIN] Join failed, returning from handleMessages goroutine. Client: %p", client)
-				// Client has been notified and connection closed
-				// Return immediately to exit the goroutine and prevent defer cleanup from running
-				return
-			}
+	switch envelope.Type {
+	case types.Join:
+		payload, err := decodeJoinPayload(envelope)
+		if err != nil {
+			return err
+		}
+		role := joinRole(payload)
+		if err := r.handleJoin(payload.Username, client, role, false); err != nil {
+			return err
+		}
 
-			// Double-check client is still in map and connection is valid before proceeding
-			mutex.Lock()
-			_, stillConnected := clients[client]
-			mutex.Unlock()
-			if !stillConnected {
-				log.Printf("[JOIN] WARNING: Client %s not in clients map after successful join! Returning immediately.", username)
-				return
+		// Double-check client is still in map and connection is valid before proceeding
+		r.mutex.Lock()
+		_, stillConnected := r.clients[client]
+		r.mutex.Unlock()
+		if !stillConnected {
+			r.log.Warn("Client left the room while joining", "user", payload.Username)
+			return &CommandError{
+				Code:       types.CodeUnavailable,
+				Err:        errors.New("the connection closed while joining"),
+				Disconnect: true,
 			}
+		}
 
-			log.Printf("[JOIN] Join successful, proceeding with post-join setup for %s", username)
+		r.sendWelcome(client)
+	case types.Resume:
+		token, err := payloadString(envelope)
+		if err != nil {
+			return err
+		}
+		if err := r.handleResume(token, client); err != nil {
+			return err
+		}
+		r.sendWelcome(client)
+	case types.NewIssue:
+		issueText, err := payloadString(envelope)
+		if err != nil {
+			return err
+		}
+		// If we have Linear issues queued, use next from queue
+		r.mutex.Lock()
+		if r.linearClient != nil && r.currentIssueIndex >= 0 && r.currentIssueIndex < len(r.linearIssues) {
+			issue := r.linearIssues[r.currentIssueIndex]
+			r.currentLinearIssue = &issue
+			r.currentIssue = fmt.Sprintf("%s: %s", issue.Identifier, issue.Title)
+			r.log.Info("Loaded Linear issue", "issue", issue.Identifier)
+		} else {
+			// Manual issue entry
+			r.currentIssue = issueText
+			r.currentLinearIssue = nil
+		}
+		payload := types.CurrentIssuePayload{
+			Text:        r.currentIssue,
+			LinearIssue: r.currentLinearIssue,
+		}
+		r.mutex.Unlock()
+		r.broadcastCurrentIssue(payload, client)
+		// A new issue gets a fresh countdown
+		r.cancelTimer(client)
+		r.startVoting(client)
+	case types.Estimate:
+		value, err := payloadString(envelope)
+		if err != nil {
+			return err
+		}
+		return r.handleEstimate(value, client)
+	case types.Reveal:
+		return r.revealRound(client)
+	case types.Reset:
+		r.resetRound(client)
+
+	case types.MessageIssueConfirm:
+		var payload types.IssueConfirmPayload
+		if err := decodePayload(envelope, &payload); err != nil {
+			return err
+		}
+		return r.handleIssueConfirm(payload, client)
 
-			// send cur issue (with linearIssue if applicable)
-			log.Printf("[JOIN] Sending current issue to %s", username)
-			var currentIssuePayload types.CurrentIssuePayload
-			currentIssuePayload.Text = currentIssue
-			if currentLinearIssue != nil {
-				currentIssuePayload.LinearIssue = currentLinearIssue
-			}
+	case types.MessageQueueAdd:
+		var payload types.QueueAddPayload
+		if err := decodePayload(envelope, &payload); err != nil {
+			return err
+		}
+		r.handleQueueAdd(payload, client)
 
-			currentIssuePayloadJSON, err := json.Marshal(currentIssuePayload)
-			if err == nil {
-				curIssueMessage := types.Message{
-					Type:    types.CurrentIssue,
-					Payload: string(currentIssuePayloadJSON),
-				}
-				sendClientMessage(client, curIssueMessage)
-			} else {
-				// Fallback to simple string
-				curIssueMessage := types.Message{
-					Type:    types.CurrentIssue,
-					Payload: currentIssue,
-				}
-				sendClientMessage(client, curIssueMessage)
-			}
+	case types.MessageQueueUpdate:
+		var payload types.QueueUpdatePayload
+		if err := decodePayload(envelope, &payload); err != nil {
+			return err
+		}
+		return r.handleQueueUpdate(payload, client)
 
-			log.Printf("[JOIN] Calculating point average for %s", username)
-			pointAvgStr := strconv.FormatInt(int64(getPointAverage()), 10)
-			log.Printf("[JOIN] Point average calculated: %s", pointAvgStr)
-			estimateMessage := types.Message{
-				Type:    types.CurrentEstimate,
-				Payload: pointAvgStr,
-			}
-			sendClientMessage(client, estimateMessage)
-			log.Printf("[JOIN] Sent estimate message to %s", username)
-
-			log.Printf("[JOIN] Broadcasting participant count")
-			broadcastParticipCount(client)
-			log.Printf("[JOIN] Broadcasting vote status")
-			broadcastVoteStatus(client)
-			// Send queue sync to newly joined client
-			log.Printf("[JOIN] Broadcasting queue sync")
-			broadcastQueueSync()
-			log.Printf("[JOIN] Complete - all post-join messages sent to %s", username)
-		case types.NewIssue:
-			// If we have Linear issues queued, use next from queue
-			if linearClient != nil && currentIssueIndex >= 0 && currentIssueIndex < len(linearIssues) {
-				issue := linearIssues[currentIssueIndex]
-				currentLinearIssue = &issue
-				currentIssue = fmt.Sprintf("%s: %s", issue.Identifier, issue.Title)
-				log.Printf("Loaded Linear issue: %s", currentIssue)
-			} else {
-				// Manual issue entry
-				currentIssue = messageObject.Payload
-				currentLinearIssue = nil
-			}
-			message := types.Message{
-				Type:    types.CurrentIssue,
-				Payload: currentIssue,
-			}
-			byteMessage := messaging.MarshallMessage(message)
-			broadcast(byteMessage, client)
-		case types.Estimate:
-			numEstimate, err := strconv.ParseInt(messageObject.Payload, 10, 32)
-			if err != nil {
-				log.Println("Error parsing estimate:", err)
-				break
-			}
-			client.CurrentEstimate = numEstimate
-			broadcastVoteStatus(client)
-		case types.Reveal:
-			pointAvg := getPointAverage()
-			pointAvgStr := strconv.FormatInt(pointAvg, 10)
-			message := types.RevealMessage{
-				Type: types.RevealData,
-				Payload: types.RevealPayload{
-					PointAvg:  pointAvgStr,
-					Estimates: getFormattedRevealData(),
-				},
-			}
-			byteMessage := messaging.MarshallMessage(message)
-			broadcast(byteMessage, client)
-		case types.Reset:
-			// Push voting results to Linear if applicable
-			if currentLinearIssue != nil && linearClient != nil {
-				pushVotingResultsToLinear(client)
-			}
-			handleReset(client)
-			currentIssue = ""
-			currentLinearIssue = nil
-
-			// Prepare next Linear issue suggestion (don't increment index yet)
-			if linearClient != nil && len(linearIssues) > 0 {
-				nextIndex := currentIssueIndex + 1
-				if nextIndex < len(linearIssues) {
-					pendingQueueIndex = nextIndex
-					log.Printf("Next Linear issue available: %s", linearIssues[nextIndex].Identifier)
-					// Send suggestion to all hosts
-					suggestIssueToHosts()
-				} else {
-					log.Println("All Linear issues have been estimated")
-					pendingQueueIndex = -1
-					// Send "no more issues" message to hosts
-					suggestNoMoreIssuesToHosts()
-				}
-			}
+	case types.MessageQueueDelete:
+		var payload types.QueueDeletePayload
+		if err := decodePayload(envelope, &payload); err != nil {
+			return err
+		}
+		return r.handleQueueDelete(payload, client)
 
-		case types.MessageIssueConfirm:
-			var confirmMsg types.IssueConfirmMessage
-			if err := json.Unmarshal(message, &confirmMsg); err != nil {
-				log.Println("Error parsing issue confirm:", err)
-				break
-			}
-			handleIssueConfirm(confirmMsg.Payload, client)
+	case types.MessageQueueReorder:
+		var payload types.QueueReorderPayload
+		if err := decodePayload(envelope, &payload); err != nil {
+			return err
+		}
+		r.handleQueueReorder(payload, client)
 
-		case types.MessageQueueAdd:
-			var addMsg types.QueueAddMessage
-			if err := json.Unmarshal(message, &addMsg); err != nil {
-				log.Println("Error parsing queue add:", err)
-				break
-			}
-			handleQueueAdd(addMsg.Payload, client)
+	case types.MessageAssignEstimate:
+		return r.handleAssignEstimate(client)
 
-		case types.MessageQueueUpdate:
-			var updateMsg types.QueueUpdateMessage
-			if err := json.Unmarshal(message, &updateMsg); err != nil {
-				log.Println("Error parsing queue update:", err)
-				break
-			}
-			handleQueueUpdate(updateMsg.Payload, client)
+	case types.StartTimer:
+		var payload types.StartTimerPayload
+		if err := decodePayload(envelope, &payload); err != nil {
+			return err
+		}
+		return r.handleStartTimer(payload, client)
 
-		case types.MessageQueueDelete:
-			var deleteMsg types.QueueDeleteMessage
-			if err := json.Unmarshal(message, &deleteMsg); err != nil {
-				log.Println("Error parsing queue delete:", err)
-				break
-			}
-			handleQueueDelete(deleteMsg.Payload, client)
+	case types.StopTimer:
+		r.cancelTimer(client)
 
-		case types.MessageQueueReorder:
-			var reorderMsg types.QueueReorderMessage
-			if err := json.Unmarshal(message, &reorderMsg); err != nil {
-				log.Println("Error parsing queue reorder:", err)
-				break
-			}
-			handleQueueReorder(reorderMsg.Payload, client)
+	case types.SetAutoReveal:
+		var payload types.AutoRevealPayload
+		if err := decodePayload(envelope, &payload); err != nil {
+			return err
+		}
+		r.handleSetAutoReveal(payload, client)
+
+	case types.TransferHost:
+		var payload types.TransferHostPayload
+		if err := decodePayload(envelope, &payload); err != nil {
+			return err
+		}
+		return r.handleTransferHost(payload, client)
+
+	case types.SetCoHost:
+		var payload types.CoHostPayload
+		if err := decodePayload(envelope, &payload); err != nil {
+			return err
+		}
+		return r.handleSetCoHost(payload, client)
+
+	case types.Kick:
+		var payload types.KickPayload
+		if err := decodePayload(envelope, &payload); err != nil {
+			return err
+		}
+		return r.handleKick(payload, client)
 
-		case types.MessageAssignEstimate:
-			handleAssignEstimate(client)
+	case types.Rename:
+		var payload types.RenamePayload
+		if err := decodePayload(envelope, &payload); err != nil {
+			return err
+		}
+		return r.handleRename(payload, client)
 
-		case types.Leave:
-			broadcastParticipCount(client)
-		default:
-			log.Println("Unknown message type:", messageObject.Type)
+	case types.ClearVote:
+		var payload types.ClearVotePayload
+		if err := decodePayload(envelope, &payload); err != nil {
+			return err
 		}
+		return r.handleClearVote(payload, client)
+
+	case types.Leave:
+		r.broadcastParticipCount(client)
 	}
+	return nil
+}
+
+// resetRound clears the board for the next issue: the results go to
+// Linear, the votes and the current issue are cleared, and hosts are
+// offered the next Linear issue
+func (r *Room) resetRound(client *Client) {
+	// Push voting results to Linear if applicable
+	if r.currentLinearIssue != nil && r.linearClient != nil {
+		r.pushVotingResultsToLinear(client)
+	}
+	r.cancelTimer(client)
+	r.handleReset(client)
+	r.mutex.Lock()
+	r.currentIssue = ""
+	r.currentLinearIssue = nil
+	r.mutex.Unlock()
+	r.closeRound(client)
+
+	// Prepare next Linear issue suggestion (don't increment index yet)
+	if r.linearClient != nil && len(r.linearIssues) > 0 {
+		nextIndex := r.currentIssueIndex + 1
+		if nextIndex < len(r.linearIssues) {
+			r.pendingQueueIndex = nextIndex
+			r.log.Info("Next Linear issue available", "issue", r.linearIssues[nextIndex].Identifier)
+			// Send suggestion to all hosts
+			r.suggestIssueToHosts()
+		} else {
+			r.log.Info("All Linear issues have been estimated")
+			r.pendingQueueIndex = -1
+			// Send "no more issues" message to hosts
+			r.suggestNoMoreIssuesToHosts()
+		}
+	}
+}
+
+// sendWelcome sends a newly joined or resumed client its resume token and
+// the current session state, and tells everyone about the new participant
+func (r *Room) sendWelcome(client *Client) {
+	// send cur issue (with linearIssue if applicable)
+	r.mutex.Lock()
+	currentIssuePayload := types.CurrentIssuePayload{
+		Text:        r.currentIssue,
+		LinearIssue: r.currentLinearIssue,
+	}
+	r.mutex.Unlock()
+	sendCurrentIssue(client, currentIssuePayload)
+
+	pointAvgStr := r.getPointAverageLabel()
+	estimateMessage := types.Message{
+		Type:    types.CurrentEstimate,
+		Payload: pointAvgStr,
+	}
+	sendClientMessage(client, estimateMessage)
+
+	r.sendDeck(client)
+	r.sendRoundState(client)
+	r.sendResumeToken(client)
+
+	r.broadcastParticipCount(client)
+	r.broadcastVoteStatus(client)
+	// Send queue sync to newly joined client
+	r.broadcastQueueSync()
 }
 
 // validateUsername checks if a username meets requirements
@@ -486,11 +561,11 @@ func validateUsername(username string) (string, error) {
 }
 
 // isUsernameTaken checks if a username is already in use (case-insensitive)
-func isUsernameTaken(username string) bool {
-	mutex.Lock()
-	defer mutex.Unlock()
+func (r *Room) isUsernameTaken(username string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 
-	for client := range clients {
+	for client := range r.clients {
 		if client.UserID != "" && strings.EqualFold(client.UserID, username) {
 			return true
 		}
@@ -499,8 +574,8 @@ func isUsernameTaken(username string) bool {
 }
 
 // isUsernameTakenUnlocked checks if username exists (caller must hold mutex)
-func isUsernameTakenUnlocked(username string) bool {
-	for client := range clients {
+func (r *Room) isUsernameTakenUnlocked(username string) bool {
+	for client := range r.clients {
 		if client.UserID != "" && strings.EqualFold(client.UserID, username) {
 			return true
 		}
@@ -509,11 +584,11 @@ func isUsernameTakenUnlocked(username string) bool {
 }
 
 // hasHost checks if there's already a host in the session
-func hasHost() bool {
-	mutex.Lock()
-	defer mutex.Unlock()
+func (r *Room) hasHost() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 
-	for client := range clients {
+	for client := range r.clients {
 		if client.IsHost {
 			return true
 		}
@@ -522,8 +597,8 @@ func hasHost() bool {
 }
 
 // hasHostUnlocked checks if host exists (caller must hold mutex)
-func hasHostUnlocked() bool {
-	for client := range clients {
+func (r *Room) hasHostUnlocked() bool {
+	for client := range r.clients {
 		if client.IsHost {
 			return true
 		}
@@ -531,252 +606,318 @@ func hasHostUnlocked() bool {
 	return false
 }
 
-func handleJoin(username string, sender *Client, isHost bool) bool {
-	log.Printf("[handleJoin] START - username: %s, isHost: %v, client: %p", username, isHost, sender)
+// handleJoin adds a participant to the session. Failed joins disconnect
+// the client once it has been told why. A host resuming with a current
+// token is resumed and may join next to other hosts, as a co-host would.
+func (r *Room) handleJoin(username string, sender *Client, role Role, resumed bool) error {
+	isHost := role == RoleHost
 
 	// Step 1: Validate username (safe outside mutex - no shared state access)
 	validUsername, err := validateUsername(username)
 	if err != nil {
-		log.Printf("[handleJoin] VALIDATION FAILED - %v", err)
-		errorMsg := types.Message{
-			Type:    types.JoinError,
-			Payload: err.Error(),
-		}
-		byteMessage := messaging.MarshallMessage(errorMsg)
-		sender.WriteMessage(websocket.TextMessage, byteMessage)
-		sender.Conn.Close()
-
-		// Remove from clients map
-		mutex.Lock()
-		delete(clients, sender)
-		mutex.Unlock()
-		return false
+		return &CommandError{Code: types.CodeInvalidUsername, Legacy: types.JoinError, Err: err, Disconnect: true}
 	}
 
 	// Step 2: Atomic check + set (critical section)
-	mutex.Lock()
+	r.mutex.Lock()
 
-	// Check duplicate username
-	if isUsernameTakenUnlocked(validUsername) {
-		mutex.Unlock()
-		log.Printf("[handleJoin] DUPLICATE USERNAME - %s", validUsername)
-		errorMsg := types.Message{
-			Type:    types.JoinError,
-			Payload: "username is already taken, please choose another",
-		}
-		byteMessage := messaging.MarshallMessage(errorMsg)
-		sender.WriteMessage(websocket.TextMessage, byteMessage)
-		sender.Conn.Close()
-
-		// Remove from clients map (safe without mutex - we just released it and client isn't fully joined)
-		mutex.Lock()
-		delete(clients, sender)
-		mutex.Unlock()
-		return false
+	switch {
+	case r.isUsernameTakenUnlocked(validUsername):
+		err = errNameTaken
+	case r.isBannedUnlocked(validUsername):
+		err = errBanned
+	case isHost && !resumed && r.hasHostUnlocked():
+		err = errHostTaken
 	}
-
-	// Check multiple host
-	if isHost && hasHostUnlocked() {
-		mutex.Unlock()
-		log.Printf("[handleJoin] MULTIPLE HOST - %s tried to join", validUsername)
-		errorMsg := types.Message{
-			Type:    types.JoinError,
-			Payload: "a host is already in this session, please join as a player",
-		}
-		byteMessage := messaging.MarshallMessage(errorMsg)
-		sender.WriteMessage(websocket.TextMessage, byteMessage)
-		sender.Conn.Close()
-
-		mutex.Lock()
-		delete(clients, sender)
-		mutex.Unlock()
-		return false
+	if err != nil {
+		r.mutex.Unlock()
+		joinErr := newCommandError(types.JoinError, err)
+		joinErr.Disconnect = true
+		return joinErr
 	}
 
 	// ATOMICALLY set client fields (still holding mutex)
 	sender.UserID = validUsername
-	sender.CurrentEstimate = 0
+	sender.CurrentEstimate = r.takeRestoredVoteUnlocked(validUsername)
+	r.dropSlotUnlocked(validUsername)
 	sender.IsHost = isHost
+	sender.IsObserver = role == RoleObserver
+	sender.JoinedAt = time.Now()
+	if isHost {
+		r.stopHostRecoveryUnlocked()
+	}
+	if sender.IsObserver {
+		// Observers never vote, not even with a vote restored from a snapshot
+		sender.CurrentEstimate = Estimate{}
+	}
 
-	mutex.Unlock()
+	r.mutex.Unlock()
 	// End of critical section
 
-	log.Println("User joined:", validUsername)
+	r.log.Info("User joined", "user", validUsername, "role", role)
 
 	// Auto-load first issue if in Linear mode, host joins, no current issue, and queue has Linear items
-	if isHost && linearClient != nil && currentIssue == "" && len(queueItems) > 0 {
+	if isHost && r.linearClient != nil && r.currentIssue == "" && len(r.queueItems) > 0 {
 		// Check if first item is a Linear issue
-		firstItem := queueItems[0]
+		firstItem := r.queueItems[0]
 		if firstItem.Source == "linear" && firstItem.LinearID != "" {
 			// Find the Linear issue by ID
 			var linearIssue *types.LinearIssue
 			var issueIndex int = -1
-			for i := range linearIssues {
-				if linearIssues[i].ID == firstItem.LinearID {
-					linearIssue = &linearIssues[i]
+			for i := range r.linearIssues {
+				if r.linearIssues[i].ID == firstItem.LinearID {
+					linearIssue = &r.linearIssues[i]
 					issueIndex = i
 					break
 				}
 			}
 
 			if linearIssue != nil {
-				currentLinearIssue = linearIssue
-				currentIssueIndex = issueIndex
-				currentIssue = fmt.Sprintf("%s: %s", linearIssue.Identifier, linearIssue.Title)
-				pendingQueueIndex = -1
+				r.currentLinearIssue = linearIssue
+				r.currentIssueIndex = issueIndex
+				r.currentIssue = fmt.Sprintf("%s: %s", linearIssue.Identifier, linearIssue.Title)
+				r.pendingQueueIndex = -1
 
 				// Broadcast issue loaded to all clients
 				loadedMsg := types.IssueLoadedMessage{
 					Type: types.MessageIssueLoaded,
 					Payload: types.IssueLoadedPayload{
 						Identifier: linearIssue.Identifier,
-						Title:      currentIssue,
+						Title:      r.currentIssue,
 						QueueIndex: 0,
 					},
 				}
 				byteMessage := messaging.MarshallMessage(loadedMsg)
-				broadcast(byteMessage, sender)
+				r.broadcast(byteMessage, sender)
 
 				// Send as CurrentIssue for backward compatibility
-				var currentIssuePayload types.CurrentIssuePayload
-				currentIssuePayload.Text = currentIssue
-				currentIssuePayload.LinearIssue = currentLinearIssue
-
-				currentIssuePayloadJSON, err := json.Marshal(currentIssuePayload)
-				if err == nil {
-					currentIssueMsg := types.Message{
-						Type:    types.CurrentIssue,
-						Payload: string(currentIssuePayloadJSON),
-					}
-					byteMessageCompat := messaging.MarshallMessage(currentIssueMsg)
-					broadcast(byteMessageCompat, sender)
-				}
+				r.broadcastCurrentIssue(types.CurrentIssuePayload{
+					Text:        r.currentIssue,
+					LinearIssue: r.currentLinearIssue,
+				}, sender)
+
+				// A new issue gets a fresh countdown
+				r.cancelTimer(sender)
+				r.startVoting(sender)
 
 				// Remove first issue from queue
-				removeQueueItem(firstItem.Identifier, false)
-				broadcastQueueSync()
+				r.removeQueueItem(firstItem.Identifier, false)
+				r.broadcastQueueSync()
 
-				log.Printf("Auto-loaded first Linear issue: %s", linearIssue.Identifier)
-				return true
+				r.log.Info("Auto-loaded first Linear issue", "issue", linearIssue.Identifier)
+				return nil
 			}
 		}
 	}
 
 	// If host joins and there's a pending issue, send suggestion
-	if isHost && linearClient != nil && pendingQueueIndex >= 0 && pendingQueueIndex < len(linearIssues) {
-		suggestIssueToHost(sender)
+	if isHost && r.linearClient != nil && r.pendingQueueIndex >= 0 && r.pendingQueueIndex < len(r.linearIssues) {
+		r.suggestIssueToHost(sender)
 	}
 
-	return true
+	return nil
 }
 
-func getFormattedRevealData() []types.UserEstimate {
+func (r *Room) getFormattedRevealData() []types.UserEstimate {
 	estimates := make([]types.UserEstimate, 0)
 
-	mutex.Lock()
-	for client := range clients {
+	r.mutex.Lock()
+	for client := range r.clients {
+		if client.IsObserver {
+			continue
+		}
 		estimates = append(estimates, types.UserEstimate{
 			User:     client.UserID,
-			Estimate: strconv.FormatInt(client.CurrentEstimate, 10),
+			Estimate: client.CurrentEstimate.Label(),
+			Special:  client.CurrentEstimate.Kind == SpecialEstimate,
 		})
 	}
-	mutex.Unlock()
+	r.mutex.Unlock()
 
 	return estimates
 }
 
-func getPointAverage() int64 {
-	possibleEstimates := []int64{1, 2, 3, 5, 8, 13}
-
-	mutex.Lock()
-	var total int64
-	didntEstimate := 0
-	for client := range clients {
-		est := client.CurrentEstimate
-		total += est
-		if est == 0 {
-			didntEstimate++
+// getPointAverage averages the value of all numeric votes and snaps the
+// result to the nearest card in the room's deck. Special cards are left out.
+// It reports false if nobody voted with a numeric card.
+func (r *Room) getPointAverage() (types.DeckCard, bool) {
+	r.mutex.Lock()
+	var total float64
+	voted := 0
+	for client := range r.clients {
+		// Observers never vote, so they can't drag the average down
+		if client.IsObserver || client.CurrentEstimate.Kind != NumericEstimate {
+			continue
 		}
+		total += client.CurrentEstimate.Value
+		voted++
 	}
-	clientsLessAbsentia := len(clients) - didntEstimate
-	mutex.Unlock()
+	r.mutex.Unlock()
 
-	log.Println("Estimate average request")
-	if clientsLessAbsentia == 0 {
-		return 0
+	if voted == 0 {
+		return types.DeckCard{}, false
 	}
-	rawAvg := total / int64(clientsLessAbsentia)
-	diffs := make([]int64, 0)
+	return r.deck.nearest(total / float64(voted)), true
+}
 
-	for i := range possibleEstimates {
-		if possibleEstimates[i] == rawAvg {
-			return possibleEstimates[i]
+// getPointAverageLabel returns the label of the average card, as shown to clients
+func (r *Room) getPointAverageLabel() string {
+	average, ok := r.getPointAverage()
+	if !ok {
+		return noEstimate
+	}
+	return average.Label
+}
+
+// getRevealStats summarizes the numeric votes for the reveal. It returns
+// nil if nobody voted with a numeric card.
+func (r *Room) getRevealStats() *types.RevealStats {
+	type vote struct {
+		user     string
+		estimate Estimate
+	}
+
+	r.mutex.Lock()
+	votes := make([]vote, 0, len(r.clients))
+	for client := range r.clients {
+		if !client.IsObserver && client.CurrentEstimate.Kind == NumericEstimate {
+			votes = append(votes, vote{user: client.UserID, estimate: client.CurrentEstimate})
+		}
+	}
+	roomDeck := r.deck
+	r.mutex.Unlock()
+
+	if len(votes) == 0 {
+		return nil
+	}
+	sort.Slice(votes, func(i, j int) bool {
+		if votes[i].estimate.Value != votes[j].estimate.Value {
+			return votes[i].estimate.Value < votes[j].estimate.Value
+		}
+		return votes[i].user < votes[j].user
+	})
+
+	lowest := votes[0].estimate
+	highest := votes[len(votes)-1].estimate
+	stats := &types.RevealStats{
+		Votes:         len(votes),
+		Min:           lowest.Card,
+		Max:           highest.Card,
+		Consensus:     roomDeck.index(highest.Card)-roomDeck.index(lowest.Card) <= 1,
+		HighestVoters: make([]string, 0),
+		LowestVoters:  make([]string, 0),
+	}
+
+	var total float64
+	counts := make(map[string]int)
+	for _, v := range votes {
+		total += v.estimate.Value
+		counts[v.estimate.Card]++
+		if lowest.Card != highest.Card {
+			switch v.estimate.Card {
+			case lowest.Card:
+				stats.LowestVoters = append(stats.LowestVoters, v.user)
+			case highest.Card:
+				stats.HighestVoters = append(stats.HighestVoters, v.user)
+			}
 		}
+	}
+	mean := total / float64(len(votes))
+	stats.Mean = roundStat(mean)
 
-		rawDiff := possibleEstimates[i] - rawAvg
-		absDiff := int64(math.Abs(float64(rawDiff)))
-		diffs = append(diffs, absDiff)
+	middle := len(votes) / 2
+	if len(votes)%2 == 1 {
+		stats.Median = votes[middle].estimate.Value
+	} else {
+		stats.Median = roundStat((votes[middle-1].estimate.Value + votes[middle].estimate.Value) / 2)
 	}
 
-	lowestNum := diffs[0]
-	lowestIdx := 0
-	for i := range diffs {
-		if diffs[i] < lowestNum {
-			lowestNum = diffs[i]
-			lowestIdx = i
+	var variance float64
+	for _, v := range votes {
+		variance += (v.estimate.Value - mean) * (v.estimate.Value - mean)
+	}
+	stats.StdDev = roundStat(math.Sqrt(variance / float64(len(votes))))
+
+	// Modes are listed in deck order
+	maxCount := 0
+	for _, count := range counts {
+		maxCount = max(maxCount, count)
+	}
+	for _, card := range roomDeck.Cards {
+		if counts[card.Label] == maxCount {
+			stats.Mode = append(stats.Mode, card.Label)
 		}
 	}
 
-	return possibleEstimates[lowestIdx]
+	return stats
 }
 
-func handleReset(client *Client) {
+// roundStat rounds a statistic to two decimals for display
+func roundStat(value float64) float64 {
+	return math.Round(value*100) / 100
+}
 
-	for client := range clients {
-		client.CurrentEstimate = 0
+func (r *Room) handleReset(client *Client) {
+	r.mutex.Lock()
+	for client := range r.clients {
+		client.CurrentEstimate = Estimate{}
 	}
+	r.restoredVotes = make(map[string]Estimate)
+	r.mutex.Unlock()
 
 	clearMessage := types.Message{
 		Type:    types.ClearBoard,
 		Payload: "",
 	}
 	byteMessage := messaging.MarshallMessage(clearMessage)
-	broadcast(byteMessage, client)
+	r.broadcast(byteMessage, client)
 
-	broadcastVoteStatus(client)
+	r.broadcastVoteStatus(client)
 
-	log.Println("Estimates reset.")
+	r.log.Info("Estimates reset")
+}
+
+// participantCountUnlocked counts the connections that take part in voting,
+// leaving out observers (caller must hold mutex)
+func (r *Room) participantCountUnlocked() int {
+	count := 0
+	for client := range r.clients {
+		if !client.IsObserver {
+			count++
+		}
+	}
+	return count
 }
 
-func broadcastParticipCount(client *Client) {
-	mutex.Lock()
-	numberOfParticipants := len(clients)
-	mutex.Unlock()
+func (r *Room) broadcastParticipCount(client *Client) {
+	r.mutex.Lock()
+	numberOfParticipants := r.participantCountUnlocked()
+	r.mutex.Unlock()
 
-	log.Println("Participants: ", numberOfParticipants)
+	r.log.Debug("Participant count", "participants", numberOfParticipants)
 	pcMessage := types.Message{
 		Type:    types.ParticipantCount,
 		Payload: strconv.Itoa(numberOfParticipants),
 	}
 	byteMessage := messaging.MarshallMessage(pcMessage)
-	broadcast(byteMessage, client)
+	r.broadcast(byteMessage, client)
 }
 
-func broadcastVoteStatus(client *Client) {
+func (r *Room) broadcastVoteStatus(client *Client) {
 	voters := make([]types.VoterInfo, 0)
 
-	mutex.Lock()
-	for c := range clients {
-		if c.UserID != "" {
-			hasVoted := c.CurrentEstimate > 0
-			log.Printf("Vote status for %s: estimate=%d, hasVoted=%v", c.UserID, c.CurrentEstimate, hasVoted)
+	r.mutex.Lock()
+	for c := range r.clients {
+		if c.isVoter() {
+			hasVoted := c.CurrentEstimate.Voted()
 			voters = append(voters, types.VoterInfo{
 				Username: c.UserID,
 				HasVoted: hasVoted,
+				IsHost:   c.IsHost,
 			})
 		}
 	}
-	mutex.Unlock()
+	r.mutex.Unlock()
 
 	voteStatusMsg := types.VoteStatusMessage{
 		Type: types.VoteStatus,
@@ -786,8 +927,10 @@ func broadcastVoteStatus(client *Client) {
 	}
 
 	byteMessage := messaging.MarshallMessage(voteStatusMsg)
-	broadcast(byteMessage, client)
-	log.Printf("Broadcasted vote status: %d voters, %d have voted", len(voters), countVoted(voters))
+	r.broadcast(byteMessage, client)
+	r.log.Debug("Broadcast vote status", "voters", len(voters), "voted", countVoted(voters))
+
+	r.checkAutoReveal()
 }
 
 func countVoted(voters []types.VoterInfo) int {
@@ -803,88 +946,98 @@ func countVoted(voters []types.VoterInfo) int {
 func sendClientMessage(client *Client, message types.Message) {
 	byteMessage := messaging.MarshallMessage(message)
 	if err := client.WriteMessage(websocket.TextMessage, byteMessage); err != nil {
-		log.Printf("Error writing message to client %s: %v", client.UserID, err)
+		client.logger().Warn("Error writing message", "user", client.UserID, "err", err)
 		// Don't crash the server, just log the error
 		// The client will be cleaned up by the read loop when it detects the broken connection
 	}
 }
 
-// broadcast sends a message to all clients except the sender.
-func broadcast(message []byte, sender *Client) {
+// broadcast sends a message to all clients. Messages are queued per client,
+// so a slow client never holds up the others.
+func (r *Room) broadcast(message []byte, sender *Client) {
+	// Payloads carry issue titles and descriptions, so they are only
+	// logged at debug level
+	if r.log.Enabled(context.Background(), slog.LevelDebug) {
+		envelope, _ := decodeEnvelope(message)
+		r.log.Debug("Broadcasting message", "type", envelope.Type, "payload", string(message))
+	}
+	r.broadcastEach(func(*Client) []byte { return message }, sender)
+}
+
+// broadcastEach sends every client the message encode returns for it, so
+// clients can get a message in the format they speak
+func (r *Room) broadcastEach(encode func(*Client) []byte, sender *Client) {
 	// Step 1: Copy client list and UserIDs while holding the lock (don't do network I/O under lock)
 	type clientInfo struct {
 		client *Client
 		userID string
 	}
-	mutex.Lock()
-	clientList := make([]clientInfo, 0, len(clients))
-	for client := range clients {
+	r.mutex.Lock()
+	clientList := make([]clientInfo, 0, len(r.clients))
+	for client := range r.clients {
 		clientList = append(clientList, clientInfo{
 			client: client,
 			userID: client.UserID,
 		})
 	}
-	mutex.Unlock()
-
-	log.Println("Broadcasting message: ", string(message))
+	r.mutex.Unlock()
 
 	// Step 2: Send to all clients without holding the lock
-	var deadClients []*Client
+	start := time.Now()
+	var deadClients []clientInfo
 	for _, info := range clientList {
-		log.Println("broadcast to client: ", info.userID)
-		if err := info.client.WriteMessage(websocket.TextMessage, message); err != nil {
-			log.Printf("Error writing message to client %s: %v (marking for cleanup)", info.userID, err)
-			deadClients = append(deadClients, info.client)
+		if err := info.client.WriteMessage(websocket.TextMessage, encode(info.client)); err != nil {
+			r.log.Warn("Error writing message, marking client for cleanup", "user", info.userID, "err", err)
+			deadClients = append(deadClients, info)
 		}
 	}
+	r.server.metrics.broadcastDuration.observe(time.Since(start))
 
 	// Step 3: Clean up dead connections
 	if len(deadClients) > 0 {
-		mutex.Lock()
-		for _, client := range deadClients {
-			if _, exists := clients[client]; exists {
-				delete(clients, client)
-				client.Conn.Close()
-				log.Printf("Removed dead client: %s", client.UserID)
+		for _, info := range deadClients {
+			if leaveRoom(info.client) {
+				info.client.Conn.Close()
+				r.log.Info("Removed dead client", "user", info.userID)
 			}
 		}
-		participantCount := len(clients)
-		mutex.Unlock()
+		r.mutex.Lock()
+		participantCount := len(r.clients)
+		r.mutex.Unlock()
 
 		// Notify remaining clients about updated participant count
-		log.Printf("Cleaned up %d dead clients, %d remaining", len(deadClients), participantCount)
-		go broadcastParticipCount(nil)
+		r.log.Info("Cleaned up dead clients", "dead", len(deadClients), "remaining", participantCount)
+		go r.do(func() { r.broadcastParticipCount(nil) })
 	}
 }
 
 // pushVotingResultsToLinear posts voting results as a comment to the Linear issue
-func pushVotingResultsToLinear(sender *Client) {
-	if currentLinearIssue == nil || linearClient == nil {
+func (r *Room) pushVotingResultsToLinear(sender *Client) {
+	if r.currentLinearIssue == nil || r.linearClient == nil {
 		return
 	}
 
 	// Get voting breakdown
-	estimates := getFormattedRevealData()
-	pointAvg := getPointAverage()
-
-	// Format comment
-	var comment strings.Builder
-	comment.WriteString("## Planning Poker Results\n\n")
-
-	for _, est := range estimates {
-		if est.Estimate != "0" {
-			comment.WriteString(fmt.Sprintf("- %s: %s\n", est.User, est.Estimate))
+	r.mutex.Lock()
+	estimates := make([]types.UserEstimate, 0, len(r.clients))
+	for client := range r.clients {
+		if client.CurrentEstimate.Voted() {
+			estimates = append(estimates, types.UserEstimate{
+				User:     client.UserID,
+				Estimate: client.CurrentEstimate.Card,
+				Special:  client.CurrentEstimate.Kind == SpecialEstimate,
+			})
 		}
 	}
-
-	comment.WriteString(fmt.Sprintf("\n**Average:** %d points", pointAvg))
+	r.mutex.Unlock()
+	comment := formatResultsComment(estimates, r.getPointAverageLabel(), r.getRevealStats())
 
 	// Post to Linear
-	err := linearClient.PostComment(currentLinearIssue.ID, comment.String())
+	err := r.linearClient.PostComment(r.currentLinearIssue.ID, comment)
 	if err != nil {
-		log.Printf("Failed to post comment to Linear issue %s: %v", currentLinearIssue.Identifier, err)
+		r.log.Error("Failed to post voting results to Linear", "issue", r.currentLinearIssue.Identifier, "err", err)
 	} else {
-		log.Printf("Posted voting results to Linear issue %s", currentLinearIssue.Identifier)
+		r.log.Info("Posted voting results to Linear", "issue", r.currentLinearIssue.Identifier)
 	}
 }
 
@@ -896,13 +1049,57 @@ func truncateDescription(text string, maxLen int) (string, bool) {
 	return text[:maxLen] + "...", true
 }
 
+// formatResultsComment renders the voting results as the Markdown comment
+// posted to Linear
+func formatResultsComment(estimates []types.UserEstimate, pointAvg string, stats *types.RevealStats) string {
+	var comment strings.Builder
+	comment.WriteString("## Planning Poker Results\n\n")
+
+	for _, est := range estimates {
+		comment.WriteString(fmt.Sprintf("- %s: %s\n", est.User, est.Estimate))
+	}
+
+	if pointAvg == noEstimate {
+		comment.WriteString("\n**Average:** no numeric votes")
+	} else {
+		comment.WriteString(fmt.Sprintf("\n**Average:** %s points", pointAvg))
+	}
+
+	if stats != nil {
+		consensus := "no"
+		if stats.Consensus {
+			consensus = "yes"
+		}
+		comment.WriteString("\n")
+		comment.WriteString(fmt.Sprintf("\n- **Median:** %s", strconv.FormatFloat(stats.Median, 'f', -1, 64)))
+		comment.WriteString(fmt.Sprintf("\n- **Mode:** %s", strings.Join(stats.Mode, ", ")))
+		comment.WriteString(fmt.Sprintf("\n- **Range:** %s–%s", stats.Min, stats.Max))
+		comment.WriteString(fmt.Sprintf("\n- **Std dev:** %s", strconv.FormatFloat(stats.StdDev, 'f', -1, 64)))
+		comment.WriteString(fmt.Sprintf("\n- **Consensus:** %s", consensus))
+		if len(stats.HighestVoters) > 0 {
+			comment.WriteString(fmt.Sprintf("\n- **Highest (%s):** %s", stats.Max, strings.Join(stats.HighestVoters, ", ")))
+			comment.WriteString(fmt.Sprintf("\n- **Lowest (%s):** %s", stats.Min, strings.Join(stats.LowestVoters, ", ")))
+		}
+	}
+
+	// Special cards are not part of the average, so call them out
+	if specials := specialSummaries(estimates); len(specials) > 0 {
+		comment.WriteString("\n")
+		for _, special := range specials {
+			comment.WriteString(fmt.Sprintf("\n- %s %s", special.Card, special.Summary))
+		}
+	}
+
+	return comment.String()
+}
+
 // suggestIssueToHost sends an issue suggestion to a specific host client
-func suggestIssueToHost(host *Client) {
-	if pendingQueueIndex < 0 || pendingQueueIndex >= len(linearIssues) {
+func (r *Room) suggestIssueToHost(host *Client) {
+	if r.pendingQueueIndex < 0 || r.pendingQueueIndex >= len(r.linearIssues) {
 		return
 	}
 
-	issue := linearIssues[pendingQueueIndex]
+	issue := r.linearIssues[r.pendingQueueIndex]
 	description, hasMore := truncateDescription(issue.Description, 3000)
 
 	suggestion := types.IssueSuggestedMessage{
@@ -914,33 +1111,33 @@ func suggestIssueToHost(host *Client) {
 			Title:       issue.Title,
 			Description: description,
 			URL:         issue.URL,
-			QueueIndex:  pendingQueueIndex,
+			QueueIndex:  r.pendingQueueIndex,
 			HasMore:     hasMore,
 		},
 	}
 
 	byteMessage := messaging.MarshallMessage(suggestion)
 	if err := host.WriteMessage(websocket.TextMessage, byteMessage); err != nil {
-		log.Printf("Error sending issue suggestion to host %s: %v", host.UserID, err)
+		r.log.Warn("Error sending issue suggestion", "user", host.UserID, "err", err)
 	} else {
-		log.Printf("Sent issue suggestion to host: %s", host.UserID)
+		r.log.Debug("Sent issue suggestion", "user", host.UserID, "issue", issue.Identifier)
 	}
 }
 
 // suggestIssueToHosts sends the current pending issue suggestion to all hosts
-func suggestIssueToHosts() {
-	mutex.Lock()
-	defer mutex.Unlock()
+func (r *Room) suggestIssueToHosts() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 
-	for client := range clients {
+	for client := range r.clients {
 		if client.IsHost {
-			suggestIssueToHost(client)
+			r.suggestIssueToHost(client)
 		}
 	}
 }
 
 // suggestNoMoreIssuesToHosts informs hosts that there are no more Linear issues
-func suggestNoMoreIssuesToHosts() {
+func (r *Room) suggestNoMoreIssuesToHosts() {
 	suggestion := types.IssueSuggestedMessage{
 		Type: types.MessageIssueSuggested,
 		Payload: types.IssueSuggestedPayload{
@@ -955,35 +1152,36 @@ func suggestNoMoreIssuesToHosts() {
 	byteMessage := messaging.MarshallMessage(suggestion)
 
 	// Copy host list while holding mutex
-	mutex.Lock()
+	r.mutex.Lock()
 	hostList := make([]*Client, 0)
-	for client := range clients {
+	for client := range r.clients {
 		if client.IsHost {
 			hostList = append(hostList, client)
 		}
 	}
-	mutex.Unlock()
+	r.mutex.Unlock()
 
 	// Send to hosts without holding mutex
 	for _, client := range hostList {
 		if err := client.WriteMessage(websocket.TextMessage, byteMessage); err != nil {
 			// Access UserID safely for logging (brief lock)
-			mutex.Lock()
+			r.mutex.Lock()
 			userID := client.UserID
-			mutex.Unlock()
-			log.Printf("Error sending 'no more issues' to host %s: %v", userID, err)
+			r.mutex.Unlock()
+			r.log.Warn("Error sending 'no more issues'", "user", userID, "err", err)
 		}
 	}
 }
 
 // handleIssueConfirm validates and processes an issue confirmation from the host
-func handleIssueConfirm(payload types.IssueConfirmPayload, sender *Client) {
-	log.Printf("📥 Received issue confirm: identifier=%s, queueIndex=%d, isCustom=%v, requestID=%s", payload.Identifier, payload.QueueIndex, payload.IsCustom, payload.RequestID)
+func (r *Room) handleIssueConfirm(payload types.IssueConfirmPayload, sender *Client) error {
+	r.log.Debug("Received issue confirm", "user", sender.UserID, "issue", payload.Identifier,
+		"queueIndex", payload.QueueIndex, "custom", payload.IsCustom, "requestId", payload.RequestID)
 
 	// Check if already confirmed (idempotency)
-	if confirmedIssues[payload.RequestID] {
-		log.Printf("Issue confirm %s already processed (idempotent)", payload.RequestID)
-		return
+	if r.confirmedIssues[payload.RequestID] {
+		r.log.Debug("Issue confirm already processed", "requestId", payload.RequestID)
+		return nil
 	}
 
 	var issueTitle string
@@ -992,45 +1190,43 @@ func handleIssueConfirm(payload types.IssueConfirmPayload, sender *Client) {
 	// If QueueIndex is -1, try to load directly from queue
 	if payload.QueueIndex == -1 {
 		// Lock mutex to safely access queueItems
-		mutex.Lock()
+		r.mutex.Lock()
 		// Find the item in the queue by identifier
 		var foundIdentifier string
-		for i := range queueItems {
-			if queueItems[i].Identifier == payload.Identifier &&
-				((payload.IsCustom && queueItems[i].Source == "custom") ||
-					(!payload.IsCustom && queueItems[i].Source == "linear")) {
+		for i := range r.queueItems {
+			if r.queueItems[i].Identifier == payload.Identifier &&
+				((payload.IsCustom && r.queueItems[i].Source == "custom") ||
+					(!payload.IsCustom && r.queueItems[i].Source == "linear")) {
 				foundIdentifier = payload.Identifier
 				loadedFromQueue = true
 				break
 			}
 		}
-		mutex.Unlock()
+		r.mutex.Unlock()
 
 		if loadedFromQueue {
-			log.Printf("✅ Found issue %s in queue, loading from queue", payload.Identifier)
 			// Update pendingQueueIndex to match (for Linear issues)
-			if !payload.IsCustom && linearClient != nil {
+			if !payload.IsCustom && r.linearClient != nil {
 				// Find the index in linearIssues
-				for j := range linearIssues {
-					if linearIssues[j].Identifier == foundIdentifier {
-						pendingQueueIndex = j
-						log.Printf("📍 Set pendingQueueIndex to %d for Linear issue", j)
+				for j := range r.linearIssues {
+					if r.linearIssues[j].Identifier == foundIdentifier {
+						r.pendingQueueIndex = j
 						break
 					}
 				}
 			}
 		} else {
-			log.Printf("❌ Issue %s not found in queue for direct loading (queue has %d items)", payload.Identifier, len(queueItems))
+			r.log.Info("Confirmed issue not in the queue", "issue", payload.Identifier, "queueItems", len(r.queueItems))
 			// Fall back to suggesting next issue if available
-			if !payload.IsCustom && linearClient != nil && pendingQueueIndex >= 0 && pendingQueueIndex < len(linearIssues) {
-				suggestIssueToHost(sender)
+			if !payload.IsCustom && r.linearClient != nil && r.pendingQueueIndex >= 0 && r.pendingQueueIndex < len(r.linearIssues) {
+				r.suggestIssueToHost(sender)
 			}
-			return
+			return &CommandError{Code: types.CodeNotFound, Err: fmt.Errorf("%s is not in the queue", payload.Identifier)}
 		}
 	} else {
 		// Validate queue index for suggested issues
-		if payload.QueueIndex != pendingQueueIndex {
-			log.Printf("Stale queue index: expected %d, got %d", pendingQueueIndex, payload.QueueIndex)
+		if payload.QueueIndex != r.pendingQueueIndex {
+			r.log.Info("Stale queue index", "expected", r.pendingQueueIndex, "got", payload.QueueIndex)
 			// Send stale message and re-suggest current issue
 			staleMsg := types.Message{
 				Type:    types.MessageIssueStale,
@@ -1038,44 +1234,44 @@ func handleIssueConfirm(payload types.IssueConfirmPayload, sender *Client) {
 			}
 			byteMessage := messaging.MarshallMessage(staleMsg)
 			sender.WriteMessage(websocket.TextMessage, byteMessage)
-			suggestIssueToHost(sender)
-			return
+			r.suggestIssueToHost(sender)
+			return &CommandError{Code: types.CodeStale, Err: errors.New("the queue has changed since the issue was suggested")}
 		}
 	}
 
 	// Mark as confirmed
-	confirmedIssues[payload.RequestID] = true
+	r.confirmedIssues[payload.RequestID] = true
 
 	if payload.IsCustom {
 		// Custom issue
 		issueTitle = payload.Identifier
-		currentIssue = issueTitle
-		currentLinearIssue = nil
-		log.Printf("Custom issue confirmed: %s", issueTitle)
+		r.currentIssue = issueTitle
+		r.currentLinearIssue = nil
+		r.log.Info("Custom issue confirmed", "user", sender.UserID)
 	} else {
 		// Linear issue - use pendingQueueIndex (set above if loaded from queue)
-		if pendingQueueIndex >= 0 && pendingQueueIndex < len(linearIssues) {
-			currentIssueIndex = pendingQueueIndex
-			issue := linearIssues[currentIssueIndex]
-			currentLinearIssue = &issue
+		if r.pendingQueueIndex >= 0 && r.pendingQueueIndex < len(r.linearIssues) {
+			r.currentIssueIndex = r.pendingQueueIndex
+			issue := r.linearIssues[r.currentIssueIndex]
+			r.currentLinearIssue = &issue
 			issueTitle = fmt.Sprintf("%s: %s", issue.Identifier, issue.Title)
-			currentIssue = issueTitle
-			log.Printf("Linear issue confirmed and loaded: %s", issue.Identifier)
+			r.currentIssue = issueTitle
+			r.log.Info("Linear issue confirmed and loaded", "user", sender.UserID, "issue", issue.Identifier)
 		} else {
-			log.Printf("Invalid pendingQueueIndex: %d (linearIssues length: %d)", pendingQueueIndex, len(linearIssues))
-			return
+			r.log.Warn("Invalid pending queue index", "pendingQueueIndex", r.pendingQueueIndex, "linearIssues", len(r.linearIssues))
+			return &CommandError{Code: types.CodeUnavailable, Err: errors.New("no Linear issue is pending confirmation")}
 		}
 	}
 
 	// If we just assigned an estimate, broadcast auto-advance notification to all clients
-	if justAssignedEstimate {
-		justAssignedEstimate = false // Reset flag
+	if r.justAssignedEstimate {
+		r.justAssignedEstimate = false // Reset flag
 		autoAdvanceMsg := types.Message{
-			Type:    "autoAdvance",
+			Type:    types.AutoAdvance,
 			Payload: "Advancing to next issue in queue...",
 		}
 		byteAutoAdvance := messaging.MarshallMessage(autoAdvanceMsg)
-		broadcast(byteAutoAdvance, sender)
+		r.broadcast(byteAutoAdvance, sender)
 	}
 
 	// Broadcast issue loaded to all clients
@@ -1088,55 +1284,40 @@ func handleIssueConfirm(payload types.IssueConfirmPayload, sender *Client) {
 		},
 	}
 	byteMessage := messaging.MarshallMessage(loadedMsg)
-	broadcast(byteMessage, sender)
+	r.broadcast(byteMessage, sender)
 
 	// Also send as CurrentIssue for backward compatibility (with linearIssue if applicable)
-	var currentIssuePayload types.CurrentIssuePayload
-	currentIssuePayload.Text = issueTitle
-	if currentLinearIssue != nil {
-		currentIssuePayload.LinearIssue = currentLinearIssue
-	}
+	r.broadcastCurrentIssue(types.CurrentIssuePayload{
+		Text:        issueTitle,
+		LinearIssue: r.currentLinearIssue,
+	}, sender)
 
-	currentIssuePayloadJSON, err := json.Marshal(currentIssuePayload)
-	if err != nil {
-		log.Printf("Error marshalling current issue payload: %v", err)
-		// Fallback to simple string
-		currentIssueMsg := types.Message{
-			Type:    types.CurrentIssue,
-			Payload: issueTitle,
-		}
-		byteMessageCompat := messaging.MarshallMessage(currentIssueMsg)
-		broadcast(byteMessageCompat, sender)
-	} else {
-		currentIssueMsg := types.Message{
-			Type:    types.CurrentIssue,
-			Payload: string(currentIssuePayloadJSON),
-		}
-		byteMessageCompat := messaging.MarshallMessage(currentIssueMsg)
-		broadcast(byteMessageCompat, sender)
-	}
+	// A new issue gets a fresh countdown
+	r.cancelTimer(sender)
+	r.startVoting(sender)
 
 	// Clear pending index after successful confirmation
-	pendingQueueIndex = -1
+	r.pendingQueueIndex = -1
 
 	// Remove confirmed issue from queue
-	removeQueueItem(payload.Identifier, payload.IsCustom)
-	broadcastQueueSync()
+	r.removeQueueItem(payload.Identifier, payload.IsCustom)
+	r.broadcastQueueSync()
+	return nil
 }
 
 // broadcastQueueSync sends the current queue state to all clients
-func broadcastQueueSync() {
-	mutex.Lock()
-	defer mutex.Unlock()
-	broadcastQueueSyncUnlocked()
+func (r *Room) broadcastQueueSync() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.broadcastQueueSyncUnlocked()
 }
 
 // broadcastQueueSyncUnlocked sends queue sync (caller must hold mutex)
-func broadcastQueueSyncUnlocked() {
+func (r *Room) broadcastQueueSyncUnlocked() {
 	syncMsg := types.QueueSyncMessage{
 		Type: types.MessageQueueSync,
 		Payload: types.QueueSyncPayload{
-			Items: queueItems,
+			Items: r.queueItems,
 		},
 	}
 	byteMessage := messaging.MarshallMessage(syncMsg)
@@ -1146,8 +1327,8 @@ func broadcastQueueSyncUnlocked() {
 		client *Client
 		userID string
 	}
-	clientList := make([]clientInfo, 0, len(clients))
-	for client := range clients {
+	clientList := make([]clientInfo, 0, len(r.clients))
+	for client := range r.clients {
 		clientList = append(clientList, clientInfo{
 			client: client,
 			userID: client.UserID,
@@ -1155,21 +1336,21 @@ func broadcastQueueSyncUnlocked() {
 	}
 	// Note: Caller will release mutex after this function returns
 
-	// Send to clients
+	// Queue for each client; this never blocks, so it is fine under the mutex
 	for _, info := range clientList {
 		if err := info.client.WriteMessage(websocket.TextMessage, byteMessage); err != nil {
-			log.Printf("Error sending queue sync to client %s: %v", info.userID, err)
+			r.log.Warn("Error sending queue sync", "user", info.userID, "err", err)
 		}
 	}
 }
 
 // removeQueueItem removes an item from the queue by identifier or ID
-func removeQueueItem(identifier string, isCustom bool) {
-	mutex.Lock()
-	defer mutex.Unlock()
+func (r *Room) removeQueueItem(identifier string, isCustom bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 
 	newQueue := make([]types.QueueItem, 0)
-	for _, item := range queueItems {
+	for _, item := range r.queueItems {
 		shouldRemove := false
 
 		if isCustom {
@@ -1181,7 +1362,7 @@ func removeQueueItem(identifier string, isCustom bool) {
 			// For Linear items, match by LinearID
 			if item.Source == "linear" && item.LinearID != "" {
 				// Check if this matches the identifier
-				linearIssue := findLinearIssueByIdentifier(identifier)
+				linearIssue := r.findLinearIssueByIdentifier(identifier)
 				if linearIssue != nil && item.LinearID == linearIssue.ID {
 					shouldRemove = true
 				}
@@ -1197,32 +1378,32 @@ func removeQueueItem(identifier string, isCustom bool) {
 		newQueue = append(newQueue, item)
 	}
 
-	queueItems = newQueue
-	log.Printf("Removed item from queue, %d items remaining", len(queueItems))
+	r.queueItems = newQueue
+	r.log.Info("Removed item from queue", "queueItems", len(r.queueItems))
 }
 
 // findLinearIssueByIdentifier finds a Linear issue by its identifier
-func findLinearIssueByIdentifier(identifier string) *types.LinearIssue {
-	for i := range linearIssues {
-		if linearIssues[i].Identifier == identifier {
-			return &linearIssues[i]
+func (r *Room) findLinearIssueByIdentifier(identifier string) *types.LinearIssue {
+	for i := range r.linearIssues {
+		if r.linearIssues[i].Identifier == identifier {
+			return &r.linearIssues[i]
 		}
 	}
 	return nil
 }
 
-// handleQueueAdd handles adding a custom item to the queue
-func handleQueueAdd(payload types.QueueAddPayload, sender *Client) {
-	if !sender.IsHost {
-		log.Printf("Non-host attempted to add queue item")
-		return
-	}
+// errQueueItemNotFound is returned when a queue command names an item that
+// no longer exists, e.g. because another host deleted it
+var errQueueItemNotFound = &CommandError{Code: types.CodeNotFound, Err: errors.New("queue item not found")}
 
-	mutex.Lock()
-	defer mutex.Unlock()
+// handleQueueAdd handles adding a custom item to the queue, returning the
+// new item
+func (r *Room) handleQueueAdd(payload types.QueueAddPayload, sender *Client) types.QueueItem {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 
-	queueItemCounter++
-	itemID := fmt.Sprintf("custom-%d-%d", time.Now().Unix(), queueItemCounter)
+	r.queueItemCounter++
+	itemID := fmt.Sprintf("custom-%d-%d", time.Now().Unix(), r.queueItemCounter)
 
 	newItem := types.QueueItem{
 		ID:          itemID,
@@ -1230,80 +1411,72 @@ func handleQueueAdd(payload types.QueueAddPayload, sender *Client) {
 		Identifier:  payload.Identifier,
 		Title:       payload.Title,
 		Description: payload.Description,
-		Index:       len(queueItems),
+		Index:       len(r.queueItems),
 	}
 
 	// Insert at specified index or append to end
-	if payload.Index != nil && *payload.Index >= 0 && *payload.Index < len(queueItems) {
+	if payload.Index != nil && *payload.Index >= 0 && *payload.Index < len(r.queueItems) {
 		// Insert at position
-		newQueue := make([]types.QueueItem, 0, len(queueItems)+1)
-		newQueue = append(newQueue, queueItems[:*payload.Index]...)
+		newQueue := make([]types.QueueItem, 0, len(r.queueItems)+1)
+		newQueue = append(newQueue, r.queueItems[:*payload.Index]...)
 		newItem.Index = *payload.Index
 		newQueue = append(newQueue, newItem)
-		for i := *payload.Index + 1; i < len(queueItems); i++ {
-			item := queueItems[i]
+		for i := *payload.Index + 1; i < len(r.queueItems); i++ {
+			item := r.queueItems[i]
 			item.Index = i + 1
 			newQueue = append(newQueue, item)
 		}
-		queueItems = newQueue
+		r.queueItems = newQueue
 	} else {
 		// Append to end
-		queueItems = append(queueItems, newItem)
+		r.queueItems = append(r.queueItems, newItem)
 	}
 
-	log.Printf("Host %s added queue item: %s", sender.UserID, newItem.Identifier)
-	broadcastQueueSyncUnlocked()
+	r.log.Info("Host added queue item", "user", sender.UserID, "item", newItem.Identifier)
+	r.broadcastQueueSyncUnlocked()
+	return newItem
 }
 
 // handleQueueUpdate handles updating a custom queue item
-func handleQueueUpdate(payload types.QueueUpdatePayload, sender *Client) {
-	if !sender.IsHost {
-		log.Printf("Non-host attempted to update queue item")
-		return
-	}
-
-	mutex.Lock()
-	defer mutex.Unlock()
-
-	for i := range queueItems {
-		if queueItems[i].ID == payload.ID {
-			if queueItems[i].Source != "custom" {
-				log.Printf("Attempted to update non-custom queue item")
-				return
+func (r *Room) handleQueueUpdate(payload types.QueueUpdatePayload, sender *Client) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i := range r.queueItems {
+		if r.queueItems[i].ID == payload.ID {
+			if r.queueItems[i].Source != "custom" {
+				r.log.Info("Refused to update a non-custom queue item", "user", sender.UserID, "item", payload.ID)
+				return &CommandError{Code: types.CodeInvalidTarget, Err: errors.New("only custom queue items can be edited")}
 			}
 
 			if payload.Identifier != "" {
-				queueItems[i].Identifier = payload.Identifier
+				r.queueItems[i].Identifier = payload.Identifier
 			}
 			if payload.Title != "" {
-				queueItems[i].Title = payload.Title
+				r.queueItems[i].Title = payload.Title
 			}
 			if payload.Description != "" {
-				queueItems[i].Description = payload.Description
+				r.queueItems[i].Description = payload.Description
 			}
 
-			log.Printf("Host %s updated queue item: %s", sender.UserID, payload.ID)
-			broadcastQueueSyncUnlocked()
-			return
+			r.log.Info("Host updated queue item", "user", sender.UserID, "item", payload.ID)
+			r.broadcastQueueSyncUnlocked()
+			return nil
 		}
 	}
 
-	log.Printf("Queue item not found for update: %s", payload.ID)
+	r.log.Info("Queue item not found for update", "item", payload.ID)
+	return errQueueItemNotFound
 }
 
 // handleQueueDelete handles removing an item from the queue
-func handleQueueDelete(payload types.QueueDeletePayload, sender *Client) {
-	if !sender.IsHost {
-		log.Printf("Non-host attempted to delete queue item")
-		return
-	}
-
-	mutex.Lock()
-	defer mutex.Unlock()
+func (r *Room) handleQueueDelete(payload types.QueueDeletePayload, sender *Client) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 
 	newQueue := make([]types.QueueItem, 0)
 	found := false
-	for _, item := range queueItems {
+	for _, item := range r.queueItems {
 		if item.ID == payload.ID {
 			found = true
 			continue
@@ -1313,28 +1486,24 @@ func handleQueueDelete(payload types.QueueDeletePayload, sender *Client) {
 		newQueue = append(newQueue, item)
 	}
 
-	if found {
-		queueItems = newQueue
-		log.Printf("Host %s deleted queue item: %s", sender.UserID, payload.ID)
-		broadcastQueueSyncUnlocked()
-	} else {
-		log.Printf("Queue item not found for delete: %s", payload.ID)
+	if !found {
+		r.log.Info("Queue item not found for delete", "item", payload.ID)
+		return errQueueItemNotFound
 	}
+	r.queueItems = newQueue
+	r.log.Info("Host deleted queue item", "user", sender.UserID, "item", payload.ID)
+	r.broadcastQueueSyncUnlocked()
+	return nil
 }
 
 // handleQueueReorder handles reordering queue items
-func handleQueueReorder(payload types.QueueReorderPayload, sender *Client) {
-	if !sender.IsHost {
-		log.Printf("Non-host attempted to reorder queue")
-		return
-	}
-
-	mutex.Lock()
-	defer mutex.Unlock()
+func (r *Room) handleQueueReorder(payload types.QueueReorderPayload, sender *Client) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 
 	// Create a map for quick lookup
 	itemMap := make(map[string]types.QueueItem)
-	for _, item := range queueItems {
+	for _, item := range r.queueItems {
 		itemMap[item.ID] = item
 	}
 
@@ -1348,7 +1517,7 @@ func handleQueueReorder(payload types.QueueReorderPayload, sender *Client) {
 	}
 
 	// Add any items not in the reorder list (shouldn't happen, but be safe)
-	for _, item := range queueItems {
+	for _, item := range r.queueItems {
 		found := false
 		for _, id := range payload.ItemIDs {
 			if item.ID == id {
@@ -1362,59 +1531,51 @@ func handleQueueReorder(payload types.QueueReorderPayload, sender *Client) {
 		}
 	}
 
-	queueItems = newQueue
-	log.Printf("Host %s reordered queue, %d items", sender.UserID, len(queueItems))
-	broadcastQueueSyncUnlocked()
+	r.queueItems = newQueue
+	r.log.Info("Host reordered queue", "user", sender.UserID, "queueItems", len(r.queueItems))
+	r.broadcastQueueSyncUnlocked()
 }
 
 // handleAssignEstimate assigns the current average estimate to the Linear issue
-func handleAssignEstimate(sender *Client) {
-	if !sender.IsHost {
-		log.Printf("Non-host attempted to assign estimate")
-		return
-	}
-
+func (r *Room) handleAssignEstimate(sender *Client) error {
 	// Only allow if there's a current Linear issue and votes have been revealed
-	if currentLinearIssue == nil || linearClient == nil {
-		log.Printf("No Linear issue currently active")
-		return
+	if r.currentLinearIssue == nil || r.linearClient == nil {
+		r.log.Info("No Linear issue to assign an estimate to")
+		return &CommandError{Code: types.CodeUnavailable, Err: errors.New("no Linear issue is currently active")}
 	}
 
 	// Calculate average estimate
-	average := getPointAverage()
-	if average == 0 {
-		log.Printf("No valid estimates to assign")
-		return
+	average, ok := r.getPointAverage()
+	if !ok {
+		r.log.Info("No valid estimates to assign")
+		return &CommandError{Code: types.CodeUnavailable, Err: errors.New("there are no estimates to assign")}
 	}
 
-	// Update estimate in Linear
-	err := linearClient.UpdateEstimate(currentLinearIssue.ID, average)
+	// Update estimate in Linear, which only accepts whole points
+	points := int64(math.Round(average.Value))
+	err := r.linearClient.UpdateEstimate(r.currentLinearIssue.ID, points)
 	if err != nil {
-		log.Printf("Failed to assign estimate to Linear issue %s: %v", currentLinearIssue.Identifier, err)
-		// Send error message to host
-		errorMsg := types.Message{
-			Type:    "estimateAssignmentError",
-			Payload: fmt.Sprintf("Failed to assign estimate: %v", err),
-		}
-		byteMessage := messaging.MarshallMessage(errorMsg)
-		sender.WriteMessage(websocket.TextMessage, byteMessage)
-		return
+		r.log.Error("Failed to assign estimate in Linear", "issue", r.currentLinearIssue.Identifier, "err", err)
+		return &CommandError{
+			Code:   types.CodeUpstream,
+			Legacy: types.EstimateAssignmentError,
+			Err:    fmt.Errorf("Failed to assign estimate: %w", err),
+		}
 	}
 
-	log.Printf("✅ Successfully assigned estimate %d to Linear issue %s", average, currentLinearIssue.Identifier)
+	r.log.Info("Assigned estimate in Linear", "issue", r.currentLinearIssue.Identifier, "estimate", average.Label, "points", points)
 
 	// Mark that we just assigned (for auto-advance notification)
-	justAssignedEstimate = true
-	log.Printf("🏷️ Set justAssignedEstimate = true")
+	r.justAssignedEstimate = true
 
 	// Send success message to host
 	successMsg := types.Message{
-		Type:    "estimateAssignmentSuccess",
-		Payload: fmt.Sprintf("Estimate %d assigned to %s", average, currentLinearIssue.Identifier),
+		Type:    types.EstimateAssignmentSuccess,
+		Payload: fmt.Sprintf("Estimate %s assigned to %s", average.Label, r.currentLinearIssue.Identifier),
 	}
 	byteMessage := messaging.MarshallMessage(successMsg)
-	log.Printf("📤 Sending estimateAssignmentSuccess message to host")
 	if err := sender.WriteMessage(websocket.TextMessage, byteMessage); err != nil {
-		log.Printf("❌ Error sending estimateAssignmentSuccess: %v", err)
+		r.log.Warn("Error sending estimateAssignmentSuccess", "user", sender.UserID, "err", err)
 	}
+	return nil
 }
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jcpsimmons/poker/messaging"
+	"github.com/jcpsimmons/poker/types"
+)
+
+// shutdownTimeout bounds how long a graceful shutdown may take before the
+// remaining connections are abandoned
+const shutdownTimeout = 10 * time.Second
+
+// shutdownReason is sent to clients in the serverShutdown message and the
+// close frame that follows it
+const shutdownReason = "server shutting down"
+
+// Serve serves s on ln until ctx is cancelled, then shuts down gracefully:
+// the listener is closed, every client is told the server is going away,
+// and each room is saved. Without a web app it closes ln and fails right
+// away, unless Options.NoWebApp is set.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	if err := s.checkWebApp(); err != nil {
+		ln.Close()
+		return err
+	}
+
+	srv := &http.Server{Handler: s, ErrorLog: slog.NewLogLogger(s.log.Handler(), slog.LevelError)}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	s.log.Info("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// WebSocket connections are hijacked, so this only stops new requests
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		s.log.Error("Error stopping HTTP server", "err", err)
+	}
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	s.log.Info("Server stopped")
+	return nil
+}
+
+// Shutdown closes every room: clients get a serverShutdown message and a
+// going-away close frame, and each room is saved once the command it is
+// running, such as a Linear update, has finished. It returns early with
+// ctx's error if that takes too long. New connections are refused from
+// then on.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.closed.Store(true)
+
+	s.roomsMutex.Lock()
+	open := make([]*Room, 0, len(s.rooms))
+	for _, room := range s.rooms {
+		open = append(open, room)
+	}
+	s.roomsMutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		var wg sync.WaitGroup
+		for _, room := range open {
+			wg.Add(1)
+			go func(room *Room) {
+				defer wg.Done()
+				room.shutdown(ctx)
+			}(room)
+		}
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close shuts s down like Shutdown, giving up after a few seconds
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return s.Shutdown(ctx)
+}
+
+// shutdown notifies the room's clients, saves the room and stops its event
+// loop, then waits for the clients' queued messages to be written
+func (r *Room) shutdown(ctx context.Context) {
+	var clients []*Client
+	r.do(func() {
+		r.mutex.Lock()
+		r.stopTimerUnlocked()
+		r.stopAutoRevealUnlocked()
+		r.stopHostRecoveryUnlocked()
+		r.stopIdleUnlocked()
+		for client := range r.clients {
+			clients = append(clients, client)
+		}
+		r.mutex.Unlock()
+
+		r.broadcast(messaging.MarshallMessage(types.Message{Type: types.ServerShutdown, Payload: shutdownReason}), nil)
+		for _, client := range clients {
+			client.closeWith(websocket.CloseGoingAway, shutdownReason)
+		}
+		r.persist()
+
+		// Disconnects arriving from here on are dropped, so they can't
+		// change the saved state
+		r.stop()
+	})
+
+	for _, client := range clients {
+		if err := client.waitFlushed(ctx); err != nil {
+			r.log.Warn("Gave up flushing messages", "user", client.UserID, "err", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jcpsimmons/poker/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdown_NotifiesClientsAndSavesRooms(t *testing.T) {
+	ts := newRoomTestServer(t)
+	dir := t.TempDir()
+	require.NoError(t, SetDataDir(dir))
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.NewIssue, Payload: "CDP-1: Login"}))
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Estimate, Payload: "5"}))
+	host.drain()
+
+	// The room is written again on the way out
+	require.NoError(t, os.Remove(filepath.Join(dir, "squad-a.json")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, Shutdown(ctx))
+
+	messages := host.drain()
+	notice := findMessage(messages, types.ServerShutdown)
+	require.NotNil(t, notice)
+	require.Equal(t, shutdownReason, notice["payload"])
+
+	_, open := <-host.messages
+	require.False(t, open, "the server closes the connection")
+	_, _, err := host.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "unexpected error: %v", err)
+
+	snapshot := readSnapshot(t, dir, "squad-a")
+	require.Equal(t, "CDP-1: Login", snapshot.CurrentIssue)
+	require.Equal(t, "5", snapshot.Votes["Alice"])
+
+	// A closed room runs nothing more
+	require.False(t, getRoom("squad-a").do(func() {}))
+}
+
+func TestServe_StopsWhenContextIsCancelled(t *testing.T) {
+	ResetServerState()
+	defaultServer().noWebApp = true // The test binary has no web app built in
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(ctx, ln)
+	}()
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	conn.Close()
+
+	cancel()
+	select {
+	case err := <-served:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve didn't return after the context was cancelled")
+	}
+
+	_, err = net.Dial("tcp", addr)
+	require.Error(t, err, "the listener is closed")
+}
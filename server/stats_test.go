@@ -0,0 +1,136 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/jcpsimmons/poker/types"
+	"github.com/stretchr/testify/require"
+)
+
+// roomWithVotes returns the default room with one mock client per vote
+func roomWithVotes(t *testing.T, votes map[string]string) *Room {
+	setupTestServer()
+	room := testRoom()
+	for user, vote := range votes {
+		client, _ := createMockClient()
+		client.UserID = user
+		client.CurrentEstimate, _ = room.deck.estimate(vote)
+		room.clients[client] = true
+	}
+	return room
+}
+
+func TestGetRevealStats(t *testing.T) {
+	tests := []struct {
+		name  string
+		votes map[string]string
+		want  *types.RevealStats
+	}{
+		{
+			name:  "no votes",
+			votes: map[string]string{"Alice": ""},
+			want:  nil,
+		},
+		{
+			name:  "only special cards",
+			votes: map[string]string{"Alice": "?", "Bob": "☕"},
+			want:  nil,
+		},
+		{
+			name:  "unanimous",
+			votes: map[string]string{"Alice": "5", "Bob": "5"},
+			want: &types.RevealStats{
+				Votes: 2, Mean: 5, Median: 5, Mode: []string{"5"}, Min: "5", Max: "5",
+				Consensus: true, HighestVoters: []string{}, LowestVoters: []string{},
+			},
+		},
+		{
+			name:  "adjacent cards are a consensus",
+			votes: map[string]string{"Alice": "3", "Bob": "5", "Carol": "5"},
+			want: &types.RevealStats{
+				Votes: 3, Mean: 4.33, Median: 5, Mode: []string{"5"}, Min: "3", Max: "5", StdDev: 0.94,
+				Consensus: true, HighestVoters: []string{"Bob", "Carol"}, LowestVoters: []string{"Alice"},
+			},
+		},
+		{
+			name:  "spread with outliers",
+			votes: map[string]string{"Alice": "1", "Bob": "3", "Carol": "3", "Dave": "13", "Erin": "?"},
+			want: &types.RevealStats{
+				Votes: 4, Mean: 5, Median: 3, Mode: []string{"3"}, Min: "1", Max: "13", StdDev: 4.69,
+				Consensus: false, HighestVoters: []string{"Dave"}, LowestVoters: []string{"Alice"},
+			},
+		},
+		{
+			name:  "even count and tied modes",
+			votes: map[string]string{"Alice": "2", "Bob": "8"},
+			want: &types.RevealStats{
+				Votes: 2, Mean: 5, Median: 5, Mode: []string{"2", "8"}, Min: "2", Max: "8", StdDev: 3,
+				Consensus: false, HighestVoters: []string{"Bob"}, LowestVoters: []string{"Alice"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			room := roomWithVotes(t, tt.votes)
+			require.Equal(t, tt.want, room.getRevealStats())
+		})
+	}
+}
+
+func TestFormatResultsComment(t *testing.T) {
+	room := roomWithVotes(t, map[string]string{"Alice": "1", "Bob": "8", "Carol": "?"})
+
+	comment := formatResultsComment(
+		[]types.UserEstimate{
+			{User: "Alice", Estimate: "1"},
+			{User: "Bob", Estimate: "8"},
+			{User: "Carol", Estimate: "?", Special: true},
+		},
+		room.getPointAverageLabel(),
+		room.getRevealStats(),
+	)
+
+	require.Equal(t, `## Planning Poker Results
+
+- Alice: 1
+- Bob: 8
+- Carol: ?
+
+**Average:** 5 points
+
+- **Median:** 4.5
+- **Mode:** 1, 8
+- **Range:** 1–8
+- **Std dev:** 3.5
+- **Consensus:** no
+- **Highest (8):** Bob
+- **Lowest (1):** Alice
+
+- ? 1 person needs clarification`, comment)
+}
+
+func TestReveal_IncludesStats(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	alice := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer alice.Close()
+	bob := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+	defer bob.Close()
+
+	require.NoError(t, alice.WriteJSON(types.Message{Type: types.Estimate, Payload: "2"}))
+	require.NoError(t, bob.WriteJSON(types.Message{Type: types.Estimate, Payload: "13"}))
+	bob.drain()
+	alice.drain()
+
+	require.NoError(t, alice.WriteJSON(types.Message{Type: types.Reveal}))
+	reveal := findMessage(bob.drain(), types.RevealData)
+	require.NotNil(t, reveal)
+
+	stats := reveal["payload"].(map[string]interface{})["stats"].(map[string]interface{})
+	require.Equal(t, false, stats["consensus"])
+	require.Equal(t, []interface{}{"Bob"}, stats["highestVoters"])
+	require.Equal(t, []interface{}{"Alice"}, stats["lowestVoters"])
+	require.Equal(t, "2", stats["min"])
+	require.Equal(t, "13", stats["max"])
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jcpsimmons/poker/types"
+	"github.com/stretchr/testify/require"
+)
+
+// stressNames are the participants the stress test's commands target
+var stressNames = []string{"host", "p1", "p2", "p3", "p4", "p5", "watcher", "renamed"}
+
+// stressCommand builds a random client command, in the legacy or the
+// versioned format
+func stressCommand(rng *rand.Rand, token string) types.Envelope {
+	name := func() string { return stressNames[rng.Intn(len(stressNames))] }
+	commands := []struct {
+		messageType types.MessageType
+		payload     interface{}
+	}{
+		{types.NewIssue, fmt.Sprintf("CDP-%d", rng.Intn(100))},
+		{types.Estimate, []string{"1", "3", "8", "?", "☕", "42"}[rng.Intn(6)]},
+		{types.Reveal, nil},
+		{types.Reset, nil},
+		{types.Leave, nil},
+		{types.Resume, token},
+		{types.MessageIssueConfirm, types.IssueConfirmPayload{RequestID: fmt.Sprint(rng.Int()), Identifier: "CDP-1", QueueIndex: -1, IsCustom: true}},
+		{types.MessageQueueAdd, types.QueueAddPayload{Identifier: fmt.Sprintf("Q-%d", rng.Intn(10)), Title: "stress"}},
+		{types.MessageQueueUpdate, types.QueueUpdatePayload{ID: "custom-1", Title: "updated"}},
+		{types.MessageQueueDelete, types.QueueDeletePayload{ID: "custom-1"}},
+		{types.MessageQueueReorder, types.QueueReorderPayload{ItemIDs: []string{"custom-2", "custom-1"}}},
+		{types.MessageAssignEstimate, nil},
+		{types.StartTimer, types.StartTimerPayload{Seconds: 1, AutoReveal: rng.Intn(2) == 0}},
+		{types.StopTimer, nil},
+		{types.SetAutoReveal, types.AutoRevealPayload{Enabled: rng.Intn(2) == 0}},
+		{types.TransferHost, types.TransferHostPayload{Username: name()}},
+		{types.SetCoHost, types.CoHostPayload{Username: name(), CoHost: rng.Intn(2) == 0}},
+		{types.Kick, types.KickPayload{Username: name()}},
+		{types.Rename, types.RenamePayload{Username: name(), NewName: name()}},
+		{types.ClearVote, types.ClearVotePayload{Username: name()}},
+	}
+
+	command := commands[rng.Intn(len(commands))]
+	envelope := types.Envelope{Type: command.messageType}
+	if rng.Intn(2) == 0 {
+		envelope.Version = types.ProtocolVersion
+		envelope.RequestID = fmt.Sprint(rng.Int())
+	}
+	if command.payload != nil {
+		envelope.Payload, _ = json.Marshal(command.payload)
+	}
+	return envelope
+}
+
+// stressWorker plays one participant until the deadline, reconnecting
+// whenever it is kicked or leaves, sometimes with its resume token. It
+// returns the first dial error, since it runs outside the test goroutine.
+func stressWorker(url string, join types.JoinPayload, seed int64, deadline time.Time) error {
+	rng := rand.New(rand.NewSource(seed))
+	var token atomic.Value
+	token.Store("")
+
+	for time.Now().Before(deadline) {
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			return fmt.Errorf("%s couldn't connect: %w", join.Username, err)
+		}
+
+		go func() {
+			for {
+				var msg types.Envelope
+				if err := conn.ReadJSON(&msg); err != nil {
+					return
+				}
+				if msg.Type == types.ResumeToken {
+					var payload types.ResumeTokenPayload
+					if json.Unmarshal(msg.Payload, &payload) == nil {
+						token.Store(payload.Token)
+					}
+				}
+			}
+		}()
+
+		if saved := token.Load().(string); saved != "" && rng.Intn(2) == 0 {
+			err = conn.WriteJSON(types.Message{Type: types.Resume, Payload: saved})
+		} else {
+			err = conn.WriteJSON(types.JoinMessage{Type: types.Join, Payload: join})
+		}
+		for err == nil && time.Now().Before(deadline) && rng.Intn(50) != 0 {
+			err = conn.WriteJSON(stressCommand(rng, token.Load().(string)))
+			time.Sleep(time.Duration(rng.Intn(2000)) * time.Microsecond)
+		}
+		conn.Close()
+	}
+	return nil
+}
+
+// TestStress_AllMessageTypes hammers a room with every client command from
+// many connections at once. Run it with -race.
+func TestStress_AllMessageTypes(t *testing.T) {
+	ts := newRoomTestServer(t)
+	SetAutoReveal(true, 5*time.Millisecond)
+	SetHostRecoveryTimeout(20 * time.Millisecond)
+	SetResumeGracePeriod(50 * time.Millisecond)
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws?room=stress"
+	deadline := time.Now().Add(2 * time.Second)
+
+	var wg sync.WaitGroup
+	workers := stressNames[:7]
+	errs := make(chan error, len(workers))
+	for i, name := range workers {
+		join := types.JoinPayload{Username: name, IsHost: name == "host", IsObserver: name == "watcher"}
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			errs <- stressWorker(url, join, seed, deadline)
+		}(int64(i))
+	}
+
+	// Read the session over HTTP while it changes
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for time.Now().Before(deadline) {
+			resp, err := http.Get(ts.URL + "/api/history?room=stress")
+			if err == nil {
+				resp.Body.Close()
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	// The room still works afterwards
+	client := joinTestRoom(t, ts.URL, "stress", "Latecomer", false)
+	defer client.Close()
+	require.NotNil(t, findMessage(client.welcome, types.ResumeToken))
+	require.NotNil(t, findMessage(client.welcome, types.VoteStatus))
+}
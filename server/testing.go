@@ -1,6 +1,8 @@
 package server
 
 import (
+	"net/http"
+
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/mock"
 )
@@ -35,30 +37,48 @@ func (m *MockWebSocketConn) SetWriteDeadline(t interface{}) error {
 	return args.Error(0)
 }
 
-// ResetServerState resets all global server state for testing
+// ResetServerState replaces the default server with a fresh one for testing
 func ResetServerState() {
-	mutex.Lock()
-	defer mutex.Unlock()
+	old := defaultInstance.Swap(newServer(nil, embeddedAssets()))
+
+	old.roomsMutex.Lock()
+	defer old.roomsMutex.Unlock()
 
-	// Close all existing connections
-	for client := range clients {
-		if client.Conn != nil {
-			client.Conn.Close()
+	// Close all existing connections and stop their countdowns
+	for _, room := range old.rooms {
+		room.mutex.Lock()
+		room.stopTimerUnlocked()
+		room.stopAutoRevealUnlocked()
+		room.stopHostRecoveryUnlocked()
+		room.stopIdleUnlocked()
+		for client := range room.clients {
+			if client.Conn != nil {
+				client.Conn.Close()
+			}
 		}
+		room.mutex.Unlock()
+		room.stop()
 	}
+}
+
+// handler serves WebSocket connections for the default server
+func handler(w http.ResponseWriter, r *http.Request) {
+	defaultServer().handleWebSocket(w, r)
+}
+
+// historyHandler serves the history export for the default server
+func historyHandler(w http.ResponseWriter, r *http.Request) {
+	defaultServer().handleHistory(w, r)
+}
+
+// getRoom returns a room of the default server
+func getRoom(slug string) *Room {
+	return defaultServer().getRoom(slug)
+}
 
-	clients = make(map[*Client]bool)
-	currentIssue = ""
-	linearClient = nil
-	linearIssues = nil
-	currentIssueIndex = -1
-	currentLinearIssue = nil
-	pendingQueueIndex = -1
-	confirmedIssues = make(map[string]bool)
-	queueItems = nil
-	currentQueueIndex = -1
-	queueItemCounter = 0
-	justAssignedEstimate = false
+// testRoom returns the default room, which most tests connect to
+func testRoom() *Room {
+	return getRoom(defaultRoomSlug)
 }
 
 // createMockClient creates a Client with a mock WebSocket connection for testing
@@ -67,7 +87,7 @@ func createMockClient() (*Client, *MockWebSocketConn) {
 	client := &Client{
 		Conn:            (*websocket.Conn)(nil), // We'll use type assertion tricks in tests
 		UserID:          "",
-		CurrentEstimate: 0,
+		CurrentEstimate: Estimate{},
 		IsHost:          false,
 	}
 	return client, mockConn
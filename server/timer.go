@@ -0,0 +1,122 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jcpsimmons/poker/messaging"
+	"github.com/jcpsimmons/poker/types"
+)
+
+// maxTimerDuration caps how long a host can timebox a round
+const maxTimerDuration = time.Hour
+
+// timerPayloadUnlocked describes the running countdown, or nil if there is
+// none (caller must hold mutex)
+func (r *Room) timerPayloadUnlocked() *types.TimerPayload {
+	if r.timer == nil {
+		return nil
+	}
+	deadline := r.timerDeadline
+	return &types.TimerPayload{
+		Running:    true,
+		Deadline:   &deadline,
+		ServerTime: time.Now().UTC(),
+		Seconds:    r.timerSeconds,
+		AutoReveal: r.timerAutoReveal,
+	}
+}
+
+// stopTimerUnlocked cancels the running countdown without telling anyone.
+// It reports whether a countdown was running (caller must hold mutex).
+func (r *Room) stopTimerUnlocked() bool {
+	if r.timer == nil {
+		return false
+	}
+	r.timer.Stop()
+	r.timer = nil
+	r.timerGeneration++
+	return true
+}
+
+// broadcastTimer sends the countdown state to everybody in the room
+func (r *Room) broadcastTimer(payload types.TimerPayload, sender *Client) {
+	message := types.TimerMessage{
+		Type:    types.TimerState,
+		Payload: payload,
+	}
+	r.broadcast(messaging.MarshallMessage(message), sender)
+}
+
+// handleStartTimer starts a countdown for the current round, replacing any
+// countdown that is already running
+func (r *Room) handleStartTimer(payload types.StartTimerPayload, sender *Client) error {
+	duration := time.Duration(payload.Seconds) * time.Second
+	if duration <= 0 || duration > maxTimerDuration {
+		return &CommandError{
+			Code:   types.CodeInvalidTimer,
+			Legacy: types.TimerError,
+			Err:    fmt.Errorf("timer must be between 1 and %d seconds", int(maxTimerDuration.Seconds())),
+		}
+	}
+
+	r.mutex.Lock()
+	r.stopTimerUnlocked()
+	generation := r.timerGeneration
+	r.timerDeadline = time.Now().UTC().Add(duration)
+	r.timerSeconds = payload.Seconds
+	r.timerAutoReveal = payload.AutoReveal
+	r.timer = time.AfterFunc(duration, func() {
+		r.do(func() { r.expireTimer(generation) })
+	})
+	timer := r.timerPayloadUnlocked()
+	r.mutex.Unlock()
+
+	r.log.Info("Host started a timer", "user", sender.UserID, "duration", duration, "autoReveal", payload.AutoReveal)
+	r.broadcastTimer(*timer, sender)
+	return nil
+}
+
+// cancelTimer stops the running countdown, if any, and tells everybody
+func (r *Room) cancelTimer(sender *Client) {
+	r.mutex.Lock()
+	stopped := r.stopTimerUnlocked()
+	seconds, autoReveal := r.timerSeconds, r.timerAutoReveal
+	r.mutex.Unlock()
+
+	if !stopped {
+		return
+	}
+	r.log.Info("Timer cancelled")
+	r.broadcastTimer(types.TimerPayload{
+		ServerTime: time.Now().UTC(),
+		Seconds:    seconds,
+		AutoReveal: autoReveal,
+	}, sender)
+}
+
+// expireTimer runs when a countdown reaches its deadline. Countdowns that
+// were stopped or replaced in the meantime are ignored.
+func (r *Room) expireTimer(generation int) {
+	r.mutex.Lock()
+	if r.timer == nil || r.timerGeneration != generation {
+		r.mutex.Unlock()
+		return
+	}
+	r.stopTimerUnlocked()
+	seconds, autoReveal := r.timerSeconds, r.timerAutoReveal
+	r.mutex.Unlock()
+
+	r.log.Info("Timer ran out")
+	r.broadcastTimer(types.TimerPayload{
+		ServerTime: time.Now().UTC(),
+		Seconds:    seconds,
+		AutoReveal: autoReveal,
+		Expired:    true,
+	}, nil)
+
+	// Only an open round is revealed; nobody has voted in an idle one
+	if autoReveal && r.revealRound(nil) == nil {
+		r.persist()
+	}
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jcpsimmons/poker/linear"
+	"github.com/jcpsimmons/poker/types"
+	"github.com/stretchr/testify/require"
+)
+
+// startTimer asks the server for a countdown of the given length
+func startTimer(t *testing.T, client *roomTestClient, seconds int, autoReveal bool) {
+	require.NoError(t, client.WriteJSON(types.StartTimerMessage{
+		Type:    types.StartTimer,
+		Payload: types.StartTimerPayload{Seconds: seconds, AutoReveal: autoReveal},
+	}))
+}
+
+func TestTimer_BroadcastsDeadline(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	player := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+	defer player.Close()
+	host.drain()
+
+	before := time.Now()
+	startTimer(t, host, 90, false)
+
+	timer := findMessage(player.drain(), types.TimerState)
+	require.NotNil(t, timer)
+	payload := timer["payload"].(map[string]interface{})
+	require.Equal(t, true, payload["running"])
+	require.Equal(t, float64(90), payload["seconds"])
+
+	deadline, err := time.Parse(time.RFC3339Nano, payload["deadline"].(string))
+	require.NoError(t, err)
+	require.WithinDuration(t, before.Add(90*time.Second), deadline, 2*time.Second)
+
+	// Late joiners get the same deadline with the round state
+	late := joinTestRoom(t, ts.URL, "squad-a", "Carol", false)
+	defer late.Close()
+	states := roundStates(late.welcome)
+	require.Len(t, states, 1)
+	require.Equal(t, payload["deadline"], states[0]["timer"].(map[string]interface{})["deadline"])
+}
+
+func TestTimer_AutoRevealOnExpiry(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Estimate, Payload: "5"}))
+	startTimer(t, host, 1, true)
+	host.drain()
+
+	require.Eventually(t, func() bool {
+		return getRoom("squad-a").getPhase() == types.RoundRevealed
+	}, 3*time.Second, 20*time.Millisecond)
+
+	messages := host.drain()
+	require.NotNil(t, findMessage(messages, types.RevealData))
+	timer := findMessage(messages, types.TimerState)
+	require.NotNil(t, timer)
+	require.Equal(t, true, timer["payload"].(map[string]interface{})["expired"])
+}
+
+func TestTimer_ExpiryWithoutAutoRevealKeepsVoting(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	require.NoError(t, host.WriteJSON(types.Message{Type: types.Estimate, Payload: "5"}))
+	startTimer(t, host, 1, false)
+	host.drain()
+
+	time.Sleep(1200 * time.Millisecond)
+	messages := host.drain()
+	require.NotNil(t, findMessage(messages, types.TimerState))
+	require.Nil(t, findMessage(messages, types.RevealData))
+	require.Equal(t, types.RoundVoting, getRoom("squad-a").getPhase())
+}
+
+func TestTimer_Cancellation(t *testing.T) {
+	tests := []struct {
+		name   string
+		cancel func(t *testing.T, host *roomTestClient)
+	}{
+		{
+			name: "reset",
+			cancel: func(t *testing.T, host *roomTestClient) {
+				require.NoError(t, host.WriteJSON(types.Message{Type: types.Reset}))
+			},
+		},
+		{
+			name: "issue confirm",
+			cancel: func(t *testing.T, host *roomTestClient) {
+				require.NoError(t, host.WriteJSON(types.QueueAddMessage{
+					Type:    types.MessageQueueAdd,
+					Payload: types.QueueAddPayload{Identifier: "CUSTOM-1", Title: "Next"},
+				}))
+				require.NoError(t, host.WriteJSON(types.IssueConfirmMessage{
+					Type: types.MessageIssueConfirm,
+					Payload: types.IssueConfirmPayload{
+						RequestID:  "req-1",
+						Identifier: "CUSTOM-1",
+						QueueIndex: -1,
+						IsCustom:   true,
+					},
+				}))
+			},
+		},
+		{
+			name: "new issue",
+			cancel: func(t *testing.T, host *roomTestClient) {
+				require.NoError(t, host.WriteJSON(types.Message{Type: types.NewIssue, Payload: "Next"}))
+			},
+		},
+		{
+			name: "stop",
+			cancel: func(t *testing.T, host *roomTestClient) {
+				require.NoError(t, host.WriteJSON(types.Message{Type: types.StopTimer}))
+			},
+		},
+		{
+			name: "reveal",
+			cancel: func(t *testing.T, host *roomTestClient) {
+				require.NoError(t, host.WriteJSON(types.Message{Type: types.Reveal}))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newRoomTestServer(t)
+
+			host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+			defer host.Close()
+			require.NoError(t, host.WriteJSON(types.Message{Type: types.Estimate, Payload: "5"}))
+			startTimer(t, host, 60, true)
+			host.drain()
+			room := getRoom("squad-a")
+			room.mutex.Lock()
+			generation := room.timerGeneration
+			room.mutex.Unlock()
+
+			tt.cancel(t, host)
+			timer := findMessage(host.drain(), types.TimerState)
+			require.NotNil(t, timer)
+			require.Equal(t, false, timer["payload"].(map[string]interface{})["running"])
+
+			// The cancelled countdown never fires
+			room.mutex.Lock()
+			require.Nil(t, room.timer)
+			room.mutex.Unlock()
+			room.do(func() { room.expireTimer(generation) })
+			require.Nil(t, findMessage(host.drain(), types.TimerState))
+		})
+	}
+}
+
+func TestTimer_CancelledWhenJoinLoadsIssue(t *testing.T) {
+	ts := newRoomTestServer(t)
+	SetLinearIssues([]types.LinearIssue{{ID: "id-1", Identifier: "ENG-1", Title: "First"}}, linear.NewClient("key"))
+
+	// A countdown left over from before the host joined
+	room := testRoom()
+	room.do(func() {
+		require.NoError(t, room.handleStartTimer(types.StartTimerPayload{Seconds: 60}, &Client{UserID: "Alice"}))
+	})
+
+	host := joinTestRoom(t, ts.URL, defaultRoomSlug, "Alice", true)
+	defer host.Close()
+	require.NotNil(t, findMessage(host.welcome, types.MessageIssueLoaded))
+
+	room.mutex.Lock()
+	defer room.mutex.Unlock()
+	require.Nil(t, room.timer, "loading the first issue starts without a countdown")
+}
+
+func TestTimer_Rejected(t *testing.T) {
+	ts := newRoomTestServer(t)
+
+	host := joinTestRoom(t, ts.URL, "squad-a", "Alice", true)
+	defer host.Close()
+	player := joinTestRoom(t, ts.URL, "squad-a", "Bob", false)
+	defer player.Close()
+	host.drain()
+
+	startTimer(t, host, 0, false)
+	require.NotNil(t, findMessage(host.drain(), types.TimerError))
+
+	startTimer(t, player, 60, false)
+	require.Nil(t, findMessage(host.drain(), types.TimerState), "only hosts can start the timer")
+}
@@ -33,10 +33,10 @@ func TestWebSocketJoinFlow(t *testing.T) {
 	err := conn.WriteJSON(joinMsg)
 	require.NoError(t, err)
 
-	// Expect to receive: currentIssue, currentEstimate, participantCount, voteStatus, queueSync
+	// Expect to receive: currentIssue, currentEstimate, deck, roundState, resumeToken, participantCount, voteStatus, queueSync
 	messagesReceived := make(map[types.MessageType]bool)
 
-	for i := 0; i < 5; i++ {
+	for i := 0; i < 8; i++ {
 		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
 		var msg map[string]interface{}
 		err := conn.ReadJSON(&msg)
@@ -51,6 +51,9 @@ func TestWebSocketJoinFlow(t *testing.T) {
 	}
 
 	// Verify we received the expected message types
+	require.True(t, messagesReceived[types.ResumeToken], "Should receive resumeToken")
+	require.True(t, messagesReceived[types.DeckInfo], "Should receive deck")
+	require.True(t, messagesReceived[types.RoundState], "Should receive roundState")
 	require.True(t, messagesReceived[types.CurrentIssue], "Should receive currentIssue")
 	require.True(t, messagesReceived[types.ParticipantCount], "Should receive participantCount")
 	require.True(t, messagesReceived[types.VoteStatus], "Should receive voteStatus")
@@ -116,7 +119,7 @@ func TestWebSocketJoinError_DuplicateUsername(t *testing.T) {
 	require.NoError(t, err)
 
 	// Read welcome messages
-	for i := 0; i < 5; i++ {
+	for i := 0; i < 8; i++ {
 		conn1.SetReadDeadline(time.Now().Add(2 * time.Second))
 		var msg types.Message
 		conn1.ReadJSON(&msg)
@@ -175,7 +178,7 @@ func TestWebSocketVoteFlow(t *testing.T) {
 	conn1.WriteJSON(joinMsg1)
 
 	// Read welcome messages for player 1
-	for i := 0; i < 5; i++ {
+	for i := 0; i < 8; i++ {
 		var msg map[string]interface{}
 		conn1.ReadJSON(&msg)
 	}
@@ -191,7 +194,7 @@ func TestWebSocketVoteFlow(t *testing.T) {
 	conn2.WriteJSON(joinMsg2)
 
 	// Read welcome messages for player 2 and broadcast for player 1
-	for i := 0; i < 6; i++ {
+	for i := 0; i < 9; i++ {
 		var msg map[string]interface{}
 		conn2.ReadJSON(&msg)
 	}
@@ -223,6 +226,11 @@ func TestWebSocketVoteFlow(t *testing.T) {
 	require.NoError(t, voteErr, "Should be able to read voteStatus message")
 	require.Equal(t, string(types.VoteStatus), voteStatusMsg["type"].(string), "Should receive voteStatus after voting")
 
+	// The first vote opens the round
+	var roundStateMsg map[string]interface{}
+	require.NoError(t, conn1.ReadJSON(&roundStateMsg))
+	require.Equal(t, string(types.RoundState), roundStateMsg["type"].(string), "Should receive roundState when voting starts")
+
 	// Host reveals
 	revealMsg := types.Message{
 		Type:    types.Reveal,
@@ -263,7 +271,7 @@ func TestWebSocketMultipleClients(t *testing.T) {
 		conn.WriteJSON(joinMsg)
 
 		// Read welcome messages
-		for j := 0; j < 5+i; j++ { // More messages as more clients join
+		for j := 0; j < 8+i; j++ { // More messages as more clients join
 			conn.SetReadDeadline(time.Now().Add(2 * time.Second))
 			var msg types.Message
 			conn.ReadJSON(&msg)
@@ -271,9 +279,9 @@ func TestWebSocketMultipleClients(t *testing.T) {
 	}
 
 	// Verify all clients are connected
-	mutex.Lock()
-	clientCount := len(clients)
-	mutex.Unlock()
+	testRoom().mutex.Lock()
+	clientCount := len(testRoom().clients)
+	testRoom().mutex.Unlock()
 	require.Equal(t, 5, clientCount)
 
 	// Disconnect one client
@@ -283,9 +291,9 @@ func TestWebSocketMultipleClients(t *testing.T) {
 	time.Sleep(200 * time.Millisecond)
 
 	// Verify client was removed
-	mutex.Lock()
-	clientCount = len(clients)
-	mutex.Unlock()
+	testRoom().mutex.Lock()
+	clientCount = len(testRoom().clients)
+	testRoom().mutex.Unlock()
 	require.Equal(t, 4, clientCount)
 }
 
@@ -311,7 +319,7 @@ func TestWebSocketResetFlow(t *testing.T) {
 	conn.WriteJSON(joinMsg)
 
 	// Read welcome messages
-	for i := 0; i < 5; i++ {
+	for i := 0; i < 8; i++ {
 		var msg types.Message
 		conn.ReadJSON(&msg)
 	}
@@ -323,9 +331,11 @@ func TestWebSocketResetFlow(t *testing.T) {
 	}
 	conn.WriteJSON(estimateMsg)
 
-	// Read voteStatus
-	var msg types.Message
-	conn.ReadJSON(&msg)
+	// Read voteStatus and the roundState opening the round
+	for i := 0; i < 2; i++ {
+		var msg map[string]interface{}
+		conn.ReadJSON(&msg)
+	}
 
 	// Reset
 	resetMsg := types.Message{
@@ -349,9 +359,9 @@ func TestWebSocketResetFlow(t *testing.T) {
 	require.True(t, messagesReceived[types.VoteStatus], "Should receive voteStatus")
 
 	// Verify votes were cleared
-	mutex.Lock()
-	for client := range clients {
-		require.Equal(t, int64(0), client.CurrentEstimate, "Votes should be cleared")
+	testRoom().mutex.Lock()
+	for client := range testRoom().clients {
+		require.False(t, client.CurrentEstimate.Voted(), "Votes should be cleared")
 	}
-	mutex.Unlock()
+	testRoom().mutex.Unlock()
 }
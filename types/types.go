@@ -1,5 +1,10 @@
 package types
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type MessageType string
 
 const (
@@ -9,6 +14,7 @@ const (
 	Reveal   MessageType = "reveal"
 	Reset    MessageType = "reset"
 	NewIssue MessageType = "newIssue"
+	Resume   MessageType = "resume"
 	// server messages to client
 	CurrentIssue     MessageType = "currentIssue"
 	ParticipantCount MessageType = "participantCount"
@@ -17,6 +23,33 @@ const (
 	ClearBoard       MessageType = "clearBoard"
 	VoteStatus       MessageType = "voteStatus"
 	JoinError        MessageType = "joinError"
+	ResumeToken      MessageType = "resumeToken"
+	ResumeError      MessageType = "resumeError"
+	DeckInfo         MessageType = "deck"
+	EstimateError    MessageType = "estimateError"
+	RoundState       MessageType = "roundState"
+	// Round countdown
+	StartTimer MessageType = "startTimer"
+	StopTimer  MessageType = "stopTimer"
+	TimerState MessageType = "timer"
+	TimerError MessageType = "timerError"
+	// Host toggle for revealing once everybody has voted
+	SetAutoReveal MessageType = "setAutoReveal"
+	// Sent when a client's role doesn't allow the message it sent
+	PermissionDenied MessageType = "permissionDenied"
+	// Host handoff and co-hosts
+	TransferHost MessageType = "transferHost"
+	SetCoHost    MessageType = "setCoHost"
+	RoleChanged  MessageType = "roleChanged"
+	RoleError    MessageType = "roleError"
+	// Host moderation
+	Kick            MessageType = "kick"
+	Rename          MessageType = "rename"
+	ClearVote       MessageType = "clearVote"
+	Kicked          MessageType = "kicked"
+	Renamed         MessageType = "renamed"
+	VoteCleared     MessageType = "voteCleared"
+	ModerationError MessageType = "moderationError"
 	// Linear queue management
 	MessageIssueSuggested MessageType = "issueSuggested"
 	MessageIssueConfirm   MessageType = "issueConfirm"
@@ -29,17 +62,93 @@ const (
 	MessageQueueDelete  MessageType = "queueDelete"
 	MessageQueueReorder MessageType = "queueReorder"
 	// Linear estimate assignment
-	MessageAssignEstimate MessageType = "assignEstimate"
+	MessageAssignEstimate     MessageType = "assignEstimate"
+	EstimateAssignmentSuccess MessageType = "estimateAssignmentSuccess"
+	EstimateAssignmentError   MessageType = "estimateAssignmentError"
+	AutoAdvance               MessageType = "autoAdvance"
+	// Replies to versioned commands
+	Ack   MessageType = "ack"
+	Error MessageType = "error"
+	// Sent to every client right before the server closes its connection
+	ServerShutdown MessageType = "serverShutdown"
 )
 
+// ProtocolVersion is the envelope version clients opt into by sending "v".
+// Frames without it use the legacy format, where payloads are strings and
+// only failures get a (per-feature) reply.
+const ProtocolVersion = 2
+
+// Envelope is the wire format of every client frame. Payload is kept raw
+// and decoded once the type is known, so it can be a JSON object (v2 and
+// structured legacy messages) or a string (legacy messages).
+type Envelope struct {
+	Version   int             `json:"v,omitempty"`
+	Type      MessageType     `json:"type"`
+	RequestID string          `json:"requestId,omitempty"` // Echoed in the ack or error reply
+	Payload   json.RawMessage `json:"payload,omitempty"`
+}
+
+// ErrorCode is the machine-readable reason a command was rejected
+type ErrorCode string
+
+const (
+	CodeBadRequest      ErrorCode = "bad_request" // The frame or its payload couldn't be decoded
+	CodeUnknownType     ErrorCode = "unknown_type"
+	CodeForbidden       ErrorCode = "forbidden" // The sender's role doesn't allow the command
+	CodeInvalidUsername ErrorCode = "invalid_username"
+	CodeNameTaken       ErrorCode = "name_taken"
+	CodeHostTaken       ErrorCode = "host_taken"
+	CodeBanned          ErrorCode = "banned"
+	CodeInvalidToken    ErrorCode = "invalid_token"
+	CodeInvalidEstimate ErrorCode = "invalid_estimate"
+	CodeVotesLocked     ErrorCode = "votes_locked"
+	CodeInvalidTimer    ErrorCode = "invalid_timer"
+	CodeNotFound        ErrorCode = "not_found"
+	CodeInvalidRole     ErrorCode = "invalid_role"   // The target's role doesn't allow the change
+	CodeInvalidTarget   ErrorCode = "invalid_target" // The command can't be applied to that target
+	CodeStale           ErrorCode = "stale"          // The command refers to state that has since changed
+	CodeUnavailable     ErrorCode = "unavailable"    // The room isn't in a state to run the command
+	CodeUpstream        ErrorCode = "upstream_error" // An integration such as Linear failed
+	CodeUnauthorized    ErrorCode = "unauthorized"   // The HTTP API token is missing or wrong
+)
+
+// AckPayload confirms a versioned command was applied
+type AckPayload struct {
+	Type MessageType `json:"type"` // The acknowledged command
+}
+
+// AckMessage wraps AckPayload
+type AckMessage struct {
+	Version   int         `json:"v"`
+	Type      MessageType `json:"type"`
+	RequestID string      `json:"requestId,omitempty"`
+	Payload   AckPayload  `json:"payload"`
+}
+
+// ErrorPayload explains why a versioned command was rejected
+type ErrorPayload struct {
+	Code    ErrorCode   `json:"code"`
+	Message string      `json:"message"`
+	Type    MessageType `json:"type,omitempty"` // The rejected command, if it could be decoded
+}
+
+// ErrorMessage wraps ErrorPayload
+type ErrorMessage struct {
+	Version   int          `json:"v"`
+	Type      MessageType  `json:"type"`
+	RequestID string       `json:"requestId,omitempty"`
+	Payload   ErrorPayload `json:"payload"`
+}
+
 type Message struct {
 	Type    MessageType `json:"type"`
 	Payload string      `json:"payload"`
 }
 
 type JoinPayload struct {
-	Username string `json:"username"`
-	IsHost   bool   `json:"isHost"`
+	Username   string `json:"username"`
+	IsHost     bool   `json:"isHost"`
+	IsObserver bool   `json:"isObserver,omitempty"` // Watch without voting; takes precedence over IsHost
 }
 
 type JoinMessage struct {
@@ -47,14 +156,81 @@ type JoinMessage struct {
 	Payload JoinPayload `json:"payload"`
 }
 
+// ResumeTokenPayload is sent after a successful join or resume. Sending the
+// token back in a resume message reattaches a new socket to the same
+// identity, host role and vote within the grace period.
+type ResumeTokenPayload struct {
+	Token        string `json:"token"`
+	GraceSeconds int    `json:"graceSeconds"`
+}
+
+// ResumeTokenMessage wraps ResumeTokenPayload
+type ResumeTokenMessage struct {
+	Type    MessageType        `json:"type"`
+	Payload ResumeTokenPayload `json:"payload"`
+}
+
+// DeckCard is a card participants can vote with. Label is what clients
+// display and send as the estimate; Value is used for averaging.
+type DeckCard struct {
+	Label string  `json:"label"`
+	Value float64 `json:"value"`
+}
+
+// SpecialCard is a non-numeric card ("?", "☕", "∞") offered by every deck.
+// It counts as a vote but is excluded from the average.
+type SpecialCard struct {
+	Label       string `json:"label"`
+	Description string `json:"description"`
+}
+
+// DeckPayload describes the deck in use, sent to clients on join
+type DeckPayload struct {
+	Name     string        `json:"name"`
+	Cards    []DeckCard    `json:"cards"`
+	Specials []SpecialCard `json:"specials"`
+}
+
+// DeckMessage wraps DeckPayload
+type DeckMessage struct {
+	Type    MessageType `json:"type"`
+	Payload DeckPayload `json:"payload"`
+}
+
 type UserEstimate struct {
 	User     string `json:"user"`
 	Estimate string `json:"estimate"`
+	Special  bool   `json:"special,omitempty"` // Estimate is a special card
+}
+
+// SpecialSummary counts who played a special card, e.g. "2 people need clarification"
+type SpecialSummary struct {
+	Card    string   `json:"card"`
+	Count   int      `json:"count"`
+	Users   []string `json:"users"`
+	Summary string   `json:"summary"`
+}
+
+// RevealStats summarizes the numeric votes of a round. Card values are
+// reported as deck labels; special cards are not included.
+type RevealStats struct {
+	Votes         int      `json:"votes"` // Number of numeric votes
+	Mean          float64  `json:"mean"`
+	Median        float64  `json:"median"`
+	Mode          []string `json:"mode"` // Most played cards, several on a tie
+	Min           string   `json:"min"`
+	Max           string   `json:"max"`
+	StdDev        float64  `json:"stdDev"`
+	Consensus     bool     `json:"consensus"`     // All votes equal or on adjacent cards
+	HighestVoters []string `json:"highestVoters"` // Empty when everybody agrees
+	LowestVoters  []string `json:"lowestVoters"`  // Empty when everybody agrees
 }
 
 type RevealPayload struct {
-	Estimates []UserEstimate `json:"estimates"`
-	PointAvg  string         `json:"pointAvg"`
+	Estimates []UserEstimate   `json:"estimates"`
+	PointAvg  string           `json:"pointAvg"`
+	Specials  []SpecialSummary `json:"specials"`
+	Stats     *RevealStats     `json:"stats,omitempty"` // Nil when nobody voted with a numeric card
 }
 
 type RevealMessage struct {
@@ -62,9 +238,195 @@ type RevealMessage struct {
 	Payload RevealPayload `json:"payload"`
 }
 
+// RoundPhase is the lifecycle of a voting round, owned by the server
+type RoundPhase string
+
+const (
+	RoundIdle     RoundPhase = "idle"     // No round has started yet
+	RoundVoting   RoundPhase = "voting"   // Votes are being cast
+	RoundRevealed RoundPhase = "revealed" // Votes are shown and locked
+	RoundClosed   RoundPhase = "closed"   // Votes were cleared, waiting for the next round
+)
+
+// RoundStatePayload is broadcast on every phase transition and sent to
+// clients when they join, so everybody sees the same phase
+type RoundStatePayload struct {
+	Phase  RoundPhase     `json:"phase"`
+	Round  int            `json:"round"` // Incremented each time voting starts
+	Issue  string         `json:"issue"`
+	Reveal *RevealPayload `json:"reveal,omitempty"` // Results while the round is revealed
+	Timer  *TimerPayload  `json:"timer,omitempty"`  // Countdown while one is running
+
+	AutoReveal        bool  `json:"autoReveal"`        // Votes are revealed once everybody has voted
+	AutoRevealDelayMs int64 `json:"autoRevealDelayMs"` // Grace period before an auto-reveal
+}
+
+// RoundStateMessage wraps RoundStatePayload
+type RoundStateMessage struct {
+	Type    MessageType       `json:"type"`
+	Payload RoundStatePayload `json:"payload"`
+}
+
+// TransferHostPayload hands the sender's host role to another participant
+type TransferHostPayload struct {
+	Username string `json:"username"`
+}
+
+// TransferHostMessage wraps TransferHostPayload
+type TransferHostMessage struct {
+	Type    MessageType         `json:"type"`
+	Payload TransferHostPayload `json:"payload"`
+}
+
+// CoHostPayload makes a participant a co-host, or takes the role away
+type CoHostPayload struct {
+	Username string `json:"username"`
+	CoHost   bool   `json:"coHost"`
+}
+
+// CoHostMessage wraps CoHostPayload
+type CoHostMessage struct {
+	Type    MessageType   `json:"type"`
+	Payload CoHostPayload `json:"payload"`
+}
+
+// ParticipantRole is the role a participant holds after a change
+type ParticipantRole struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+// RoleChangedPayload is broadcast whenever somebody gains or loses the host
+// role, so every client knows who is in charge
+type RoleChangedPayload struct {
+	Changes []ParticipantRole `json:"changes"`
+	Reason  string            `json:"reason"`       // "transfer", "cohost", "promoted" or "replaced"
+	By      string            `json:"by,omitempty"` // Host who made the change, empty if automatic
+}
+
+// RoleChangedMessage wraps RoleChangedPayload
+type RoleChangedMessage struct {
+	Type    MessageType        `json:"type"`
+	Payload RoleChangedPayload `json:"payload"`
+}
+
+// KickPayload removes a participant from the session
+type KickPayload struct {
+	Username string `json:"username"`
+	Ban      bool   `json:"ban,omitempty"` // Refuse the name until the room closes
+}
+
+// KickMessage wraps KickPayload
+type KickMessage struct {
+	Type    MessageType `json:"type"`
+	Payload KickPayload `json:"payload"`
+}
+
+// RenamePayload changes a participant's display name
+type RenamePayload struct {
+	Username string `json:"username"`
+	NewName  string `json:"newName"`
+}
+
+// RenameMessage wraps RenamePayload
+type RenameMessage struct {
+	Type    MessageType   `json:"type"`
+	Payload RenamePayload `json:"payload"`
+}
+
+// RenamedPayload is broadcast after a participant was renamed
+type RenamedPayload struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	By   string `json:"by"`
+}
+
+// RenamedMessage wraps RenamedPayload
+type RenamedMessage struct {
+	Type    MessageType    `json:"type"`
+	Payload RenamedPayload `json:"payload"`
+}
+
+// ClearVotePayload takes back a participant's vote
+type ClearVotePayload struct {
+	Username string `json:"username"`
+}
+
+// ClearVoteMessage wraps ClearVotePayload
+type ClearVoteMessage struct {
+	Type    MessageType      `json:"type"`
+	Payload ClearVotePayload `json:"payload"`
+}
+
+// VoteClearedPayload is broadcast after a host cleared somebody's vote
+type VoteClearedPayload struct {
+	Username string `json:"username"`
+	By       string `json:"by"`
+}
+
+// VoteClearedMessage wraps VoteClearedPayload
+type VoteClearedMessage struct {
+	Type    MessageType        `json:"type"`
+	Payload VoteClearedPayload `json:"payload"`
+}
+
+// PermissionDeniedPayload explains why a message was rejected
+type PermissionDeniedPayload struct {
+	Action  MessageType `json:"action"` // The rejected message type
+	Role    string      `json:"role"`   // The sender's role at the time
+	Message string      `json:"message"`
+}
+
+// PermissionDeniedMessage wraps PermissionDeniedPayload
+type PermissionDeniedMessage struct {
+	Type    MessageType             `json:"type"`
+	Payload PermissionDeniedPayload `json:"payload"`
+}
+
+// AutoRevealPayload turns auto-reveal on or off for a room
+type AutoRevealPayload struct {
+	Enabled bool `json:"enabled"`
+}
+
+// AutoRevealMessage wraps AutoRevealPayload
+type AutoRevealMessage struct {
+	Type    MessageType       `json:"type"`
+	Payload AutoRevealPayload `json:"payload"`
+}
+
+// StartTimerPayload asks the server to timebox the current round
+type StartTimerPayload struct {
+	Seconds    int  `json:"seconds"`
+	AutoReveal bool `json:"autoReveal"` // Reveal the votes when time runs out
+}
+
+// StartTimerMessage wraps StartTimerPayload
+type StartTimerMessage struct {
+	Type    MessageType       `json:"type"`
+	Payload StartTimerPayload `json:"payload"`
+}
+
+// TimerPayload is broadcast when a countdown starts, stops or runs out.
+// Clients render the clock from Deadline so everybody sees the same time.
+type TimerPayload struct {
+	Running    bool       `json:"running"`
+	Deadline   *time.Time `json:"deadline,omitempty"` // Set while running
+	ServerTime time.Time  `json:"serverTime"`         // Lets clients correct for clock skew
+	Seconds    int        `json:"seconds"`
+	AutoReveal bool       `json:"autoReveal"`
+	Expired    bool       `json:"expired,omitempty"` // Time ran out rather than being stopped
+}
+
+// TimerMessage wraps TimerPayload
+type TimerMessage struct {
+	Type    MessageType  `json:"type"`
+	Payload TimerPayload `json:"payload"`
+}
+
 type VoterInfo struct {
 	Username string `json:"username"`
 	HasVoted bool   `json:"hasVoted"`
+	IsHost   bool   `json:"isHost,omitempty"`
 }
 
 type VoteStatusPayload struct {
@@ -104,13 +466,28 @@ type CurrentIssuePayload struct {
 	LinearIssue *LinearIssue `json:"linearIssue,omitempty"`
 }
 
-// IssueRevealData stores voting results for a specific issue
+// CurrentIssueMessage sends CurrentIssuePayload as an object to versioned
+// clients; legacy clients get it JSON-encoded in a string payload
+type CurrentIssueMessage struct {
+	Version int                 `json:"v"`
+	Type    MessageType         `json:"type"`
+	Payload CurrentIssuePayload `json:"payload"`
+}
+
+// IssueRevealData stores voting results for a specific issue. One is
+// recorded in the session history for every revealed round.
 type IssueRevealData struct {
-	IssueIdentifier string         `json:"issueIdentifier"`
-	IssueID         string         `json:"issueId"`
+	Round           int            `json:"round"`
+	IssueIdentifier string         `json:"issueIdentifier,omitempty"` // Linear issues only
+	IssueID         string         `json:"issueId,omitempty"`         // Linear issues only
+	IssueTitle      string         `json:"issueTitle"`
+	Host            string         `json:"host,omitempty"`
 	Estimates       []UserEstimate `json:"estimates"`
-	Average         int64          `json:"average"`
-	RoundedEstimate int64          `json:"roundedEstimate"`
+	Average         string         `json:"average"`                   // Card label, empty if nobody voted with a number
+	RoundedEstimate *int64         `json:"roundedEstimate,omitempty"` // Average value rounded to whole points, unset if nobody voted with a number
+	Stats           *RevealStats   `json:"stats,omitempty"`
+	StartedAt       time.Time      `json:"startedAt"`
+	RevealedAt      time.Time      `json:"revealedAt"`
 }
 
 // IssueSuggestedPayload contains details for a suggested issue with versioning
@@ -0,0 +1,23 @@
+// Package web embeds the built web app into the poker binary
+package web
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// dist is the output of npm run build. Only a placeholder is committed, so
+// a binary built without building the web app first has no app and its
+// server refuses to start.
+//
+//go:embed all:dist
+var dist embed.FS
+
+// Dist returns the built web app, rooted at index.html
+func Dist() fs.FS {
+	sub, err := fs.Sub(dist, "dist")
+	if err != nil {
+		panic(err) // "dist" is always a valid path
+	}
+	return sub
+}
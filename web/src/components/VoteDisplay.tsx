@@ -73,7 +73,7 @@ export const VoteDisplay = () => {
                   </div>
                 </div>
                 <div className="text-foreground font-mono w-8 text-right font-bold">
-                  {vote.points === "0" ? "NV" : vote.points}
+                  {vote.points === "" ? "NV" : vote.points}
                 </div>
               </>
             ) : (
@@ -86,7 +86,7 @@ export const VoteDisplay = () => {
         ))}
       </div>
 
-      {gameState.revealed && gameState.averagePoints !== "0" && (
+      {gameState.revealed && gameState.averagePoints !== "" && (
         <div className="mt-2 pt-2 border-t border-border">
           <div className="flex items-center justify-between text-xs font-mono uppercase">
             <span className="text-muted-foreground">Average</span>
@@ -69,7 +69,7 @@ export const PokerProvider: React.FC<PokerProviderProps> = ({ children, onAutoRe
         votes: [],
         voters: [],
         revealed: false,
-        averagePoints: "0",
+        averagePoints: "",
         roundNumber: 1,
         queueItems: [],
       };
@@ -84,7 +84,7 @@ export const PokerProvider: React.FC<PokerProviderProps> = ({ children, onAutoRe
       votes: [],
       voters: [],
       revealed: false,
-      averagePoints: "0",
+      averagePoints: "",
       roundNumber: 1,
       queueItems: [],
     };
@@ -150,7 +150,7 @@ export const PokerProvider: React.FC<PokerProviderProps> = ({ children, onAutoRe
         });
         break;
       case "clearBoard":
-        const avg = currentState.averagePoints !== "0" ? currentState.averagePoints : "N/A";
+        const avg = currentState.averagePoints !== "" ? currentState.averagePoints : "N/A";
         setActivities((prev) => {
           const updated = [...prev, logActivity("RESET", `round completed, avg=${avg}`)];
           return updated.slice(-100);
@@ -354,7 +354,7 @@ export const PokerProvider: React.FC<PokerProviderProps> = ({ children, onAutoRe
       votes: [],
       voters: [],
       revealed: false,
-      averagePoints: "0",
+      averagePoints: "",
       roundNumber: 1,
       queueItems: [],
     });